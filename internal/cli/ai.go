@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- AI (optional) ----------
+
+// --ai summaries go to the LLM provider of the config (config set-ai):
+// OpenAI by default, any OpenAI-compatible server (vLLM, LM Studio,
+// llama.cpp, a gateway), Anthropic, or a local Ollama. With ollama or a
+// server on your own network, target data does not leave it.
+
+// DefaultAITemperature is used when the config sets none.
+const DefaultAITemperature = 0.2
+
+// aiProvider sends one prompt and returns the model's answer.
+type aiProvider interface {
+	complete(ctx context.Context, system, prompt string) (string, error)
+}
+
+// aiBackend describes a provider: its defaults and where its key comes from.
+type aiBackend struct {
+	endpoint, model string
+	keyEnv          string // environment variable checked before the config
+	needsKey        bool   // AI is off until a key is set
+	timeout         time.Duration
+	new             func(aiSettings) aiProvider
+}
+
+var aiBackends = map[string]aiBackend{
+	"openai":            {"https://api.openai.com/v1", "gpt-4o-mini", "OPENAI_API_KEY", true, 30 * time.Second, newOpenAIProvider},
+	"openai-compatible": {"", "", "", false, 2 * time.Minute, newOpenAIProvider},
+	"anthropic":         {"https://api.anthropic.com", "claude-3-5-haiku-latest", "ANTHROPIC_API_KEY", true, 30 * time.Second, newAnthropicProvider},
+	"ollama":            {"http://localhost:11434", "llama3.1", "", false, 5 * time.Minute, newOllamaProvider},
+}
+
+func aiProviderNames() []string {
+	var names []string
+	for n := range aiBackends { names = append(names, n) }
+	sort.Strings(names)
+	return names
+}
+
+// aiSettings is the AI configuration with the provider's defaults filled in.
+type aiSettings struct {
+	Provider, Model, Endpoint, Key string
+	Temperature                    float64
+	backend                        aiBackend
+}
+
+func resolveAI(cfg *Config) (aiSettings, error) {
+	s := aiSettings{Provider: strings.TrimSpace(cfg.AIProvider), Model: strings.TrimSpace(cfg.AIModel), Endpoint: strings.TrimSpace(cfg.AIEndpoint), Temperature: DefaultAITemperature}
+	if s.Provider == "" { s.Provider = "openai" }
+	b, ok := aiBackends[s.Provider]
+	if !ok { return s, fmt.Errorf("unknown AI provider %q (%s)", s.Provider, strings.Join(aiProviderNames(), ", ")) }
+	s.backend = b
+	if s.Model == "" { s.Model = b.model }
+	if s.Endpoint == "" { s.Endpoint = b.endpoint }
+	s.Endpoint = strings.TrimRight(s.Endpoint, "/")
+	if cfg.AITemperature != nil { s.Temperature = *cfg.AITemperature }
+	if b.keyEnv != "" { s.Key = strings.TrimSpace(os.Getenv(b.keyEnv)) }
+	if s.Key == "" { s.Key = strings.TrimSpace(cfg.AIAPIKey) }
+	if s.Key == "" && s.Provider == "openai" { s.Key = strings.TrimSpace(cfg.OpenAIAPIKey) }
+	if s.Endpoint == "" { return s, fmt.Errorf("AI provider %s needs an endpoint (config set-ai %s --endpoint <url>)", s.Provider, s.Provider) }
+	if s.Model == "" { return s, fmt.Errorf("AI provider %s needs a model (config set-ai %s --model <name>)", s.Provider, s.Provider) }
+	return s, nil
+}
+
+// errAIRateLimited: the summary is skipped, as a quota problem should not
+// fail scans.
+var errAIRateLimited = errors.New("rate limited")
+
+func aiSummary(ctx context.Context, domain string, newSubs []string) (summary string, err error) {
+	cfg, err := loadConfig(); if err != nil { return "", err }
+	s, err := resolveAI(cfg); if err != nil { return "", err }
+	if s.backend.needsKey && s.Key == "" { return "", nil }
+	if len(newSubs)==0 { return "No new subdomains found.", nil }
+	start := time.Now()
+	defer func() { observeAIRequest(time.Since(start), err) }()
+	userMsg := fmt.Sprintf("Domain: %s\nNew subdomains (%d):\n- %s\n\nTask: 1) Group by obvious services (auth, api, dev, staging, admin, cdn, mail, vpn, grafana, kibana, git, test). 2) Flag likely high-value targets. 3) Suggest next checks (httpx, tls certs, title, tech stack, weak DNS). Output in concise bullets.", domain, len(newSubs), strings.Join(newSubs, "\n- "))
+	summary, err = s.backend.new(s).complete(ctx, "You are a security assistant. Be concise and actionable.", userMsg)
+	if errors.Is(err, errAIRateLimited) { return "", nil }
+	if err != nil { return "", err }
+	return strings.TrimSpace(summary), nil
+}
+
+// postAI posts payload as JSON to url and decodes the answer into out.
+func postAI(ctx context.Context, s aiSettings, url string, header http.Header, payload, out any) error {
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body)); if err != nil { return err }
+	for k, v := range header { req.Header[k] = v }
+	req.Header.Set("Content-Type", "application/json")
+	c := &http.Client{Timeout: s.backend.timeout}
+	resp, err := c.Do(req); if err != nil { return err }
+	defer resp.Body.Close()
+	if resp.StatusCode==429 { io.Copy(io.Discard, resp.Body); return errAIRateLimited }
+	if resp.StatusCode>=300 { b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096)); return fmt.Errorf("%s error: %s\n%s", s.Provider, resp.Status, string(b)) }
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// openAIProvider speaks /chat/completions, for OpenAI and compatible servers.
+type openAIProvider struct{ s aiSettings }
+
+func newOpenAIProvider(s aiSettings) aiProvider { return openAIProvider{s} }
+
+func (p openAIProvider) complete(ctx context.Context, system, prompt string) (string, error) {
+	payload := map[string]any{
+		"model": p.s.Model,
+		"messages": []map[string]string{
+			{"role":"system","content":system},
+			{"role":"user","content":prompt},
+		},
+		"temperature": p.s.Temperature,
+	}
+	h := http.Header{}
+	if p.s.Key != "" { h.Set("Authorization", "Bearer "+p.s.Key) }
+	var parsed struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := postAI(ctx, p.s, p.s.Endpoint+"/chat/completions", h, payload, &parsed); err != nil { return "", err }
+	if len(parsed.Choices)==0 { return "", errors.New("no AI choices returned") }
+	return parsed.Choices[0].Message.Content, nil
+}
+
+// anthropicProvider speaks the Messages API.
+type anthropicProvider struct{ s aiSettings }
+
+func newAnthropicProvider(s aiSettings) aiProvider { return anthropicProvider{s} }
+
+func (p anthropicProvider) complete(ctx context.Context, system, prompt string) (string, error) {
+	payload := map[string]any{
+		"model":       p.s.Model,
+		"max_tokens":  1024,
+		"system":      system,
+		"messages":    []map[string]string{{"role":"user","content":prompt}},
+		"temperature": p.s.Temperature,
+	}
+	h := http.Header{}
+	h.Set("x-api-key", p.s.Key)
+	h.Set("anthropic-version", "2023-06-01")
+	var parsed struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := postAI(ctx, p.s, p.s.Endpoint+"/v1/messages", h, payload, &parsed); err != nil { return "", err }
+	var text []string
+	for _, c := range parsed.Content { if c.Type=="text" { text = append(text, c.Text) } }
+	if len(text)==0 { return "", errors.New("no AI text returned") }
+	return strings.Join(text, "\n"), nil
+}
+
+// ollamaProvider speaks Ollama's /api/chat, without streaming.
+type ollamaProvider struct{ s aiSettings }
+
+func newOllamaProvider(s aiSettings) aiProvider { return ollamaProvider{s} }
+
+func (p ollamaProvider) complete(ctx context.Context, system, prompt string) (string, error) {
+	payload := map[string]any{
+		"model": p.s.Model,
+		"messages": []map[string]string{
+			{"role":"system","content":system},
+			{"role":"user","content":prompt},
+		},
+		"stream":  false,
+		"options": map[string]any{"temperature": p.s.Temperature},
+	}
+	var parsed struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	if err := postAI(ctx, p.s, p.s.Endpoint+"/api/chat", nil, payload, &parsed); err != nil { return "", err }
+	if strings.TrimSpace(parsed.Message.Content)=="" { return "", errors.New("no AI text returned") }
+	return parsed.Message.Content, nil
+}
+
+// setAI is `config set-ai`: it replaces the AI settings. The key is kept
+// unless the provider changes, so it is not sent to another endpoint.
+func setAI(cfg *Config, provider, model, endpoint, key string, temperature *float64) error {
+	if _, ok := aiBackends[provider]; !ok { return fmt.Errorf("unknown AI provider %q (%s)", provider, strings.Join(aiProviderNames(), ", ")) }
+	if provider != cfg.AIProvider && !(cfg.AIProvider == "" && provider == "openai") { cfg.AIAPIKey = "" }
+	cfg.AIProvider, cfg.AIModel, cfg.AIEndpoint, cfg.AITemperature = provider, model, "", nil
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" { return fmt.Errorf("invalid endpoint %q: want an http(s) base URL", endpoint) }
+		cfg.AIEndpoint = endpoint
+	}
+	if temperature != nil {
+		if *temperature < 0 || *temperature > 2 { return fmt.Errorf("invalid temperature %g: want 0 to 2", *temperature) }
+		cfg.AITemperature = temperature
+	}
+	if key != "" { cfg.AIAPIKey = key }
+	_, err := resolveAI(cfg)
+	return err
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// setupAIHome points the home at a temp dir with cfg and clears the keys of
+// the environment.
+func setupAIHome(t *testing.T, cfg *Config) {
+	t.Helper()
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if err := ensureDirs(); err != nil { t.Fatal(err) }
+	if err := saveConfig(cfg); err != nil { t.Fatal(err) }
+}
+
+// stubAI answers POSTs to path with answer and records the last request.
+type stubAI struct {
+	path   string
+	status int
+	answer string
+	header http.Header
+	body   map[string]any
+}
+
+func (s *stubAI) start(t *testing.T) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != s.path { http.Error(w, "unexpected "+r.Method+" "+r.URL.Path, 404); return }
+		s.header = r.Header.Clone()
+		s.body = nil
+		if err := json.NewDecoder(r.Body).Decode(&s.body); err != nil { http.Error(w, err.Error(), 400); return }
+		if s.status != 0 { w.WriteHeader(s.status) }
+		w.Write([]byte(s.answer))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func float(v float64) *float64 { return &v }
+
+func TestAISummaryProviders(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		stub   stubAI
+		header map[string]string
+		model  string
+		temp   float64
+	}{
+		{name: "openai", cfg: Config{OpenAIAPIKey: "sk-test"},
+			stub:   stubAI{path: "/v1/chat/completions", answer: `{"choices":[{"message":{"content":" - api.example.com: API \n"}}]}`},
+			header: map[string]string{"Authorization": "Bearer sk-test"}, model: "gpt-4o-mini", temp: DefaultAITemperature},
+		{name: "openai-compatible", cfg: Config{AIProvider: "openai-compatible", AIModel: "qwen2.5", AITemperature: float(0)},
+			stub:   stubAI{path: "/v1/chat/completions", answer: `{"choices":[{"message":{"content":"- api.example.com: API"}}]}`},
+			header: map[string]string{"Authorization": ""}, model: "qwen2.5", temp: 0},
+		{name: "anthropic", cfg: Config{AIProvider: "anthropic", AIAPIKey: "ant-test", AITemperature: float(0.7)},
+			stub:   stubAI{path: "/v1/messages", answer: `{"content":[{"type":"text","text":"- api.example.com: API"}]}`},
+			header: map[string]string{"X-Api-Key": "ant-test", "Anthropic-Version": "2023-06-01"}, model: "claude-3-5-haiku-latest", temp: 0.7},
+		{name: "ollama", cfg: Config{AIProvider: "ollama", AIModel: "llama3.2"},
+			stub:  stubAI{path: "/api/chat", answer: `{"message":{"role":"assistant","content":"- api.example.com: API"}}`},
+			model: "llama3.2", temp: DefaultAITemperature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := tt.stub
+			base := stub.start(t)
+			if tt.name != "ollama" && tt.name != "anthropic" { base += "/v1" }
+			tt.cfg.AIEndpoint = base + "/"
+			setupAIHome(t, &tt.cfg)
+
+			got, err := aiSummary(context.Background(), "example.com", []string{"api.example.com"})
+			if err != nil { t.Fatal(err) }
+			if got != "- api.example.com: API" { t.Errorf("summary = %q", got) }
+			for k, v := range tt.header {
+				if g := stub.header.Get(k); g != v { t.Errorf("header %s = %q, want %q", k, g, v) }
+			}
+			if stub.body["model"] != tt.model { t.Errorf("model = %v, want %s", stub.body["model"], tt.model) }
+			temp := stub.body["temperature"]
+			if opts, ok := stub.body["options"].(map[string]any); ok { temp = opts["temperature"] }
+			if temp != tt.temp { t.Errorf("temperature = %v, want %v", temp, tt.temp) }
+			if b, _ := json.Marshal(stub.body); !strings.Contains(string(b), "api.example.com") { t.Errorf("prompt lacks the hosts: %s", b) }
+		})
+	}
+}
+
+func TestAISummaryErrors(t *testing.T) {
+	t.Run("rate limited", func(t *testing.T) {
+		stub := stubAI{path: "/api/chat", status: 429, answer: `{"error":"slow down"}`}
+		setupAIHome(t, &Config{AIProvider: "ollama", AIEndpoint: stub.start(t)})
+		got, err := aiSummary(context.Background(), "example.com", []string{"a.example.com"})
+		if got != "" || err != nil { t.Errorf("got %q, %v; want the summary skipped", got, err) }
+	})
+	t.Run("server error", func(t *testing.T) {
+		stub := stubAI{path: "/api/chat", status: 500, answer: `model not found`}
+		setupAIHome(t, &Config{AIProvider: "ollama", AIEndpoint: stub.start(t)})
+		_, err := aiSummary(context.Background(), "example.com", []string{"a.example.com"})
+		if err == nil || !strings.Contains(err.Error(), "model not found") { t.Errorf("err = %v", err) }
+	})
+	t.Run("no key", func(t *testing.T) {
+		setupAIHome(t, &Config{AIProvider: "anthropic"})
+		got, err := aiSummary(context.Background(), "example.com", []string{"a.example.com"})
+		if got != "" || err != nil { t.Errorf("got %q, %v; want AI off", got, err) }
+	})
+	t.Run("no endpoint", func(t *testing.T) {
+		setupAIHome(t, &Config{AIProvider: "openai-compatible", AIModel: "m"})
+		if _, err := aiSummary(context.Background(), "example.com", []string{"a.example.com"}); err == nil { t.Error("want an error") }
+	})
+}
+
+func TestSetAI(t *testing.T) {
+	cfg := &Config{AIProvider: "anthropic", AIAPIKey: "ant-test", AIModel: "claude-x"}
+	if err := setAI(cfg, "anthropic", "", "", "", float(0.5)); err != nil { t.Fatal(err) }
+	if cfg.AIAPIKey != "ant-test" || cfg.AIModel != "" || cfg.AITemperature == nil || *cfg.AITemperature != 0.5 {
+		t.Errorf("same provider: %+v", cfg)
+	}
+	if err := setAI(cfg, "ollama", "", "", "", nil); err != nil { t.Fatal(err) }
+	if cfg.AIAPIKey != "" { t.Error("key kept across providers") }
+	for _, bad := range []struct {
+		provider, model, endpoint string
+		temperature               *float64
+	}{
+		{"gemini", "", "", nil},
+		{"openai-compatible", "m", "", nil},
+		{"openai-compatible", "m", "localhost:8000", nil},
+		{"ollama", "", "", float(3)},
+	} {
+		if err := setAI(&Config{}, bad.provider, bad.model, bad.endpoint, "", bad.temperature); err == nil { t.Errorf("setAI(%+v) succeeded", bad) }
+	}
+}
+
+func TestConfigRecordAIError(t *testing.T) {
+	setupAIHome(t, &Config{})
+	r := newConfigRecord(&Config{AIProvider: "gemini", AIModel: "g-1", AITemperature: float(0.7), AIAPIKey: "gem-secret-key"})
+	if r.AIError == "" || r.AIProvider != "gemini" || r.AIModel != "g-1" || r.AIEndpoint != "" || r.AITemperature != 0.7 {
+		t.Errorf("unknown provider: %+v", r)
+	}
+	if r.AIAPIKey == "" || strings.Contains(r.AIAPIKey, "secret") { t.Errorf("ai_api_key = %q, want it masked", r.AIAPIKey) }
+	r = newConfigRecord(&Config{AIProvider: "openai-compatible"})
+	if !strings.Contains(r.AIError, "endpoint") || r.AIProvider != "openai-compatible" || r.AITemperature != DefaultAITemperature { t.Errorf("no endpoint: %+v", r) }
+	if r = newConfigRecord(&Config{}); r.AIError != "" || r.AIProvider != "openai" || r.AIModel == "" || r.AIEndpoint == "" { t.Errorf("default: %+v", r) }
+}
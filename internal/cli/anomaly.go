@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Health heuristics: an expired API key does not make subfinder fail, it
+// just returns a fraction of the usual hosts. Each successful run is
+// compared against the median of the domain's recent healthy runs, in total
+// and per passive source.
+const (
+	anomalyWindow     = 10  // recent healthy runs forming the baseline
+	anomalyMinRuns    = 3   // baseline runs needed before judging
+	anomalyRatio      = 0.5 // alert when the total drops below this share of the median
+	anomalyMinHosts   = 20  // ... and the median is at least this large
+	sourceRatio       = 0.2 // a single source must drop further
+	sourceMinHosts    = 10
+	anomalyRebaseline = 5 // anomalous runs in a row after which their level is the new normal
+)
+
+// detectAnomalies compares run with prev (oldest first) and describes every
+// collapse it finds. Runs that were anomalous themselves are left out of the
+// baseline, so a broken source keeps alerting until it recovers, or until
+// anomalyRebaseline runs in a row have been at the lower level: those runs
+// then become the baseline (a source that was dropped for good).
+func detectAnomalies(run *RunRecord, prev []RunRecord) []string {
+	var base, streak []RunRecord
+	for i := len(prev) - 1; i >= 0 && len(base) < anomalyWindow; i-- {
+		r := prev[i]
+		if r.Status != "ok" { continue }
+		if len(r.Anomalies) == 0 { base = append(base, r); streak = nil; continue }
+		if streak = append(streak, r); len(streak) == anomalyRebaseline { base = append(base, streak...); break }
+	}
+	if len(base) > anomalyWindow { base = base[:anomalyWindow] }
+	if len(base) < anomalyMinRuns { return nil }
+	var out []string
+	totals := make([]int, len(base))
+	for i, r := range base { totals[i] = r.Returned }
+	if m := median(totals); m >= anomalyMinHosts && float64(run.Returned) < anomalyRatio*float64(m) {
+		out = append(out, fmt.Sprintf("%s returned %s hosts vs %s median of the last %d runs", run.Domain, commas(run.Returned), commas(m), len(base)))
+	}
+
+	// per source, only against runs that recorded sources
+	var withSources []RunRecord
+	for _, r := range base { if len(r.Sources) > 0 { withSources = append(withSources, r) } }
+	if len(withSources) < anomalyMinRuns { return out }
+	names := map[string]bool{}
+	for _, r := range withSources { for s := range r.Sources { names[s] = true } }
+	sorted := make([]string, 0, len(names))
+	for s := range names { sorted = append(sorted, s) }
+	sort.Strings(sorted)
+	for _, s := range sorted {
+		counts := make([]int, len(withSources))
+		for i, r := range withSources { counts[i] = r.Sources[s] }
+		m := median(counts)
+		if m >= sourceMinHosts && float64(run.Sources[s]) < sourceRatio*float64(m) {
+			out = append(out, fmt.Sprintf("source %s returned %s hosts vs %s median", s, commas(run.Sources[s]), commas(m)))
+		}
+	}
+	return out
+}
+
+// sinceBaseline drops the runs of domain before its last
+// `history --reset-baseline`.
+func sinceBaseline(domain string, prev []RunRecord) []RunRecord {
+	st, err := loadState(); if err != nil { return prev }
+	ds := st.Domains[domain]
+	if ds == nil || ds.BaselineFrom == 0 { return prev }
+	for i, r := range prev { if r.Seq >= ds.BaselineFrom { return prev[i:] } }
+	return nil
+}
+
+func median(v []int) int {
+	if len(v) == 0 { return 0 }
+	s := append([]int(nil), v...)
+	sort.Ints(s)
+	if n := len(s); n%2 == 0 { return (s[n/2-1] + s[n/2]) / 2 }
+	return s[len(s)/2]
+}
+
+// commas formats n with thousands separators: 3400 -> "3,400".
+func commas(n int) string {
+	s := strconv.Itoa(n)
+	neg := n < 0
+	if neg { s = s[1:] }
+	for i := len(s) - 3; i > 0; i -= 3 { s = s[:i] + "," + s[i:] }
+	if neg { s = "-" + s }
+	return s
+}
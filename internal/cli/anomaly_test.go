@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// scanSeries feeds totals to detectAnomalies one run at a time, the way
+// scans record them, and returns which runs were flagged.
+func scanSeries(totals []int) []bool {
+	var runs []RunRecord
+	flagged := make([]bool, len(totals))
+	for i, n := range totals {
+		r := RunRecord{Seq: i + 1, Domain: "example.com", Status: "ok", Returned: n}
+		r.Anomalies = detectAnomalies(&r, runs)
+		flagged[i] = len(r.Anomalies) > 0
+		runs = append(runs, r)
+	}
+	return flagged
+}
+
+func repeat(n, times int) []int {
+	out := make([]int, times)
+	for i := range out { out[i] = n }
+	return out
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	r := RunRecord{Domain: "example.com", Status: "ok", Returned: 12}
+	prev := []RunRecord{{Status: "ok", Returned: 3400}, {Status: "failed"}, {Status: "ok", Returned: 3300}, {Status: "ok", Returned: 3500}}
+	got := detectAnomalies(&r, prev)
+	if len(got) != 1 || got[0] != "example.com returned 12 hosts vs 3,400 median of the last 3 runs" { t.Errorf("got %q", got) }
+	if got := detectAnomalies(&r, prev[:2]); got != nil { t.Errorf("judged with too few runs: %q", got) }
+	r.Returned = 2000
+	if got := detectAnomalies(&r, prev); got != nil { t.Errorf("normal run flagged: %q", got) }
+
+	// per source
+	src := func(n int) RunRecord { return RunRecord{Status: "ok", Returned: 1000, Sources: map[string]int{"crtsh": 500, "virustotal": n}} }
+	r = src(3)
+	got = detectAnomalies(&r, []RunRecord{src(100), src(120), src(90)})
+	if len(got) != 1 || !strings.HasPrefix(got[0], "source virustotal returned 3 hosts vs 100 median") { t.Errorf("got %q", got) }
+}
+
+func TestDetectAnomaliesRebaseline(t *testing.T) {
+	// a collapse that lasts becomes the new normal after anomalyRebaseline runs
+	totals := append(repeat(1000, 10), repeat(100, anomalyRebaseline+3)...)
+	for i, f := range scanSeries(totals) {
+		want := i >= 10 && i < 10+anomalyRebaseline
+		if f != want { t.Errorf("run %d (%d hosts): flagged %v, want %v", i+1, totals[i], f, want) }
+	}
+	// a collapse at the new level still alerts
+	totals = append(totals, 10)
+	if f := scanSeries(totals); !f[len(f)-1] { t.Error("collapse after re-baselining not flagged") }
+
+	// a dip that recovers does not shift the baseline
+	totals = append(repeat(1000, 10), 100, 100, 1000, 100, 100, 100, 1000, 100)
+	for i, f := range scanSeries(totals) {
+		if want := totals[i] == 100; f != want { t.Errorf("intermittent run %d: flagged %v, want %v", i+1, f, want) }
+	}
+}
+
+func TestSinceBaseline(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	runs := []RunRecord{{Seq: 1}, {Seq: 2}, {Seq: 3}}
+	if got := sinceBaseline("example.com", runs); len(got) != 3 { t.Errorf("without a reset: %d runs", len(got)) }
+	if err := updateState(func(st *SchedState) { st.domain("example.com").BaselineFrom = 3 }); err != nil { t.Fatal(err) }
+	if got := sinceBaseline("example.com", runs); len(got) != 1 || got[0].Seq != 3 { t.Errorf("after reset: %+v", got) }
+	if got := sinceBaseline("example.org", runs); len(got) != 3 { t.Errorf("other domain: %d runs", len(got)) }
+	if err := updateState(func(st *SchedState) { st.domain("example.com").BaselineFrom = 4 }); err != nil { t.Fatal(err) }
+	if got := sinceBaseline("example.com", runs); len(got) != 0 { t.Errorf("reset after the last run: %+v", got) }
+}
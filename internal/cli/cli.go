@@ -3,6 +3,7 @@ package cli
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,10 +11,14 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -24,65 +29,59 @@ const (
 )
 
 type Config struct {
-	DiscordWebhookURL string `json:"discord_webhook_url,omitempty"`
-	TelegramBotToken  string `json:"telegram_bot_token,omitempty"`
-	TelegramChatID    string `json:"telegram_chat_id,omitempty"`
-	OpenAIAPIKey      string `json:"openai_api_key,omitempty"`
+	DiscordWebhookURL string   `json:"discord_webhook_url,omitempty"`
+	TelegramBotToken  string   `json:"telegram_bot_token,omitempty"`
+	TelegramChatID    string   `json:"telegram_chat_id,omitempty"`
+	OpenAIAPIKey      string   `json:"openai_api_key,omitempty"`
+	// AI summaries (config set-ai); unset = OpenAI with its defaults
+	AIProvider        string   `json:"ai_provider,omitempty"` // openai, openai-compatible, anthropic, ollama
+	AIModel           string   `json:"ai_model,omitempty"`
+	AIEndpoint        string   `json:"ai_endpoint,omitempty"` // base URL
+	AITemperature     *float64 `json:"ai_temperature,omitempty"`
+	AIAPIKey          string   `json:"ai_api_key,omitempty"` // key of a provider other than openai
+	DiscordAISummary  string   `json:"discord_ai_summary,omitempty"`  // message (default), embed, off
+	TelegramAISummary string   `json:"telegram_ai_summary,omitempty"` // message (default), off
+	APIToken          string   `json:"api_token,omitempty"`  // bearer token for `domwatch serve`
+	// snapshot retention (config set-retention); 0 = keep all
+	SnapshotKeep      int      `json:"snapshot_keep,omitempty"`     // newest snapshots kept per domain
+	SnapshotMaxDays   int      `json:"snapshot_max_days,omitempty"` // age limit in days
 }
 
 func Run() int {
 	if len(os.Args) < 2 {
-		usage()
+		usage(os.Stderr)
 		return 2
 	}
-	switch os.Args[1] {
-	case "add":
-		return cmdAdd(os.Args[2:])
-	case "scan":
-		return cmdScan(os.Args[2:])
-	case "list":
-		return cmdList(os.Args[2:])
-	case "remove":
-		return cmdRemove(os.Args[2:])
-	case "config":
-		return cmdConfig(os.Args[2:])
-	case "notify-test":
-		return cmdNotifyTest(os.Args[2:])
-	case "setup":
-		return cmdSetup(os.Args[2:])
-	case "-h","--help","help":
-		usage()
-		return 0
-	default:
-		usage()
+	// SIGINT/SIGTERM cancel ctx: in-flight atomic writes finish, unsent
+	// notifications are spooled, and a second signal kills as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() { <-ctx.Done(); stop() }()
+	name := os.Args[1]
+	if name == "-h" || name == "--help" { name = "help" }
+	c, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\nRun 'domwatch help' for the list of commands.\n", name)
 		return 2
 	}
-}
-
-func usage() {
-	fmt.Println(` + "`" + `DomWatch ` + "`" + ` + Version + ` + "`" + ` — Subdomain monitor (new vs old) + Discord/Telegram + optional AI
-
-Usage:
-  domwatch add <domain>                          # add target & create storage
-  domwatch scan <domain> [--ai]                  # run subfinder, compare, write results, AI summary optional
-  domwatch scan --all [--ai]                     # scan all domains listed in domains.txt
-  domwatch list <domain>                         # print current inventory
-  domwatch remove <domain>                       # remove domain (data only; timers best-effort)
-  domwatch config [show|set-webhook|set-telegram|set-openai]
-  domwatch notify-test <domain>                  # send a test notification
-  domwatch setup                                 # guided setup (deps + notifiers)
-
-Env:
-  DOMWATCH_HOME           # base dir (default /opt/domwatch)
-  SUBFINDER_PATH          # custom path to subfinder binary
-  DISCORD_WEBHOOK_URL     # alt to config file value
-  TELEGRAM_BOT_TOKEN, TELEGRAM_CHAT_ID
-  OPENAI_API_KEY          # for --ai` + "`" + `)
+	code := c.exec(ctx, os.Args[2:])
+	// a command cut short by a signal exits 130 whatever it failed with
+	if code != 0 && ctx.Err() != nil { return ExitInterrupted }
+	return code
 }
 
 // ---------- paths/helpers ----------
 
 func homeDir() string {
+	homes.RLock()
+	dir := homes.dir
+	homes.RUnlock()
+	if dir != "" { return dir }
+	return envHome()
+}
+
+// envHome is $DOMWATCH_HOME, else DefaultHome.
+func envHome() string {
 	if v := strings.TrimSpace(os.Getenv("DOMWATCH_HOME")); v != "" {
 		return v
 	}
@@ -112,12 +111,22 @@ func readLines(p string) ([]string, error) {
 	return out, sc.Err()
 }
 func writeLines(p string, lines []string) error {
-	tmp := p + ".tmp"
+	var b strings.Builder
+	for _, l := range lines { b.WriteString(l); b.WriteByte('\n') }
+	return writeFileAtomic(p, []byte(b.String()), 0o644)
+}
+// writeFileAtomic writes b to a uniquely named temp file next to p and
+// renames it into place, so concurrent writers never share a temp file.
+func writeFileAtomic(p string, b []byte, perm os.FileMode) error {
 	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil { return err }
-	f, err := os.Create(tmp); if err != nil { return err }
-	defer f.Close()
-	for _, l := range lines { if _, err := io.WriteString(f, l+"\n"); err != nil { return err } }
-	return os.Rename(tmp, p)
+	f, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".*.tmp"); if err != nil { return err }
+	tmp := f.Name()
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil { err = cerr }
+	if err == nil { err = os.Chmod(tmp, perm) }
+	if err == nil { err = os.Rename(tmp, p) }
+	if err != nil { os.Remove(tmp) }
+	return err
 }
 func uniqueSorted(in []string) []string {
 	m := map[string]struct{}{}; for _, s := range in { s=strings.TrimSpace(s); if s!="" { m[s]=struct{}{} } }
@@ -138,11 +147,8 @@ func loadConfig() (*Config, error) {
 	return &c, nil
 }
 func saveConfig(c *Config) error {
-	if err := os.MkdirAll(homeDir(), 0o755); err != nil { return err }
 	b, _ := json.MarshalIndent(c,"","  ")
-	tmp := configPath()+".tmp"
-	if err := os.WriteFile(tmp, b, 0o600); err != nil { return err }
-	return os.Rename(tmp, configPath())
+	return writeFileAtomic(configPath(), b, 0o600)
 }
 
 func cleanWebhook(s string) string {
@@ -172,24 +178,57 @@ func ensureSubfinder() error {
 }
 
 // ---------- subfinder ----------
-func runSubfinder(domain string) ([]string, error) {
+// enumeration is what one subfinder run returned for a domain.
+type enumeration struct {
+	Hosts, Dropped []string
+	Sources        map[string]int // valid hosts per passive source
+}
+
+// runSubfinder asks for JSON lines with the collected sources of each host;
+// plain host lines (older subfinder builds) are accepted too.
+func runSubfinder(ctx context.Context, domain string) (enumeration, error) {
 	bin := strings.TrimSpace(os.Getenv("SUBFINDER_PATH"))
 	if bin == "" { bin = "subfinder" }
-	cmd := exec.Command(bin, "-silent", "-d", domain)
+	cmd := exec.CommandContext(ctx, bin, "-silent", "-json", "-cs", "-d", domain)
+	cmd.WaitDelay = 5*time.Second
 	out, err := cmd.Output()
 	if err != nil {
+		if ctx.Err()!=nil { return enumeration{}, fmt.Errorf("subfinder: %w", ctx.Err()) }
 		if ee, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("subfinder failed: %v\n%s", err, string(ee.Stderr))
+			return enumeration{}, fmt.Errorf("subfinder failed: %w\n%s", err, string(ee.Stderr))
 		}
-		return nil, err
+		return enumeration{}, err
 	}
 	var res []string
+	sources := map[string][]string{}
 	sc := bufio.NewScanner(bytes.NewReader(out))
 	for sc.Scan() {
 		line := strings.TrimSpace(sc.Text())
-		if line != "" { res = append(res, line) }
+		if line == "" { continue }
+		if line[0] == '{' {
+			var r struct {
+				Host    string   `json:"host"`
+				Source  string   `json:"source"`
+				Sources []string `json:"sources"`
+			}
+			if json.Unmarshal([]byte(line), &r) != nil || r.Host == "" { continue }
+			line = r.Host
+			if r.Source != "" { r.Sources = append(r.Sources, r.Source) }
+			sources[line] = append(sources[line], r.Sources...)
+		}
+		res = append(res, line)
+	}
+	e := enumeration{Sources: map[string]int{}}
+	e.Hosts, e.Dropped = normalizeResults(domain, res)
+	seen := map[string]bool{}
+	for raw, srcs := range sources {
+		h, err := normalizeHost(raw)
+		if err != nil || !underDomain(h, domain) { continue }
+		for _, src := range uniqueSorted(srcs) {
+			if !seen[src+" "+h] { seen[src+" "+h] = true; e.Sources[src]++ }
+		}
 	}
-	return uniqueSorted(res), nil
+	return e, nil
 }
 func diff(old, now []string) (added, existing []string) {
 	oldSet := map[string]struct{}{}; for _, s := range old { oldSet[s]=struct{}{} }
@@ -212,199 +251,380 @@ func getTelegram() (string,string) {
 	if c,_ := loadConfig(); c!=nil { return strings.TrimSpace(c.TelegramBotToken), strings.TrimSpace(c.TelegramChatID) }
 	return "",""
 }
-func postDiscord(webhook, title string, lines []string) error {
-	webhook = cleanWebhook(webhook)
-	if webhook=="" || len(lines)==0 { return nil }
-	const maxLen = 1800
-	header := title
-	cur := header+"\n"
+// Message limits, with room for Markdown: Discord allows 2000 characters per
+// message and 4096 per embed description, Telegram 4096.
+const (
+	discordMaxLen      = 1800
+	discordEmbedMaxLen = 4000
+	telegramMaxLen     = 3900
+)
+
+// chunkLines packs lines into messages of at most max bytes, each starting
+// with header. Lines longer than a message are split.
+func chunkLines(header string, lines []string, max int) []string {
+	room := max-len(header)-1
+	if room < 2 { header, room = "", max }
 	var chunks []string
+	cur := ""
 	for _, ln := range lines {
-		if len(cur)+len(ln)+1 > maxLen { chunks = append(chunks, cur); cur = header+"\n" }
+		for len(ln)+1 > room {
+			i := room-1
+			for i > 1 && !utf8.RuneStart(ln[i]) { i-- }
+			if cur!="" { chunks = append(chunks, cur) }
+			chunks = append(chunks, ln[:i]+"\n"); ln = ln[i:]; cur = ""
+		}
+		if cur!="" && len(cur)+len(ln)+1 > room { chunks = append(chunks, cur); cur = "" }
 		cur += ln+"\n"
 	}
 	if strings.TrimSpace(cur)!="" { chunks = append(chunks, cur) }
-	for _, msg := range chunks {
-		payload := map[string]any{"content":msg, "username":"DomWatch"}
-		b,_ := json.Marshal(payload)
-		req,_ := http.NewRequest("POST", webhook, bytes.NewReader(b))
-		req.Header.Set("Content-Type","application/json")
-		c := &http.Client{Timeout:15*time.Second}
-		resp, err := c.Do(req); if err!=nil { return err }
-		io.Copy(io.Discard, resp.Body); resp.Body.Close()
-		if resp.StatusCode>=300 { return fmt.Errorf("discord status %d", resp.StatusCode) }
-		time.Sleep(300*time.Millisecond)
-	}
-	return nil
+	for i := range chunks { if header!="" { chunks[i] = header+"\n"+chunks[i] } }
+	return chunks
 }
-func postTelegram(botToken, chatID, title string, lines []string) error {
-	if botToken=="" || chatID=="" || len(lines)==0 { return nil }
-	const maxLen = 3900
-	header := title+"\n"
-	cur := header
-	var chunks []string
-	for _, ln := range lines {
-		if len(cur)+len(ln)+1 > maxLen { chunks = append(chunks, cur); cur = header }
-		cur += ln+"\n"
-	}
-	if strings.TrimSpace(cur)!="" { chunks = append(chunks, cur) }
+// The post functions skip the first skip messages of a notification (sent
+// before it was spooled) and return how many of its messages have been
+// delivered, so a retry resumes after the last one that went out.
+func postDiscord(ctx context.Context, webhook, title string, lines []string, skip int) (int, error) {
+	webhook = cleanWebhook(webhook)
+	if webhook=="" || len(lines)==0 { return 0, nil }
+	return postChunks(ctx, chunkLines(title, lines, discordMaxLen), skip, func(msg string) error {
+		return postJSON(ctx, "discord", webhook, map[string]any{"content":msg, "username":"DomWatch"})
+	})
+}
+// postDiscordEmbed sends lines as the description of one embed per message.
+func postDiscordEmbed(ctx context.Context, webhook, title string, lines []string, skip int) (int, error) {
+	webhook = cleanWebhook(webhook)
+	if webhook=="" || len(lines)==0 { return 0, nil }
+	if len(title)>250 { title = title[:250]+"…" }
+	return postChunks(ctx, chunkLines("", lines, discordEmbedMaxLen), skip, func(desc string) error {
+		embed := map[string]any{"title":title, "description":desc, "color":0x5865F2}
+		return postJSON(ctx, "discord", webhook, map[string]any{"username":"DomWatch", "embeds":[]any{embed}})
+	})
+}
+// postTelegram sends title/lines; plain messages (AI output) are sent
+// without Markdown, which Telegram rejects when unbalanced.
+func postTelegram(ctx context.Context, botToken, chatID, title string, lines []string, plain bool, skip int) (int, error) {
+	if botToken=="" || chatID=="" || len(lines)==0 { return 0, nil }
 	api := "https://api.telegram.org/bot"+botToken+"/sendMessage"
-	for _, msg := range chunks {
-		payload := map[string]any{"chat_id":chatID,"text":msg,"parse_mode":"Markdown","disable_web_page_preview":true}
-		b,_ := json.Marshal(payload)
-		req,_ := http.NewRequest("POST", api, bytes.NewReader(b))
-		req.Header.Set("Content-Type","application/json")
-		c := &http.Client{Timeout:15*time.Second}
-		resp, err := c.Do(req); if err!=nil { return err }
-		io.Copy(io.Discard, resp.Body); resp.Body.Close()
-		if resp.StatusCode>=300 { return fmt.Errorf("telegram status %d", resp.StatusCode) }
-		time.Sleep(300*time.Millisecond)
+	return postChunks(ctx, chunkLines(title, lines, telegramMaxLen), skip, func(msg string) error {
+		payload := map[string]any{"chat_id":chatID,"text":msg,"disable_web_page_preview":true}
+		if !plain { payload["parse_mode"] = "Markdown" }
+		return postJSON(ctx, "telegram", api, payload)
+	})
+}
+// postChunks posts msgs[skip:] one at a time, pausing after each, and
+// returns how many of msgs have been delivered.
+func postChunks(ctx context.Context, msgs []string, skip int, post func(string) error) (int, error) {
+	if skip>len(msgs) { skip = len(msgs) }
+	sent := skip
+	for _, msg := range msgs[skip:] {
+		if err := post(msg); err!=nil { return sent, err }
+		sent++
+		if err := sleepCtx(ctx, 300*time.Millisecond); err!=nil && sent<len(msgs) { return sent, err }
 	}
+	return sent, nil
+}
+func postJSON(ctx context.Context, channel, u string, payload map[string]any) error {
+	b,_ := json.Marshal(payload)
+	req,_ := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(b))
+	req.Header.Set("Content-Type","application/json")
+	c := &http.Client{Timeout:15*time.Second}
+	resp, err := c.Do(req); if err!=nil { return err }
+	io.Copy(io.Discard, resp.Body); resp.Body.Close()
+	if resp.StatusCode>=300 { return fmt.Errorf("%s status %d", channel, resp.StatusCode) }
 	return nil
 }
 
-// ---------- AI (optional) ----------
-func getOpenAIKey() string {
-	if s := strings.TrimSpace(os.Getenv("OPENAI_API_KEY")); s!="" { return s }
-	if c,_ := loadConfig(); c!=nil { return strings.TrimSpace(c.OpenAIAPIKey) }
-	return ""
+// ---------- commands ----------
+func cmdAdd(ctx context.Context, a cmdArgs) int {
+	var domains []string
+	if f := a.str("from-hosts"); f!="" {
+		if len(a.args)>0 { return a.usage("give either a domain or --from-hosts") }
+		lines, err := readLines(f); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		roots, bad := rootsFromHosts(lines)
+		for _, b := range bad { fmt.Fprintln(os.Stderr, "skipped:", b) }
+		fmt.Printf("derived %d root domain(s) from %d host(s)\n", len(roots), len(lines))
+		domains = roots
+	} else {
+		if len(a.args)<1 { return a.usage("missing domain") }
+		domain, err := checkNewDomain(a.args[0], a.bool("force"), a.bool("registrable"), os.Stderr)
+		if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 2 }
+		domains = []string{domain}
+	}
+	opts := AddOptions{Program: a.str("program"), Tags: a.list("tag"), Force: a.bool("force"), Registrable: a.bool("registrable")}
+	m := cliMonitor(""); m.opts.Output = io.Discard // notes were printed above
+	for _, domain := range domains {
+		_, created, err := m.AddDomain(ctx, domain, opts); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		if created { fmt.Println("added:", domain) } else { fmt.Println("already exists:", domain) }
+	}
+	return 0
 }
-func aiSummary(domain string, newSubs []string) (string, error) {
-	key := getOpenAIKey()
-	if key=="" { return "", nil }
-	if len(newSubs)==0 { return "No new subdomains found.", nil }
-	userMsg := fmt.Sprintf("Domain: %s\nNew subdomains (%d):\n- %s\n\nTask: 1) Group by obvious services (auth, api, dev, staging, admin, cdn, mail, vpn, grafana, kibana, git, test). 2) Flag likely high-value targets. 3) Suggest next checks (httpx, tls certs, title, tech stack, weak DNS). Output in concise bullets.", domain, len(newSubs), strings.Join(newSubs, "\n- "))
-	payload := map[string]any{
-		"model": "gpt-4o-mini",
-		"messages": []map[string]string{
-			{"role":"system","content":"You are a security assistant. Be concise and actionable."},
-			{"role":"user","content":userMsg},
-		},
-		"temperature": 0.2,
-	}
-	body,_ := json.Marshal(payload)
-	req,_ := http.NewRequest("POST","https://api.openai.com/v1/chat/completions", bytes.NewReader(body))
-	req.Header.Set("Authorization","Bearer "+key)
-	req.Header.Set("Content-Type","application/json")
-	c := &http.Client{Timeout:30*time.Second}
-	resp, err := c.Do(req); if err!=nil { return "", err }
-	defer resp.Body.Close()
-	if resp.StatusCode==429 { io.Copy(io.Discard, resp.Body); return "", nil }
-	if resp.StatusCode>=300 { b,_ := io.ReadAll(resp.Body); return "", fmt.Errorf("OpenAI error: %s\n%s", resp.Status, string(b)) }
-	var parsed struct {
-		Choices []struct {
-			Message struct {
-				Content string ` + "`json:\"content\"`" + `
-			} ` + "`json:\"message\"`" + `
-		} ` + "`json:\"choices\"`" + `
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err!=nil { return "", err }
-	if len(parsed.Choices)==0 { return "", errors.New("no AI choices returned") }
-	return strings.TrimSpace(parsed.Choices[0].Message.Content), nil
+
+// checkNewDomain normalizes a domain to add. Public suffixes are refused
+// unless force; with registrable a subdomain is replaced by its eTLD+1.
+func checkNewDomain(s string, force, registrable bool, errw io.Writer) (string, error) {
+	domain, err := normalizeDomain(s); if err!=nil { return "", &domainError{err} }
+	if isPublicSuffix(domain) && !force {
+		return "", &domainError{fmt.Errorf("%s is a public suffix, not a registrable domain (use --force to add anyway)", domain)}
+	}
+	if rd, err := registrableDomain(domain); err==nil && rd!=domain {
+		if registrable { return rd, nil }
+		fmt.Fprintf(errw, "note: %s is under registrable domain %s (use --registrable to monitor that instead)\n", domain, rd)
+	}
+	return domain, nil
 }
 
-// ---------- commands ----------
-func cmdAdd(args []string) int {
-	if len(args)<1 { fmt.Println("usage: domwatch add <domain>"); return 2 }
-	domain := strings.ToLower(args[0])
-	if err := ensureDirs(); err!=nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+// domainError is an argument that cannot be monitored as a root domain (a
+// 400 over the API).
+type domainError struct{ err error }
+
+func (e *domainError) Error() string { return e.err.Error() }
+func (e *domainError) Unwrap() error { return e.err }
+
+// addDomain creates storage for domain and lists it in domains.txt.
+// It reports whether the data file was newly created.
+func addDomain(domain string) (bool, error) {
+	if err := ensureDirs(); err!=nil { return false, err }
+	created := false
 	p := filepath.Join(dataDir(), domain+".txt")
 	if _, err := os.Stat(p); os.IsNotExist(err) {
-		if err := writeLines(p, []string{}); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
-		fmt.Println("added:", domain)
-	} else {
-		fmt.Println("already exists:", domain)
+		if err := writeLines(p, []string{}); err!=nil { return false, err }
+		created = true
 	}
 	df := filepath.Join(homeDir(), "domains.txt")
 	old, _ := readLines(df)
 	has := false
 	for _, d := range old { if strings.EqualFold(strings.TrimSpace(d), domain) { has = true; break } }
 	if !has { old = append(old, domain); old = uniqueSorted(old); _ = writeLines(df, old) }
-	return 0
+	return created, nil
+}
+
+// scanResult summarizes one scanOne call.
+type scanResult struct {
+	Domain                 string
+	Started                time.Time
+	Run                    int // number in the run history
+	Total, New, OutOfScope int
+	NewHosts, OutOfScopeHosts []string
+	Anomalies              []string // see detectAnomalies
+	AISummary              string
 }
 
-func scanOne(domain string, withAI bool) (int, error) {
-	if err := ensureDirs(); err!=nil { return 0, err }
-	oldList, err := readLines(filepath.Join(dataDir(), domain+".txt")); if err!=nil { return 0, err }
-	nowList, err := runSubfinder(domain); if err!=nil { return 0, err }
-	added, _ := diff(oldList, nowList)
+// scanOne scans a single domain. Output goes to out/errw so concurrent
+// scans can buffer it and print whole blocks. timeout bounds enumeration
+// only; once results are written, notifications run on the parent ctx.
+func scanOne(ctx context.Context, domain string, opts scanOptions, out, errw io.Writer) (res scanResult, err error) {
+	res = scanResult{Domain: domain, Started: time.Now()}
+	if err := ensureDirs(); err!=nil { return res, err }
+	l, err := acquireLock(ctx, domainLockName(domain), opts.LockWait, errw); if err!=nil { return res, err }
+	defer l.unlock()
+	run := &RunRecord{Domain: domain, Trigger: opts.Trigger, Start: res.Started}
+	if run.Seq, err = nextRunSeq(domain); err!=nil { return res, err }
+	res.Run = run.Seq
+	emit(errw, opts.OnEvent, Event{Type: EventScanStarted, Time: res.Started, Domain: domain, Run: run.Seq, Trigger: opts.Trigger})
+	defer func() {
+		run.finish(ctx, err)
+		if e := appendRun(run); e!=nil { fmt.Fprintln(errw, "error: run history:", e) }
+		done := Event{Type: EventScanFinished, Domain: domain, Run: run.Seq, Status: run.Status, Total: run.Total, New: res.New}
+		if err!=nil { done.Error = firstLine(err.Error()) }
+		emit(errw, opts.OnEvent, done)
+	}()
+	ectx, cancel := ctx, context.CancelFunc(func() {})
+	if opts.Timeout>0 { ectx, cancel = context.WithTimeout(ctx, opts.Timeout) }
+	enum, err := runSubfinder(ectx, domain)
+	cancel()
+	if err!=nil { return res, err }
+	nowList := enum.Hosts
+	run.Sources, run.Returned, run.Dropped = enum.Sources, len(nowList), len(enum.Dropped)
+	warnDropped(errw, domain, enum.Dropped)
+	if prev, err := loadRuns(domain); err==nil { run.Anomalies = detectAnomalies(run, sinceBaseline(domain, prev)) }
+	res.Anomalies = run.Anomalies
+	storeMu.Lock()
+	oldList, err := readLines(filepath.Join(dataDir(), domain+".txt")); if err!=nil { storeMu.Unlock(); return res, err }
+	added, existing := diff(oldList, nowList)
+	run.Added, run.Removed = len(added), len(oldList)-len(existing)
 	merged := uniqueSorted(append(oldList, nowList...))
-	if err := writeLines(filepath.Join(dataDir(), domain+".txt"), merged); err!=nil { return 0, err }
+	if err := writeLines(filepath.Join(dataDir(), domain+".txt"), merged); err!=nil { storeMu.Unlock(); return res, err }
 	if len(added)>0 {
 		lastNew := filepath.Join(dataDir(), fmt.Sprintf("%s_new_%d.txt", domain, time.Now().Unix()))
 		_ = writeLines(lastNew, added)
 	}
-	fmt.Printf("Scan %s -> total:%d (new:%d, old:%d)\n", domain, len(merged), len(added), len(merged)-len(added))
-	for _, s := range added { fmt.Println("[NEW]", s) }
+	storeMu.Unlock()
+	var records map[string]resolution
+	if opts.Resolve { records = resolveHosts(ctx, nowList) }
+	prevSnap := latestSnapshot(domain)
+	if err := writeSnapshot(domain, run.Seq, res.Started, nowList, records); err!=nil { fmt.Fprintln(errw, "error: snapshot:", err) }
+	if cfg, _ := loadConfig(); cfg!=nil {
+		if _, err := pruneSnapshots(domain, cfg.SnapshotKeep, cfg.SnapshotMaxDays, time.Now()); err!=nil { fmt.Fprintln(errw, "error: snapshot retention:", err) }
+	}
+	fmt.Fprintf(out, "Scan %s -> total:%d (new:%d, old:%d)\n", domain, len(merged), len(added), len(merged)-len(added))
+	// out-of-scope hosts stay in the inventory but never alert
+	added, oos := splitScopeAddrs(domain, added, resolveForScope(ctx, domain, added, records))
+	for _, s := range added { fmt.Fprintln(out, "[NEW]", hostLabel(s)) }
+	for _, s := range oos { fmt.Fprintln(out, "[NEW][OOS]", hostLabel(s)) }
+	res.Total, res.New, res.OutOfScope = len(merged), len(added), len(oos)
+	res.NewHosts, res.OutOfScopeHosts = added, oos
+	run.Total, run.OutOfScope = len(merged), len(oos)
+	emit(errw, opts.OnEvent, scanEvents(domain, run.Seq, added, oos, prevSnap, newSnapshot(run.Seq, res.Started, nowList, records))...)
 
 	// notify
 	if len(added)>0 {
 		title := fmt.Sprintf("🆕 New subdomains for **%s** (%d) — %s", domain, len(added), time.Now().Format(time.RFC3339))
-		var lines []string; for _, s := range added { lines = append(lines, "- `"+s+"`") }
-		if d := getDiscordWebhook(); d!="" { if err := postDiscord(d, title, lines); err!=nil { fmt.Fprintln(os.Stderr,"Discord notify error:", err) } }
-		if tb, tc := getTelegram(); tb!="" && tc!="" { if err := postTelegram(tb, tc, title, lines); err!=nil { fmt.Fprintln(os.Stderr,"Telegram notify error:", err) } }
+		var lines []string; for _, s := range added { lines = append(lines, "- `"+hostLabel(s)+"`") }
+		notify(ctx, errw, title, lines)
+	}
+	// a collapse in results is an operator problem, not a quiet day
+	if len(run.Anomalies)>0 {
+		for _, a := range run.Anomalies { fmt.Fprintln(errw, "warning:", a) }
+		title := fmt.Sprintf("⚠️ DomWatch enumeration anomaly for **%s** — %s", domain, time.Now().Format(time.RFC3339))
+		var lines []string; for _, a := range run.Anomalies { lines = append(lines, "- "+a) }
+		lines = append(lines, "- check subfinder's provider API keys; see `domwatch history "+domain+"`")
+		notify(ctx, errw, title, lines)
 	}
+	// hooks get the in-scope new hosts, like the notification
+	if len(added)>0 && ctx.Err()==nil { run.Hooks = runHooks(ctx, domain, strconv.Itoa(run.Seq), added, out, errw) }
 
-	if withAI {
-		if summary, err := aiSummary(domain, added); err==nil && strings.TrimSpace(summary)!="" {
-			fmt.Println("\n=== AI Summary ===")
-			fmt.Println(summary)
+	if opts.WithAI && ctx.Err()==nil {
+		if summary, err := aiSummary(ctx, domain, added); err==nil && strings.TrimSpace(summary)!="" {
+			res.AISummary = strings.TrimSpace(summary)
+			run.AISummary = res.AISummary
+			fmt.Fprintln(out, "\n=== AI Summary ===")
+			fmt.Fprintln(out, summary)
+			if len(added)>0 { notifyAISummary(ctx, errw, domain, len(added), res.AISummary) }
+		} else if err!=nil {
+			fmt.Fprintf(errw, "%s: AI summary failed: %v\n", domain, err)
 		}
 	}
-	return len(added), nil
+	return res, nil
 }
 
-func cmdScan(args []string) int {
-	if len(args)<1 { fmt.Println("usage: domwatch scan <domain>|--all [--ai]"); return 2 }
-	withAI := false
+func cmdScan(ctx context.Context, a cmdArgs) int {
+	format, err := outputFlag(a); if err!=nil { return a.usage(err.Error()) }
+	// with structured output stdout carries only the records
+	info := io.Writer(os.Stdout); if format!=formatText { info = os.Stderr }
+	mon := cliMonitor("scan")
+	mon.opts.Timeout, mon.opts.Resolve, mon.opts.AI = a.duration("timeout"), a.bool("resolve"), a.bool("ai")
+	opts := scanOptions{Workers: a.int("concurrency"), FailFast: a.bool("fail-fast")}
+	if opts.Workers<1 { return a.usage("--concurrency must be a positive integer") }
+	if mon.opts.Timeout<0 { return a.usage("--timeout must not be negative (0 = none)") }
+	runCtx := ctx
+	if d := a.duration("global-timeout"); d!=0 {
+		if d<0 { return a.usage("--global-timeout must be a positive duration like 4h") }
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
 	var domains []string
-	for _, a := range args {
-		if a=="--ai" { withAI = true; continue }
-		if a=="--all" {
-			list, _ := readLines(filepath.Join(homeDir(),"domains.txt"))
-			if len(list)==0 { fmt.Println("no domains in domains.txt; add with: domwatch add example.com"); return 2 }
-			domains = append(domains, list...)
+	if prog, tag := a.str("program"), a.str("tag"); a.bool("all") || prog!="" || tag!="" {
+		if len(a.args)>0 { return a.usage("give either a domain or --all/--program/--tag") }
+		list, err := selectDomains(prog, tag); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		if len(list)==0 && prog=="" && tag=="" { fmt.Fprintln(info,"no domains in domains.txt; add with: domwatch add example.com"); return 2 }
+		if len(list)==0 { fmt.Fprintln(info,"no domains match the given --program/--tag"); return 2 }
+		// only domains that are due by their own interval, unless --force
+		due, paused, notDue, err := dueDomains(uniqueSorted(list), DefaultScanInterval, a.bool("force"))
+		if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		if paused+notDue>0 { fmt.Fprintf(info, "skipping %d paused and %d not-yet-due domain(s) (use --force to scan them)\n", paused, notDue) }
+		if len(due)==0 {
+			fmt.Fprintln(info, "nothing due")
+			if format!=formatText { writeScanRecords(format, nil) }
+			return 0
 		}
+		domains = append(domains, due...)
+	}
+	if len(domains)==0 && len(a.args)>0 {
+		d, err := domainArg(a, 0); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 2 }
+		domains = []string{d}
 	}
-	if len(domains)==0 && !strings.HasPrefix(args[0],"--") { domains = []string{args[0]} }
-	if len(domains)==0 { fmt.Println("usage: domwatch scan <domain>|--all [--ai]"); return 2 }
-	totalNew := 0
+	if len(domains)==0 { return a.usage("give a domain or --all/--program/--tag") }
 	if err := ensureSubfinder(); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
-	for _, d := range domains {
-		n, err := scanOne(strings.ToLower(d), withAI); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
-		totalNew += n
+	if n := flushPending(ctx, os.Stderr); n>0 { fmt.Fprintf(info, "delivered %d pending notification(s)\n", n) }
+	var recs []ScanRecord
+	if format!=formatText {
+		opts.Quiet = true
+		opts.OnDone = func(d string, r scanResult, err error) {
+			rec := newScanRecord(d, r, err)
+			// JSON lines stream as domains finish
+			if format==formatJSONL { writeScanRecords(format, []ScanRecord{rec}); return }
+			recs = append(recs, rec)
+		}
 	}
-	if totalNew==0 { fmt.Println("No new subdomains detected.") }
-	return 0
+	sum := mon.scanDomains(runCtx, domains, opts)
+	if format!=formatText {
+		skipped := skippedRecords(sum)
+		if format==formatJSONL { writeScanRecords(format, skipped) } else { writeScanRecords(format, append(recs, skipped...)) }
+	}
+	if ctx.Err()!=nil {
+		fmt.Fprintf(os.Stderr, "interrupted: %d of %d domain(s) completed\n", sum.Domains-sum.Failed, len(domains))
+		return ExitInterrupted
+	}
+	if a.bool("notify-failures") { notifyFailures(ctx, sum, len(domains)) }
+	switch {
+	case format!=formatText:
+	case sum.Anomalous>0:
+		fmt.Printf("%d domain(s) returned far fewer hosts than usual; check the warnings above.\n", sum.Anomalous)
+	case sum.New==0 && sum.Failed<sum.Domains:
+		fmt.Println("No new subdomains detected.")
+	}
+	return sum.exitCode()
 }
 
-func cmdList(args []string) int {
-	if len(args)<1 { fmt.Println("usage: domwatch list <domain>"); return 2 }
-	domain := strings.ToLower(args[0])
+func cmdList(ctx context.Context, a cmdArgs) int {
+	format, err := outputFlag(a); if err!=nil { return a.usage(err.Error()) }
+	if prog, tag := a.str("program"), a.str("tag"); prog!="" || tag!="" {
+		if len(a.args)>0 { return a.usage("give either a domain or --program/--tag") }
+		// no domain: list the monitored domains in a program/tag instead
+		ds, err := selectDomains(prog, tag); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		if format!=formatText { return listDomainRecords(format, ds) }
+		for _, d := range ds { fmt.Println(d) }
+		return 0
+	}
+	if a.bool("in-scope") && a.bool("out-of-scope") { return a.usage("use only one of --in-scope and --out-of-scope") }
+	if len(a.args)==0 { return a.usage("give a domain or --program/--tag") }
+	domain, err := domainArg(a, 0); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 2 }
 	lines, err := readLines(filepath.Join(dataDir(), domain+".txt")); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+	if a.bool("in-scope") || a.bool("out-of-scope") {
+		in, out := splitScope(domain, lines)
+		if a.bool("in-scope") { lines = in } else { lines = out }
+	}
+	if format!=formatText { return listHostRecords(format, domain, lines) }
 	for _, s := range lines { fmt.Println(s) }
 	return 0
 }
 
-func cmdRemove(args []string) int {
-	if len(args)<1 { fmt.Println("usage: domwatch remove <domain>"); return 2 }
-	domain := strings.ToLower(args[0])
+func cmdRemove(ctx context.Context, a cmdArgs) int {
+	domain, err := domainArg(a, 0); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 2 }
+	if err := removeDomain(ctx, domain, lockWait, os.Stderr); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+	fmt.Println("removed:", domain)
+	return 0
+}
+
+// removeDomain deletes domain and everything stored for it once no scan of
+// it is running. The caller holds the home lock.
+func removeDomain(ctx context.Context, domain string, wait time.Duration, errw io.Writer) error {
+	l, err := acquireLock(ctx, domainLockName(domain), wait, errw); if err!=nil { return err }
+	defer l.unlock()
 	df := filepath.Join(homeDir(),"domains.txt")
 	lines, _ := readLines(df)
 	var kept []string; for _, d := range lines { if !strings.EqualFold(strings.TrimSpace(d), domain) { kept = append(kept, d) } }
 	_ = writeLines(df, uniqueSorted(kept))
+	if scopes, err := loadScopes(); err==nil && scopes[domain]!=nil { delete(scopes, domain); _ = saveScopes(scopes) }
+	if reg, err := loadRegistry(); err==nil && reg.Targets[domain]!=nil { delete(reg.Targets, domain); _ = saveRegistry(reg) }
+	if err := updateState(func(st *SchedState) { delete(st.Domains, domain) }); err!=nil { fmt.Fprintln(errw, "state error:", err) }
 	_ = os.Remove(filepath.Join(dataDir(), domain+".txt"))
+	_ = os.Remove(runsPath(domain))
+	_ = os.RemoveAll(snapshotsDir(domain))
 	entries, _ := os.ReadDir(dataDir())
 	for _, e := range entries {
 		name := e.Name()
 		if strings.HasPrefix(name, domain+"_new_") && strings.HasSuffix(name, ".txt") { _ = os.Remove(filepath.Join(dataDir(), name)) }
 	}
-	fmt.Println("removed:", domain)
-	return 0
+	return nil
 }
 
-func cmdConfig(args []string) int {
-	if len(args)==0 || args[0]=="show" {
+func cmdConfig(ctx context.Context, a cmdArgs) int {
+	switch a.sub {
+	case "show":
+		format, err := outputFlag(a); if err!=nil { return a.usage(err.Error()) }
+		if format!=formatText { return configRecords(format) }
 		cfg,_ := loadConfig()
 		fmt.Println("Home:", homeDir())
 		fmt.Println("Config:", configPath())
@@ -412,31 +632,63 @@ func cmdConfig(args []string) int {
 		fmt.Println("telegram_bot_token :", mask(cfg.TelegramBotToken))
 		fmt.Println("telegram_chat_id   :", mask(cfg.TelegramChatID))
 		fmt.Println("openai_api_key     :", mask(cfg.OpenAIAPIKey))
-		return 0
-	}
-	switch args[0] {
+		if ai, err := resolveAI(cfg); err!=nil { fmt.Println("ai                 :", err) } else {
+			fmt.Printf("ai                 : %s, model %s, temperature %g, %s\n", ai.Provider, ai.Model, ai.Temperature, ai.Endpoint)
+			fmt.Println("ai_api_key         :", mask(cfg.AIAPIKey))
+			fmt.Printf("ai summaries       : discord %s, telegram %s\n", aiNotifyMode(cfg, "discord"), aiNotifyMode(cfg, "telegram"))
+		}
+		fmt.Println("api_token          :", mask(cfg.APIToken))
+		fmt.Println("snapshot retention :", retentionText(cfg))
 	case "set-webhook":
-		if len(args)<2 { fmt.Println("usage: domwatch config set-webhook <discord_url>"); return 2 }
-		cfg,_ := loadConfig(); u := cleanWebhook(args[1]); if u=="" { fmt.Println("invalid webhook URL"); return 2 }
+		cfg,_ := loadConfig(); u := cleanWebhook(a.args[0]); if u=="" { return a.usage("invalid webhook URL") }
 		cfg.DiscordWebhookURL=u; if err:=saveConfig(cfg); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
 		fmt.Println("Saved webhook to", configPath())
 	case "set-telegram":
-		if len(args)<3 { fmt.Println("usage: domwatch config set-telegram <bot_token> <chat_id>"); return 2 }
-		cfg,_ := loadConfig(); cfg.TelegramBotToken=strings.TrimSpace(args[1]); cfg.TelegramChatID=strings.TrimSpace(args[2]); if err:=saveConfig(cfg); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		cfg,_ := loadConfig(); cfg.TelegramBotToken=strings.TrimSpace(a.args[0]); cfg.TelegramChatID=strings.TrimSpace(a.args[1]); if err:=saveConfig(cfg); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
 		fmt.Println("Saved Telegram settings to", configPath())
 	case "set-openai":
-		if len(args)<2 { fmt.Println("usage: domwatch config set-openai <key>"); return 2 }
-		cfg,_ := loadConfig(); cfg.OpenAIAPIKey=strings.TrimSpace(args[1]); if err:=saveConfig(cfg); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		cfg,_ := loadConfig(); cfg.OpenAIAPIKey=strings.TrimSpace(a.args[0]); if err:=saveConfig(cfg); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
 		fmt.Println("Saved API key to", configPath())
-	default:
-		fmt.Println("usage: domwatch config [show|set-webhook <discord_url>|set-telegram <bot> <chat>|set-openai <key>]"); return 2
+	case "set-ai":
+		cfg, err := loadConfig(); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		var temp *float64
+		if a.isSet("temperature") { t := a.float("temperature"); temp = &t }
+		if err := setAI(cfg, strings.TrimSpace(a.args[0]), a.str("model"), a.str("endpoint"), a.str("key"), temp); err!=nil { return a.usage(err.Error()) }
+		if err := saveConfig(cfg); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		ai, _ := resolveAI(cfg)
+		fmt.Printf("Saved AI provider %s (model %s, %s) to %s\n", ai.Provider, ai.Model, ai.Endpoint, configPath())
+		if ai.backend.needsKey && ai.Key=="" { fmt.Printf("note: set a key with --key or %s\n", ai.backend.keyEnv) }
+	case "set-ai-notify":
+		ch, mode := strings.ToLower(a.args[0]), strings.ToLower(a.args[1])
+		modes, ok := aiNotifyModes[ch]; if !ok { return a.usage(fmt.Sprintf("unknown channel %q (discord, telegram)", ch)) }
+		ok = false; for _, m := range modes { ok = ok || m==mode }
+		if !ok { return a.usage(fmt.Sprintf("%s takes %s", ch, strings.Join(modes, ", "))) }
+		cfg, err := loadConfig(); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		if ch=="discord" { cfg.DiscordAISummary = mode } else { cfg.TelegramAISummary = mode }
+		if err := saveConfig(cfg); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		fmt.Printf("AI summaries to %s: %s (saved to %s)\n", channelNames[ch], mode, configPath())
+	case "set-retention":
+		keep, days := a.int("keep"), a.int("days")
+		if keep<0 || days<0 { return a.usage("--keep and --days must not be negative (0 = no limit)") }
+		cfg, err := loadConfig(); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		if a.isSet("keep") { cfg.SnapshotKeep = keep }
+		if a.isSet("days") { cfg.SnapshotMaxDays = days }
+		if err := saveConfig(cfg); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		fmt.Printf("Snapshot retention: %s (saved to %s; applied after each scan)\n", retentionText(cfg), configPath())
+	case "set-api-token":
+		cfg,_ := loadConfig()
+		tok := ""
+		if len(a.args)>0 { tok = strings.TrimSpace(a.args[0]) } else { tok = newAPIToken(); fmt.Println("API token:", tok) }
+		if len(tok)<16 { return a.usage("API token must be at least 16 characters") }
+		cfg.APIToken=tok; if err:=saveConfig(cfg); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
+		fmt.Println("Saved API token to", configPath())
 	}
 	return 0
 }
 
-func cmdNotifyTest(args []string) int {
-	if len(args)<1 { fmt.Println("usage: domwatch notify-test <domain>"); return 2 }
-	domain := strings.ToLower(args[0])
+// latestNewHosts returns the hosts of the domain's latest scan that found
+// any, or the first ten of its inventory.
+func latestNewHosts(domain string) []string {
 	pattern := domain+"_new_"
 	var newest string; var newestTS int64
 	entries, _ := os.ReadDir(dataDir())
@@ -447,21 +699,24 @@ func cmdNotifyTest(args []string) int {
 			if ts > newestTS { newestTS = ts; newest = filepath.Join(dataDir(), name) }
 		}
 	}
-	var subs []string
-	if newest != "" { subs,_ = readLines(newest) } else {
-		all,_ := readLines(filepath.Join(dataDir(), domain+".txt"))
-		if len(all)>10 { subs = all[:10] } else { subs = all }
-	}
+	if newest != "" { subs,_ := readLines(newest); return subs }
+	all,_ := readLines(filepath.Join(dataDir(), domain+".txt"))
+	if len(all)>10 { return all[:10] }
+	return all
+}
+
+func cmdNotifyTest(ctx context.Context, a cmdArgs) int {
+	domain, err := domainArg(a, 0); if err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 2 }
+	subs := latestNewHosts(domain)
 	if len(subs)==0 { fmt.Println("nothing to send"); return 0 }
 	title := fmt.Sprintf("🔔 DomWatch test for **%s** — %s", domain, time.Now().Format(time.RFC3339))
 	var lines []string; for _, s := range subs { lines = append(lines, "- `"+s+"`") }
-	if d:=getDiscordWebhook(); d!="" { if err:=postDiscord(d,title,lines); err!=nil { fmt.Fprintln(os.Stderr,"Discord notify error:",err) } }
-	if tb,tc := getTelegram(); tb!="" && tc!="" { if err:=postTelegram(tb,tc,title,lines); err!=nil { fmt.Fprintln(os.Stderr,"Telegram notify error:",err) } }
+	notify(ctx, os.Stderr, title, lines)
 	fmt.Println("sent test notification")
 	return 0
 }
 
-func cmdSetup(args []string) int {
+func cmdSetup(ctx context.Context, a cmdArgs) int {
 	fmt.Println("== DomWatch setup ==")
 	if err := ensureDirs(); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
 	if err := ensureSubfinder(); err!=nil { fmt.Fprintln(os.Stderr,"error:",err); return 1 }
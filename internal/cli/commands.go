@@ -0,0 +1,449 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Commands are declared in one table. Each gets its own flag.FlagSet,
+// which rejects unknown flags, missing values and malformed numbers and
+// durations before the command runs, and which `help <command>` and the
+// shell completions are generated from. Flags may come before, between or
+// after the positional arguments. Commands with subcommands (scope,
+// program, ...) list them in subs; a subcommand without a run func uses its
+// parent's, which reads cmdArgs.sub.
+type command struct {
+	name     string
+	synopsis string // arguments after "domwatch <name>"; one line per form
+	summary  string
+	flags    func(fs *flag.FlagSet)
+	// positional arguments; maxArgs -1 = any number
+	minArgs, maxArgs int
+	subs             []command // subcommands, chosen by the first argument
+	defaultSub       string    // run when the first argument is not a subcommand
+	complete         string    // positional completion: "domains", "files" or ""
+	raw              bool      // arguments are not parsed as flags (tag's -tag)
+	locked           bool      // runs under the home lock
+	hidden           bool
+	run              func(ctx context.Context, a cmdArgs) int
+}
+
+// cmdArgs is a parsed command line: the subcommand, the positional
+// arguments and the command's flag set.
+type cmdArgs struct {
+	cmd  command
+	sub  string
+	args []string
+	fs   *flag.FlagSet
+}
+
+func (a cmdArgs) value(name string) any      { return a.fs.Lookup(name).Value.(flag.Getter).Get() }
+func (a cmdArgs) str(name string) string     { return a.value(name).(string) }
+func (a cmdArgs) bool(name string) bool      { return a.value(name).(bool) }
+func (a cmdArgs) int(name string) int        { return a.value(name).(int) }
+func (a cmdArgs) float(name string) float64  { return a.value(name).(float64) }
+func (a cmdArgs) list(name string) []string  { return a.value(name).([]string) }
+func (a cmdArgs) duration(name string) time.Duration { return a.value(name).(time.Duration) }
+
+// isSet reports whether flag name was given on the command line.
+func (a cmdArgs) isSet(name string) bool {
+	set := false
+	a.fs.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// usage reports a usage error with the command's synopsis and returns 2.
+func (a cmdArgs) usage(msg string) int { return a.cmd.usageError(msg) }
+
+func commands() []command {
+	return []command{
+		{name: "add", synopsis: "<domain> [--program <name>] [--tag <tag>]... [--registrable] [--force]\n--from-hosts <file> [--program <name>] [--tag <tag>]...",
+			summary: "add a root domain and create its storage", maxArgs: 1, locked: true,
+			flags: func(fs *flag.FlagSet) {
+				fs.String("program", "", "put the domain(s) in program `name`")
+				fs.Var(&listFlag{}, "tag", "tag the domain(s) with `tag` (repeatable, comma-separated)")
+				fs.String("from-hosts", "", "derive and add the registrable domains of the hosts in `file`")
+				fs.Bool("registrable", false, "monitor the registrable domain (eTLD+1) of a subdomain instead")
+				fs.Bool("force", false, "add a public suffix anyway")
+			},
+			run: cmdAdd},
+		{name: "scan", synopsis: "<domain> | --all | --program <name> | --tag <tag> [flags]",
+			summary: "run subfinder, store new hosts, notify; --all scans the due, unpaused domains", maxArgs: 1, complete: "domains",
+			flags: func(fs *flag.FlagSet) {
+				fs.Bool("all", false, "scan every due, unpaused domain")
+				fs.String("program", "", "scan the due domains of program `name`")
+				fs.String("tag", "", "scan the due domains tagged `tag`")
+				fs.Bool("force", false, "with --all/--program/--tag: ignore intervals")
+				fs.Bool("ai", false, "summarize new hosts with AI")
+				fs.Bool("resolve", false, "store the addresses of returned hosts in the snapshot")
+				fs.Int("concurrency", 1, "scan `N` domains at a time")
+				fs.Bool("fail-fast", false, "stop at the first failed domain")
+				fs.Bool("notify-failures", false, "send the failed-domain summary to the notifiers")
+				fs.Duration("timeout", DefaultDomainTimeout, "per-domain enumeration limit (0 = none)")
+				fs.Duration("global-timeout", 0, "limit for the whole run")
+				outputFlags(fs)
+			},
+			run: cmdScan},
+		{name: "list", synopsis: "<domain> [--in-scope | --out-of-scope]\n--program <name> | --tag <tag>",
+			summary: "print a domain's inventory, or the domains of a program/tag", maxArgs: 1, complete: "domains",
+			flags: func(fs *flag.FlagSet) {
+				fs.Bool("in-scope", false, "only in-scope hosts")
+				fs.Bool("out-of-scope", false, "only out-of-scope hosts")
+				fs.String("program", "", "list the domains of program `name`")
+				fs.String("tag", "", "list the domains tagged `tag`")
+				outputFlags(fs)
+			},
+			run: cmdList},
+		{name: "remove", synopsis: "<domain>", summary: "stop monitoring a domain and delete its data", minArgs: 1, maxArgs: 1, complete: "domains", locked: true,
+			run: cmdRemove},
+		{name: "scope", summary: "per-domain scope rules (hosts, *.wildcards, re:regex or /regex/, IPs, CIDRs)", complete: "domains", locked: true,
+			subs: []command{
+				{name: "show", synopsis: "<domain>", summary: "list the include/exclude rules", minArgs: 1, maxArgs: 1},
+				{name: "include", synopsis: "<domain> <rule>...", summary: "add include rules", minArgs: 2, maxArgs: -1},
+				{name: "exclude", synopsis: "<domain> <rule>...", summary: "add exclude rules", minArgs: 2, maxArgs: -1},
+				{name: "rm", synopsis: "<domain> <rule>...", summary: "remove rules from either list", minArgs: 2, maxArgs: -1},
+				{name: "clear", synopsis: "<domain>", summary: "drop all rules (everything in scope)", minArgs: 1, maxArgs: 1},
+				{name: "check", synopsis: "<domain> <host>...", summary: "explain whether hosts are in scope", minArgs: 2, maxArgs: -1},
+			},
+			run: cmdScope},
+		{name: "import-scope", synopsis: "<file.json|file.csv> [--program <name>] [--dry-run]",
+			summary: "import a HackerOne/Bugcrowd/Intigriti scope export", minArgs: 1, maxArgs: 1, complete: "files", locked: true,
+			flags: func(fs *flag.FlagSet) {
+				fs.String("program", "", "put the imported domains in program `name`")
+				fs.Bool("dry-run", false, "show what would be imported")
+			},
+			run: cmdImportScope},
+		{name: "program", summary: "group domains into programs (owner, notes, tags, statistics)", complete: "domains", locked: true,
+			subs: []command{
+				{name: "list", summary: "programs with per-program statistics"},
+				{name: "show", synopsis: "<name>", summary: "details and domains of a program", minArgs: 1, maxArgs: 1},
+				{name: "set", synopsis: "<name> [--owner <x>] [--notes <y>] [--tag <tag>]... [--exclude <rule>]... [--clear-exclude]", summary: "create or change a program", minArgs: 1, maxArgs: 1,
+					flags: func(fs *flag.FlagSet) {
+						fs.String("owner", "", "program owner")
+						fs.String("notes", "", "free-form notes")
+						fs.Var(&listFlag{}, "tag", "add `tag` (repeatable, comma-separated)")
+						fs.Var(&listFlag{}, "exclude", "exclude scope `rule` (IP, CIDR, ...) from all the program's domains (repeatable)")
+						fs.Bool("clear-exclude", false, "drop the program-wide exclusions first")
+					}},
+				{name: "assign", synopsis: "<name> <domain>...", summary: "move domains into a program", minArgs: 2, maxArgs: -1},
+				{name: "rm", synopsis: "<name>", summary: "delete a program (its domains stay monitored)", minArgs: 1, maxArgs: 1},
+			},
+			run: cmdProgram},
+		{name: "tag", synopsis: "<domain> [+tag | -tag]...", summary: "add or remove tags of a domain", minArgs: 2, maxArgs: -1, raw: true, locked: true, complete: "domains",
+			run: cmdTag},
+		{name: "psl", synopsis: "<host>...", summary: "Public Suffix List: inspect hosts, install a newer list", minArgs: 1, maxArgs: -1, locked: true,
+			subs: []command{
+				{name: "info", summary: "source and size of the list in use"},
+				{name: "update", synopsis: "<public_suffix_list.dat>", summary: "install a newer list", minArgs: 1, maxArgs: 1},
+			},
+			run: cmdPSL},
+		{name: "config", summary: "show or change notifier, AI and API settings", defaultSub: "show", locked: true,
+			subs: []command{
+				{name: "show", summary: "print the settings (secrets masked)", flags: outputFlags},
+				{name: "set-webhook", synopsis: "<discord_url>", summary: "Discord webhook", minArgs: 1, maxArgs: 1},
+				{name: "set-telegram", synopsis: "<bot_token> <chat_id>", summary: "Telegram bot and chat", minArgs: 2, maxArgs: 2},
+				{name: "set-openai", synopsis: "<key>", summary: "OpenAI API key", minArgs: 1, maxArgs: 1},
+				{name: "set-ai", synopsis: "openai|openai-compatible|anthropic|ollama [--model <name>] [--endpoint <url>] [--temperature <t>] [--key <key>]",
+					summary: "AI provider for --ai summaries", minArgs: 1, maxArgs: 1,
+					flags: func(fs *flag.FlagSet) {
+						fs.String("model", "", "model `name` (default: the provider's)")
+						fs.String("endpoint", "", "base `url` (default: the provider's; required for openai-compatible)")
+						fs.Float64("temperature", DefaultAITemperature, "sampling temperature, 0 to 2")
+						fs.String("key", "", "API `key` (openai-compatible, anthropic; or ANTHROPIC_API_KEY)")
+					}},
+				{name: "set-ai-notify", synopsis: "discord|telegram message|embed|off", summary: "how AI summaries are sent to a channel", minArgs: 2, maxArgs: 2},
+				{name: "set-retention", synopsis: "[--keep N] [--days N]", summary: "how many snapshots to keep per domain",
+					flags: func(fs *flag.FlagSet) {
+						fs.Int("keep", 0, "keep the newest `N` snapshots (0 = no limit)")
+						fs.Int("days", 0, "delete snapshots older than `N` days (0 = no limit)")
+					}},
+				{name: "set-api-token", synopsis: "[<token>]", summary: "bearer token for serve (generated if omitted)", maxArgs: 1},
+			},
+			run: cmdConfig},
+		{name: "daemon", synopsis: "[flags]", summary: "run continuously, scanning each domain when it is due",
+			flags: func(fs *flag.FlagSet) {
+				fs.Duration("interval", DefaultScanInterval, "default scan interval")
+				fs.Duration("jitter", DefaultScheduleJitter, "random delay added to each next run")
+				fs.Int("concurrency", 1, "scan `N` domains at a time")
+				fs.Duration("timeout", DefaultDomainTimeout, "per-domain enumeration limit (0 = none)")
+				fs.Bool("ai", false, "summarize new hosts with AI")
+				fs.Bool("resolve", false, "store the addresses of returned hosts in the snapshot")
+				fs.String("metrics-listen", "", "serve Prometheus /metrics on `addr`")
+			},
+			run: cmdDaemon},
+		{name: "schedule", synopsis: "<domain> [--every <duration> | --cron \"<expr>\" | --clear] [--priority N]",
+			summary: "show or set a domain's schedule", minArgs: 1, maxArgs: 1, complete: "domains", locked: true,
+			flags: func(fs *flag.FlagSet) {
+				fs.Duration("every", 0, "scan every `duration` (at least 1m)")
+				fs.String("cron", "", "scan at the times of cron `expr`ession")
+				fs.Bool("clear", false, "go back to the default interval")
+				fs.Int("priority", 0, "higher `N` is scanned first")
+			},
+			run: cmdSchedule},
+		{name: "pause", synopsis: "<domain>...", summary: "stop scheduled scans of domains (data is kept)", minArgs: 1, maxArgs: -1, complete: "domains", locked: true,
+			run: func(ctx context.Context, a cmdArgs) int { return cmdPause(a, false) }},
+		{name: "resume", synopsis: "<domain>...", summary: "restart scheduled scans of paused domains", minArgs: 1, maxArgs: -1, complete: "domains", locked: true,
+			run: func(ctx context.Context, a cmdArgs) int { return cmdPause(a, true) }},
+		{name: "status", synopsis: "", summary: "daemon health, last and next run per domain", flags: outputFlags,
+			run: cmdStatus},
+		{name: "history", synopsis: "<domain> [--limit N] [--failed] [--run <seq>] [--reset-baseline]", summary: "past scans: counts per source, errors, hooks",
+			minArgs: 1, maxArgs: 1, complete: "domains",
+			flags: func(fs *flag.FlagSet) {
+				fs.Int("limit", 20, "show the last `N` runs")
+				fs.Bool("failed", false, "only runs that did not finish ok")
+				fs.Int("run", 0, "show run number `seq` in detail")
+				fs.Bool("reset-baseline", false, "accept the current result level: start the anomaly baseline with the next run")
+				outputFlags(fs)
+			},
+			run: cmdHistory},
+		{name: "diff", synopsis: "<domain> [--from <run|date>] [--to <run|date>] [--json]", summary: "added, removed and re-pointed hosts between two scans",
+			minArgs: 1, maxArgs: 1, complete: "domains",
+			flags: func(fs *flag.FlagSet) {
+				fs.String("from", "", "older snapshot: run number or date (default: the previous one)")
+				fs.String("to", "", "newer snapshot: run number or date (default: the latest)")
+				fs.Bool("json", false, "print JSON")
+			},
+			run: cmdDiff},
+		{name: "report", synopsis: "<domain> | --all | --program <name> | --tag <tag> [flags]", summary: "HTML or Markdown report from the stored history",
+			maxArgs: 1, complete: "domains",
+			flags: func(fs *flag.FlagSet) {
+				fs.Bool("all", false, "report on every monitored domain")
+				fs.String("program", "", "report on the domains of program `name`")
+				fs.String("tag", "", "report on the domains tagged `tag`")
+				fs.String("since", "", "start of the period: `7d` or YYYY-MM-DD (default 7d)")
+				fs.String("until", "", "end of the period: YYYY-MM-DD (default now)")
+				fs.String("format", "", "html or md (default html, md for an --out ending in .md)")
+				fs.String("template", "", "render with the Go template in `file`")
+				out := fs.String("out", "", "write to `file` instead of stdout")
+				fs.StringVar(out, "o", "", "short for --out")
+			},
+			run: cmdReport},
+		{name: "serve", synopsis: "[flags]", summary: "HTTP JSON API, web dashboard and /metrics (token: config set-api-token)",
+			flags: func(fs *flag.FlagSet) {
+				fs.String("listen", DefaultListen, "listen on `addr`")
+				fs.Int("concurrency", 1, "scan `N` domains at a time")
+				fs.Duration("timeout", DefaultDomainTimeout, "per-domain enumeration limit (0 = none)")
+				fs.String("tls-cert", "", "serve HTTPS with this certificate `file`")
+				fs.String("tls-key", "", "and this key `file`")
+			},
+			run: cmdServe},
+		{name: "metrics", synopsis: "[--textfile <file.prom>]", summary: "Prometheus metrics on stdout, or for node_exporter's textfile collector",
+			flags: func(fs *flag.FlagSet) { fs.String("textfile", "", "write atomically to `file.prom`") },
+			run: cmdMetrics},
+		{name: "hook", summary: "run tools (httpx, nuclei, ...) on the new hosts of each scan", defaultSub: "list",
+			subs: []command{
+				{name: "list", summary: "configured hooks"},
+				{name: "add", synopsis: "<name> --cmd '<command>' [--input stdin|file] [--timeout 10m] [--domain <d>]... [--notify]",
+					summary: "add or replace a hook", minArgs: 1, maxArgs: 1, locked: true,
+					flags: func(fs *flag.FlagSet) {
+						fs.String("cmd", "", "the `command` ({domain}, {scan_id}, {count}, {file} are replaced)")
+						fs.String("input", "stdin", "pass the hosts on stdin or in a temp file")
+						fs.Duration("timeout", DefaultHookTimeout, "kill the hook after `duration`")
+						fs.Var(&listFlag{}, "domain", "only for `domain` (repeatable)")
+						fs.Bool("notify", false, "send the output's last lines to the notifiers")
+					}},
+				{name: "rm", synopsis: "<name>", summary: "delete a hook", minArgs: 1, maxArgs: 1, locked: true},
+				{name: "test", synopsis: "<name> <domain> [<host>...]", summary: "run a hook on given hosts, or the domain's latest new hosts", minArgs: 2, maxArgs: -1},
+			},
+			run: cmdHook},
+		{name: "notify-test", synopsis: "<domain>", summary: "send a test notification", minArgs: 1, maxArgs: 1, complete: "domains",
+			run: cmdNotifyTest},
+		{name: "notify-flush", synopsis: "", summary: "retry notifications spooled by interrupted runs", run: cmdNotifyFlush},
+		{name: "setup", synopsis: "", summary: "guided setup (subfinder, notifiers)", run: cmdSetup},
+		{name: "completion", synopsis: "bash|zsh|fish", summary: "print a shell completion script", minArgs: 1, maxArgs: 1,
+			run: cmdCompletion},
+		{name: "help", synopsis: "[<command> [<subcommand>]]", summary: "show the commands, or a command's arguments and flags", maxArgs: 2,
+			run: cmdHelp},
+		// monitored domains, one per line, for the completions
+		{name: "__domains", hidden: true,
+			run: func(ctx context.Context, a cmdArgs) int {
+				all, _ := readLines(filepath.Join(homeDir(), "domains.txt"))
+				for _, d := range uniqueSorted(all) { fmt.Println(d) }
+				return 0
+			}},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands() { if c.name == name { return c, true } }
+	return command{}, false
+}
+
+// sub returns subcommand name with its full name ("scope show") and the
+// parent's lock and handler.
+func (c command) sub(name string) (command, bool) {
+	for _, s := range c.subs {
+		if s.name != name { continue }
+		s.name = c.name + " " + s.name
+		s.locked = s.locked || c.locked
+		if s.run == nil { s.run = c.run }
+		return s, true
+	}
+	return command{}, false
+}
+
+// listFlag is a repeatable string flag.
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+func (l *listFlag) Get() any           { return []string(*l) }
+
+func outputFlags(fs *flag.FlagSet) {
+	fs.Bool("json", false, "print a JSON array (schema in README)")
+	fs.Bool("jsonl", false, "print JSON lines")
+	fs.Bool("csv", false, "print CSV")
+}
+
+func (c command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if c.flags != nil { c.flags(fs) }
+	fs.Var(waitFlag{}, "wait", "wait for a domain another run is scanning")
+	return fs
+}
+
+// exec picks the subcommand, parses and validates args and runs the command.
+func (c command) exec(ctx context.Context, args []string) int {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") { printHelp(os.Stdout, c); return 0 }
+	if len(c.subs) > 0 {
+		name := c.defaultSub
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			if s, ok := c.sub(args[0]); ok { return s.exec(ctx, args[1:]) }
+			// psl <host>...: the command itself takes other arguments
+			if c.maxArgs == 0 { return c.usageError(fmt.Sprintf("unknown subcommand %q", args[0])) }
+			name = ""
+		}
+		if s, ok := c.sub(name); ok { return s.exec(ctx, args) }
+		if len(args) == 0 { return c.usageError("missing subcommand") }
+	}
+	a := cmdArgs{cmd: c, args: args, fs: c.flagSet()}
+	if i := strings.LastIndex(c.name, " "); i >= 0 { a.sub = c.name[i+1:] }
+	if c.raw {
+		rest, err := splitWait(args); if err != nil { return c.usageError(err.Error()) }
+		a.args = rest
+	} else {
+		pos, err := parseArgs(a.fs, args)
+		if errors.Is(err, flag.ErrHelp) { printHelp(os.Stdout, c); return 0 }
+		if err != nil { return c.usageError(strings.Replace(err.Error(), " -", " --", 1)) }
+		if len(pos) > c.maxArgs && c.maxArgs >= 0 { return c.usageError(fmt.Sprintf("unexpected argument %q", pos[c.maxArgs])) }
+		a.args = pos
+	}
+	if len(a.args) < c.minArgs { return c.usageError("missing arguments") }
+	if c.locked { return withHomeLock(ctx, func() int { return c.run(ctx, a) }) }
+	return c.run(ctx, a)
+}
+
+// synopsisLines are the usage lines of c, one per form or subcommand.
+func (c command) synopsisLines() []string {
+	var out []string
+	for _, s := range c.subs {
+		for _, l := range strings.Split(s.synopsis, "\n") { out = append(out, strings.TrimSpace(c.name+" "+s.name+" "+l)) }
+	}
+	if len(c.subs) > 0 && c.maxArgs == 0 { return out }
+	for _, l := range strings.Split(c.synopsis, "\n") { out = append(out, strings.TrimSpace(c.name+" "+l)) }
+	return out
+}
+
+func printUsage(w io.Writer, c command) {
+	for i, l := range c.synopsisLines() {
+		p := "usage:"; if i > 0 { p = "      " }
+		fmt.Fprintf(w, "%s domwatch %s\n", p, l)
+	}
+}
+
+func (c command) usageError(msg string) int {
+	fmt.Fprintln(os.Stderr, "error:", msg)
+	printUsage(os.Stderr, c)
+	fmt.Fprintf(os.Stderr, "Run 'domwatch help %s' for its flags.\n", c.name)
+	return 2
+}
+
+// parseArgs parses flags anywhere in args and returns the positional
+// arguments. Everything after "--" is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil { return nil, err }
+		rest := fs.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" { return append(pos, rest...), nil }
+		if len(rest) == 0 { return pos, nil }
+		pos, args = append(pos, rest[0]), rest[1:]
+	}
+}
+
+// ---------- help ----------
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "DomWatch %s — Subdomain monitor (new vs old) + Discord/Telegram + optional AI\n\n", Version)
+	fmt.Fprintln(w, "Usage: domwatch <command> [arguments] [flags]\n\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands() { if !c.hidden { fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.summary) } }
+	tw.Flush()
+	fmt.Fprint(w, `
+Run 'domwatch help <command>' for its arguments and flags.
+
+  Output: --json | --jsonl | --csv     scan, list, history, status, config show (schema in README)
+  Any command: --wait[=10m]            wait for a domain another run is scanning instead of failing
+
+Exit codes: 0 ok, 1 error, 2 usage error, 3 some domains of a scan failed, 130 interrupted
+
+Env:
+  DOMWATCH_HOME           # base dir (default /opt/domwatch)
+  SUBFINDER_PATH          # custom path to subfinder binary
+  DISCORD_WEBHOOK_URL     # alt to config file value
+  TELEGRAM_BOT_TOKEN, TELEGRAM_CHAT_ID
+  OPENAI_API_KEY          # for --ai (provider openai)
+  ANTHROPIC_API_KEY       # for --ai (provider anthropic)
+  DOMWATCH_API_TOKEN      # alt to config api_token (serve)
+`)
+}
+
+func printHelp(w io.Writer, c command) {
+	printUsage(w, c)
+	fmt.Fprintf(w, "\n%s\n", c.summary)
+	if len(c.subs) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "\nSubcommands:")
+		flags := false
+		for _, s := range c.subs { fmt.Fprintf(tw, "  %s\t%s\n", s.name, s.summary); flags = flags || s.flags != nil }
+		tw.Flush()
+		if flags { fmt.Fprintf(w, "\nRun 'domwatch help %s <subcommand>' for its flags.\n", c.name) }
+	}
+	printFlags(w, c)
+	fmt.Fprintln(w, "\nGlobal flags:\n  --wait[=D]  wait (up to D) for a domain another run is scanning instead of failing")
+}
+
+func printFlags(w io.Writer, c command) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	n := 0
+	c.flagSet().VisitAll(func(f *flag.Flag) {
+		if f.Name == "o" || f.Name == "wait" { return }
+		if n++; n == 1 { fmt.Fprintln(tw, "\nFlags:") }
+		arg, text := flag.UnquoteUsage(f)
+		name := "--" + f.Name
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() { arg = "" }
+		if f.Name == "out" { name = "-o, --out" }
+		if def := f.DefValue; def != "" && def != "0" && def != "0s" && def != "false" { text += " (default " + def + ")" }
+		fmt.Fprintf(tw, "  %s %s\t%s\n", name, arg, text)
+	})
+	tw.Flush()
+}
+
+func cmdHelp(ctx context.Context, a cmdArgs) int {
+	if len(a.args) == 0 { usage(os.Stdout); return 0 }
+	c, ok := findCommand(a.args[0])
+	if !ok || c.hidden { return a.usage(fmt.Sprintf("unknown command %q", a.args[0])) }
+	if len(a.args) > 1 {
+		if c, ok = c.sub(a.args[1]); !ok { return a.usage(fmt.Sprintf("%s has no subcommand %q", a.args[0], a.args[1])) }
+	}
+	printHelp(os.Stdout, c)
+	return 0
+}
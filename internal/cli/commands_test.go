@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		pos  []string
+		prog string
+		tags []string
+		bad  bool
+	}{
+		{name: "flags first", args: []string{"--program", "acme", "example.com"}, pos: []string{"example.com"}, prog: "acme"},
+		{name: "flags after positionals", args: []string{"example.com", "--program", "acme", "--tag", "web"}, pos: []string{"example.com"}, prog: "acme", tags: []string{"web"}},
+		{name: "flags between positionals", args: []string{"a.example", "--tag=x", "b.example", "--tag", "y"}, pos: []string{"a.example", "b.example"}, tags: []string{"x", "y"}},
+		{name: "double dash", args: []string{"--program", "acme", "--", "--not-a-flag", "x"}, pos: []string{"--not-a-flag", "x"}, prog: "acme"},
+		{name: "unknown flag", args: []string{"example.com", "--prgram", "acme"}, bad: true},
+		{name: "missing value", args: []string{"example.com", "--program"}, bad: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			prog := fs.String("program", "", "")
+			var tags listFlag
+			fs.Var(&tags, "tag", "")
+			pos, err := parseArgs(fs, tt.args)
+			if tt.bad {
+				if err == nil { t.Errorf("parseArgs(%q) = %q, want an error", tt.args, pos) }
+				return
+			}
+			if err != nil { t.Fatal(err) }
+			if len(pos) == 0 { pos = nil }
+			if !reflect.DeepEqual(pos, tt.pos) { t.Errorf("positional = %q, want %q", pos, tt.pos) }
+			if !reflect.DeepEqual([]string(tags), tt.tags) { t.Errorf("tags = %q, want %q", tags, tt.tags) }
+			if *prog != tt.prog { t.Errorf("program = %q, want %q", *prog, tt.prog) }
+		})
+	}
+}
+
+// testCommand records the arguments it was run with.
+func testCommand(got *cmdArgs) command {
+	run := func(ctx context.Context, a cmdArgs) int { *got = a; return 0 }
+	return command{name: "test", minArgs: 1, maxArgs: 2, run: run,
+		flags: func(fs *flag.FlagSet) { fs.Bool("all", false, ""); fs.Int("limit", 5, "") },
+		subs: []command{
+			{name: "show", minArgs: 1, maxArgs: 1},
+			{name: "set", minArgs: 2, maxArgs: 2, flags: func(fs *flag.FlagSet) { fs.String("owner", "", "") }},
+		},
+		defaultSub: "show"}
+}
+
+func TestCommandExec(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+		sub  string
+		pos  []string
+	}{
+		{name: "default subcommand", args: []string{"--", "x"}, sub: "show", pos: []string{"x"}},
+		{name: "subcommand", args: []string{"set", "a", "--owner", "me", "b"}, sub: "set", pos: []string{"a", "b"}},
+		{name: "subcommand flag on another subcommand", args: []string{"show", "a", "--owner", "me"}, code: 2},
+		{name: "too few arguments", args: []string{"set", "a"}, code: 2},
+		{name: "too many arguments", args: []string{"show", "a", "b"}, code: 2},
+		{name: "unknown flag is not an argument", args: []string{"show", "--verbose", "a"}, code: 2},
+		{name: "help", args: []string{"--help"}, code: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got cmdArgs
+			code := testCommand(&got).exec(context.Background(), tt.args)
+			if code != tt.code { t.Fatalf("exit code = %d, want %d", code, tt.code) }
+			if tt.code != 0 || tt.sub == "" { return }
+			if got.sub != tt.sub || !reflect.DeepEqual(got.args, tt.pos) { t.Errorf("ran %q with %q, want %q with %q", got.sub, got.args, tt.sub, tt.pos) }
+		})
+	}
+}
+
+func TestCommandTable(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"scan", "--all", "example.com"}, 2},
+		{[]string{"scan", "example.com", "--concurrency", "0"}, 2},
+		{[]string{"scan", "example.com", "--timeout", "soon"}, 2},
+		{[]string{"scan", "--json", "--csv", "example.com"}, 2},
+		{[]string{"list", "--verbose", "example.com"}, 2},
+		{[]string{"add", "example.com", "example.org"}, 2},
+		{[]string{"remove"}, 2},
+		{[]string{"scope", "include", "example.com"}, 2},
+		{[]string{"program", "nosuch"}, 2},
+		{[]string{"psl", "example.co.uk"}, 0},
+		{[]string{"help", "scope", "show"}, 0},
+		{[]string{"help", "nosuch"}, 2},
+	}
+	for _, tt := range tests {
+		c, ok := findCommand(tt.args[0]); if !ok { t.Fatalf("no command %q", tt.args[0]) }
+		if code := c.exec(context.Background(), tt.args[1:]); code != tt.code { t.Errorf("domwatch %q: exit code %d, want %d", tt.args, code, tt.code) }
+	}
+}
+
+func TestRunUsageErrors(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	defer func(args []string, wait time.Duration) { os.Args, lockWait = args, wait }(os.Args, lockWait)
+	for _, args := range [][]string{{}, {"nosuch"}, {"list", "--wait=soon"}, {"history"}} {
+		os.Args = append([]string{"domwatch"}, args...)
+		if code := Run(); code != 2 { t.Errorf("domwatch %q: exit code %d, want 2", args, code) }
+	}
+}
+
+func TestWaitFlag(t *testing.T) {
+	defer func(w time.Duration) { lockWait = w }(lockWait)
+	var got cmdArgs
+	run := func(ctx context.Context, a cmdArgs) int { got = a; return 0 }
+	c := command{name: "test", minArgs: 1, maxArgs: -1, run: run, flags: func(fs *flag.FlagSet) { fs.String("cmd", "", "") }}
+	tests := []struct {
+		args, pos []string
+		cmd       string
+		wait      time.Duration
+	}{
+		{args: []string{"x", "--wait=5m"}, pos: []string{"x"}, wait: 5 * time.Minute},
+		{args: []string{"--wait", "x"}, pos: []string{"x"}, wait: -1},
+		// the value of another flag and arguments after -- are left alone
+		{args: []string{"x", "--cmd", "--wait"}, pos: []string{"x"}, cmd: "--wait"},
+		{args: []string{"x", "--", "--wait=5m"}, pos: []string{"x", "--wait=5m"}},
+	}
+	for _, tt := range tests {
+		lockWait = 0
+		if code := c.exec(context.Background(), tt.args); code != 0 { t.Errorf("%q: exit code %d", tt.args, code); continue }
+		if !reflect.DeepEqual(got.args, tt.pos) || got.str("cmd") != tt.cmd || lockWait != tt.wait {
+			t.Errorf("%q: args %q, --cmd %q, wait %v; want %q, %q, %v", tt.args, got.args, got.str("cmd"), lockWait, tt.pos, tt.cmd, tt.wait)
+		}
+	}
+	if code := c.exec(context.Background(), []string{"x", "--wait=-1m"}); code != 2 { t.Errorf("negative --wait: exit code %d, want 2", code) }
+}
+
+func TestSplitWait(t *testing.T) {
+	defer func(w time.Duration) { lockWait = w }(lockWait)
+	tests := []struct {
+		args, rest []string
+		wait       time.Duration
+		bad        bool
+	}{
+		{args: []string{"example.com"}, rest: []string{"example.com"}},
+		{args: []string{"example.com", "--wait"}, rest: []string{"example.com"}, wait: -1},
+		{args: []string{"--wait=10m", "--all"}, rest: []string{"--all"}, wait: 10 * time.Minute},
+		{args: []string{"--wait=0s"}, rest: nil},
+		{args: []string{"--wait=-1m"}, bad: true},
+		{args: []string{"--wait=soon"}, bad: true},
+		{args: []string{"x", "--", "--wait", "-y"}, rest: []string{"x", "--", "--wait", "-y"}},
+	}
+	for _, tt := range tests {
+		lockWait = 0
+		rest, err := splitWait(tt.args)
+		if tt.bad {
+			if err == nil { t.Errorf("splitWait(%q) accepted", tt.args) }
+			continue
+		}
+		if err != nil { t.Errorf("splitWait(%q): %v", tt.args, err); continue }
+		if !reflect.DeepEqual(rest, tt.rest) || lockWait != tt.wait { t.Errorf("splitWait(%q) = %q, wait %v; want %q, wait %v", tt.args, rest, lockWait, tt.rest, tt.wait) }
+	}
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Completion scripts are generated from the command table; monitored
+// domains are completed by calling `domwatch __domains`.
+
+func cmdCompletion(ctx context.Context, a cmdArgs) int {
+	switch a.args[0] {
+	case "bash":
+		bashCompletion(os.Stdout)
+	case "zsh":
+		zshCompletion(os.Stdout)
+	case "fish":
+		fishCompletion(os.Stdout)
+	default:
+		return a.usage(fmt.Sprintf("no completion for %q (bash, zsh or fish)", a.args[0]))
+	}
+	return 0
+}
+
+// completionFlag is a flag as the completions see it.
+type completionFlag struct {
+	name, usage string
+	value       bool // takes a value
+}
+
+// completionFlags are the flags of c and of its subcommands.
+func completionFlags(c command) []completionFlag {
+	var out []completionFlag
+	seen := map[string]bool{}
+	for _, cc := range append([]command{c}, c.subs...) {
+		cc.flagSet().VisitAll(func(f *flag.Flag) {
+			if seen[f.Name] { return }
+			seen[f.Name] = true
+			_, text := flag.UnquoteUsage(f)
+			b, ok := f.Value.(interface{ IsBoolFlag() bool })
+			out = append(out, completionFlag{name: f.Name, usage: text, value: !ok || !b.IsBoolFlag()})
+		})
+	}
+	out = append(out, completionFlag{name: "help", usage: "show help"})
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func subNames(c command) []string {
+	var out []string
+	for _, s := range c.subs { out = append(out, s.name) }
+	return out
+}
+
+func visibleCommands() []command {
+	var out []command
+	for _, c := range commands() { if !c.hidden { out = append(out, c) } }
+	return out
+}
+
+func bashCompletion(w io.Writer) {
+	var names []string
+	for _, c := range visibleCommands() { names = append(names, c.name) }
+	fmt.Fprintf(w, `# bash completion for domwatch; load with: source <(domwatch completion bash)
+_domwatch() {
+	local cur=${COMP_WORDS[COMP_CWORD]} opts="" subs="" pos=""
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur")); return
+	fi
+	case "${COMP_WORDS[1]}" in
+`, strings.Join(names, " "))
+	for _, c := range visibleCommands() {
+		var opts []string
+		for _, f := range completionFlags(c) { if len(f.name) > 1 { opts = append(opts, "--"+f.name) } }
+		subs := subNames(c)
+		if c.name == "help" { subs = names }
+		fmt.Fprintf(w, "\t%s) opts=%q subs=%q pos=%q ;;\n", c.name, strings.Join(opts, " "), strings.Join(subs, " "), c.complete)
+	}
+	fmt.Fprint(w, `	esac
+	if [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "$opts" -- "$cur"))
+	elif [ "$COMP_CWORD" -eq 2 ] && [ -n "$subs" ]; then
+		COMPREPLY=($(compgen -W "$subs" -- "$cur"))
+	elif [ "$pos" = domains ]; then
+		COMPREPLY=($(compgen -W "$(domwatch __domains 2>/dev/null)" -- "$cur"))
+	elif [ "$pos" = files ]; then
+		COMPREPLY=($(compgen -f -- "$cur"))
+	fi
+}
+complete -F _domwatch domwatch
+`)
+}
+
+// zshQuote escapes s for a single-quoted _arguments spec.
+func zshQuote(s string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func zshCompletion(w io.Writer) {
+	fmt.Fprint(w, `#compdef domwatch
+# zsh completion for domwatch; save as _domwatch in a directory of $fpath
+_domwatch_domains() { local -a d; d=(${(f)"$(domwatch __domains 2>/dev/null)"}); _describe 'domain' d }
+_domwatch() {
+	local -a commands
+	commands=(
+`)
+	for _, c := range visibleCommands() { fmt.Fprintf(w, "\t\t'%s:%s'\n", c.name, zshQuote(c.summary)) }
+	fmt.Fprint(w, `	)
+	if (( CURRENT == 2 )); then _describe 'command' commands; return; fi
+	local cmd=$words[2]
+	shift words; (( CURRENT-- ))
+	case $cmd in
+`)
+	for _, c := range visibleCommands() {
+		var specs []string
+		for _, f := range completionFlags(c) {
+			if len(f.name) == 1 { continue }
+			spec := "'--" + f.name
+			if f.value { spec += "=" }
+			spec += "[" + zshQuote(f.usage) + "]"
+			if f.value { spec += ":value:" }
+			specs = append(specs, spec+"'")
+		}
+		switch {
+		case c.name == "help":
+			var names []string
+			for _, o := range visibleCommands() { names = append(names, o.name) }
+			specs = append(specs, "'1:command:("+strings.Join(names, " ")+")'")
+		case len(c.subs) > 0:
+			specs = append(specs, "'1:subcommand:("+strings.Join(subNames(c), " ")+")'")
+		}
+		switch c.complete {
+		case "domains":
+			specs = append(specs, "'*:domain:_domwatch_domains'")
+		case "files":
+			specs = append(specs, "'*:file:_files'")
+		}
+		fmt.Fprintf(w, "\t\t%s) _arguments -s %s ;;\n", c.name, strings.Join(specs, " "))
+	}
+	fmt.Fprint(w, `	esac
+}
+_domwatch "$@"
+`)
+}
+
+func fishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# fish completion for domwatch; save as ~/.config/fish/completions/domwatch.fish")
+	fmt.Fprintln(w, "complete -c domwatch -f")
+	q := func(s string) string { return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'" }
+	for _, c := range visibleCommands() {
+		fmt.Fprintf(w, "complete -c domwatch -n __fish_use_subcommand -a %s -d %s\n", c.name, q(c.summary))
+	}
+	for _, c := range visibleCommands() {
+		cond := q("__fish_seen_subcommand_from " + c.name)
+		for _, f := range completionFlags(c) {
+			if len(f.name) == 1 { continue }
+			r := ""; if f.value { r = " -r" }
+			fmt.Fprintf(w, "complete -c domwatch -n %s -l %s%s -d %s\n", cond, f.name, r, q(f.usage))
+		}
+		if c.name == "help" {
+			for _, o := range visibleCommands() { fmt.Fprintf(w, "complete -c domwatch -n %s -a %s\n", cond, o.name) }
+		}
+		for _, s := range c.subs { fmt.Fprintf(w, "complete -c domwatch -n %s -a %s -d %s\n", cond, s.name, q(s.summary)) }
+		switch c.complete {
+		case "domains":
+			fmt.Fprintf(w, "complete -c domwatch -n %s -a '(domwatch __domains 2>/dev/null)'\n", cond)
+		case "files":
+			fmt.Fprintf(w, "complete -c domwatch -n %s -F\n", cond)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSpec is a parsed 5-field cron expression (minute hour dom month dow).
+// Supports *, lists, ranges, steps, month/day names and @hourly-style macros.
+type cronSpec struct {
+	minute, hour, dom, month, dow [64]bool
+	domStar, dowStar              bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly": "0 0 1 1 *", "@annually": "0 0 1 1 *", "@monthly": "0 0 1 * *",
+	"@weekly": "0 0 * * 0", "@daily": "0 0 * * *", "@midnight": "0 0 * * *", "@hourly": "0 * * * *",
+}
+// names are accepted only in their own field: "mon" is not a month.
+var (
+	cronMonths = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	cronDays   = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+func parseCron(expr string) (*cronSpec, error) {
+	expr = strings.TrimSpace(expr)
+	if m, ok := cronMacros[strings.ToLower(expr)]; ok { expr = m }
+	f := strings.Fields(expr)
+	if len(f) != 5 { return nil, fmt.Errorf("cron %q: want 5 fields (minute hour day-of-month month day-of-week)", expr) }
+	// as in Vixie cron, a day field starting with "*" (*/2 too) is "star"
+	c := &cronSpec{domStar: strings.HasPrefix(f[2], "*"), dowStar: strings.HasPrefix(f[4], "*")}
+	for i, fld := range []struct {
+		set      *[64]bool
+		min, max int
+		names    map[string]int
+		wrap     int // a range lo-hi with lo > hi wraps at this period (dow only, as in Vixie cron)
+	}{{&c.minute, 0, 59, nil, 0}, {&c.hour, 0, 23, nil, 0}, {&c.dom, 1, 31, nil, 0}, {&c.month, 1, 12, cronMonths, 0}, {&c.dow, 0, 7, cronDays, 7}} {
+		if err := parseCronField(f[i], fld.set, fld.min, fld.max, fld.names, fld.wrap); err != nil { return nil, fmt.Errorf("cron %q: %w", expr, err) }
+	}
+	if c.dow[7] { c.dow[0] = true }
+	return c, nil
+}
+
+func parseCronField(s string, set *[64]bool, min, max int, names map[string]int, wrap int) error {
+	num := func(v string) (int, error) {
+		if n, ok := names[strings.ToLower(v)]; ok { return n, nil }
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max { return 0, fmt.Errorf("value %q out of range %d-%d", v, min, max) }
+		return n, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:]); if err != nil || n < 1 { return fmt.Errorf("bad step in %q", part) }
+			rng, step = part[:i], n
+		}
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = num(a); err != nil { return err }
+			if hi, err = num(b); err != nil { return err }
+			// fri-mon: the days from Friday through Monday
+			if lo > hi && wrap > 0 { hi += wrap }
+			if lo > hi { return fmt.Errorf("bad range %q", rng) }
+		default:
+			n, err := num(rng); if err != nil { return err }
+			lo, hi = n, n
+			if step > 1 { hi = max }
+		}
+		for v := lo; v <= hi; v += step {
+			if wrap > 0 && v > max { set[v%wrap] = true } else { set[v] = true }
+		}
+	}
+	return nil
+}
+
+// next returns the first minute strictly after t matching the spec.
+func (c *cronSpec) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.month[int(t.Month())] { t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()); continue }
+		if !c.dayMatches(t) { t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()); continue }
+		if !c.hour[t.Hour()] { t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location()); continue }
+		if !c.minute[t.Minute()] { t = t.Add(time.Minute); continue }
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron semantics: when both day fields are restricted,
+// either may match; when one is star, both must.
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	d, w := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	if c.domStar || c.dowStar { return d && w }
+	return d || w
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", s); if err != nil { t.Fatal(err) }
+		return v
+	}
+	mon := "2024-01-15 10:30" // a Monday
+	tests := []struct{ expr, from, want string }{
+		{"*/15 * * * *", mon, "2024-01-15 10:45"},
+		{"@hourly", mon, "2024-01-15 11:00"},
+		{"@daily", mon, "2024-01-16 00:00"},
+		{"@weekly", mon, "2024-01-21 00:00"},
+		{"@monthly", mon, "2024-02-01 00:00"},
+		{"@yearly", mon, "2025-01-01 00:00"},
+		{"30 9 * * mon-fri", mon, "2024-01-16 09:30"},
+		{"0 12 * * sat,sun", mon, "2024-01-20 12:00"},
+		{"0 0 * * 7", mon, "2024-01-21 00:00"},
+		{"0 9 * * MON", "2024-01-15 09:00", "2024-01-22 09:00"},
+		{"0 0 29 feb *", mon, "2024-02-29 00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"5 4 * dec *", mon, "2024-12-01 04:05"},
+		{"0 0 1 jan-mar/2 *", mon, "2024-03-01 00:00"},
+		{"0 0 31 * *", "2024-02-01 00:00", "2024-03-31 00:00"},
+		// both day fields restricted: either matches
+		{"0 0 1-7 * mon", mon, "2024-01-22 00:00"},
+		{"0 0 1-7 * mon", "2024-01-29 12:00", "2024-02-01 00:00"},
+		// a stepped star is still star: odd days that are Mondays
+		{"0 0 */2 * 1", mon, "2024-01-29 00:00"},
+		{"0 0 1 * */7", mon, "2024-09-01 00:00"}, // the 1st on a Sunday
+		// a weekday range may wrap around the end of the week
+		{"0 0 * * fri-mon", mon, "2024-01-19 00:00"},
+		{"0 0 * * 5-1", "2024-01-20 12:00", "2024-01-21 00:00"},
+		{"0 0 * * 5-1", "2024-01-22 12:00", "2024-01-26 00:00"},
+		{"0 0 * * 6-2/2", mon, "2024-01-20 00:00"},
+		{"0 0 * * 6-2/2", "2024-01-20 12:00", "2024-01-22 00:00"},
+		{"0-10/5 8 * * *", mon, "2024-01-16 08:00"},
+		{"10 8/6 * * *", mon, "2024-01-15 14:10"},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr); if err != nil { t.Errorf("parseCron(%q): %v", tt.expr, err); continue }
+		if got := c.next(at(tt.from)); !got.Equal(at(tt.want)) {
+			t.Errorf("%q after %s = %s, want %s", tt.expr, tt.from, got.Format("2006-01-02 15:04 Mon"), tt.want)
+		}
+	}
+}
+
+func TestCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"0 0 0 * *",
+		"0 0 1 13 *",
+		"0 0 * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"0 0 * dec-jan *", // only weekday ranges wrap
+		"0 0 * mon *", // day name in the month field
+		"0 0 * * jan", // month name in the weekday field
+		"0 jan * * *",
+		"0 0 sun * *",
+	} {
+		if _, err := parseCron(expr); err == nil { t.Errorf("parseCron(%q) succeeded", expr) }
+	}
+}
@@ -0,0 +1,417 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------- schedule state ----------
+
+const (
+	StateRelPath          = "schedule.json"
+	DefaultScanInterval   = 6 * time.Hour
+	DefaultScheduleJitter = 10 * time.Minute
+	daemonTick            = 30 * time.Second
+)
+
+func statePath() string { return filepath.Join(homeDir(), StateRelPath) }
+
+// DomainState is the scheduler's view of one domain; it survives restarts.
+type DomainState struct {
+	LastRun      time.Time `json:"last_run,omitempty"`
+	LastOK       bool      `json:"last_ok"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastNew      int       `json:"last_new"`
+	Total        int       `json:"total"`
+	NextRun      time.Time `json:"next_run,omitempty"`
+	BaselineFrom int       `json:"baseline_from,omitempty"` // first run the anomaly baseline may use (history --reset-baseline)
+}
+
+// DaemonInfo is the heartbeat a running `domwatch daemon` keeps fresh.
+type DaemonInfo struct {
+	PID       int       `json:"pid"`
+	Started   time.Time `json:"started"`
+	Heartbeat time.Time `json:"heartbeat"`
+	Running   []string  `json:"running,omitempty"`
+}
+
+type SchedState struct {
+	Daemon  *DaemonInfo             `json:"daemon,omitempty"`
+	Domains map[string]*DomainState `json:"domains"`
+}
+
+func loadState() (*SchedState, error) {
+	st := &SchedState{}
+	b, err := os.ReadFile(statePath())
+	if err != nil && !os.IsNotExist(err) { return nil, err }
+	if err == nil {
+		if err := json.Unmarshal(b, st); err != nil { return nil, fmt.Errorf("%s: %w", statePath(), err) }
+	}
+	if st.Domains == nil { st.Domains = map[string]*DomainState{} }
+	return st, nil
+}
+func saveState(st *SchedState) error {
+	b, _ := json.MarshalIndent(st, "", "  ")
+	return writeFileAtomic(statePath(), b, 0o644)
+}
+
+// updateState applies fn to the freshly loaded state and saves it.
+// domain returns the state of d, creating it when missing.
+func (st *SchedState) domain(d string) *DomainState {
+	ds := st.Domains[d]
+	if ds == nil { ds = &DomainState{}; st.Domains[d] = ds }
+	return ds
+}
+
+func updateState(fn func(*SchedState)) error {
+	release, err := lockHome(context.Background(), os.Stderr); if err != nil { return err }
+	defer release()
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	st, err := loadState(); if err != nil { return err }
+	fn(st)
+	return saveState(st)
+}
+
+// recordScan stores the outcome of a scan of domain (from scan or daemon).
+// LastRun is the scan's start so fixed-rate timers don't drift out of "due".
+func recordScan(domain string, r scanResult, err error) {
+	// the run holding the domain lock records its own outcome
+	var busy *lockBusyError
+	if errors.As(err, &busy) { return }
+	now := r.Started
+	if now.IsZero() { now = time.Now() }
+	e := updateState(func(st *SchedState) {
+		ds := st.domain(domain)
+		ds.LastRun, ds.LastOK, ds.LastError = now, err == nil, ""
+		if err != nil { ds.LastError = firstLine(err.Error()); return }
+		ds.LastNew, ds.Total = r.New, r.Total
+	})
+	if e != nil { fmt.Fprintln(os.Stderr, "state error:", e) }
+}
+
+// ---------- scheduling ----------
+
+// scheduleOf describes when domain runs; def is used without an own interval.
+func scheduleOf(t *Target, def time.Duration) string {
+	switch {
+	case t != nil && t.Cron != "":
+		return "cron " + t.Cron
+	case t != nil && t.Interval != "":
+		return "every " + t.Interval
+	}
+	return "every " + def.String() + " (default)"
+}
+
+// nextRun computes the next run after from, plus a random jitter.
+func nextRun(t *Target, def, jitter time.Duration, from time.Time) (time.Time, error) {
+	var next time.Time
+	switch {
+	case t != nil && t.Cron != "":
+		c, err := parseCron(t.Cron); if err != nil { return time.Time{}, err }
+		next = c.next(from)
+		if next.IsZero() { return time.Time{}, fmt.Errorf("cron %q never fires", t.Cron) }
+	case t != nil && t.Interval != "":
+		d, err := time.ParseDuration(t.Interval); if err != nil { return time.Time{}, err }
+		next = from.Add(d)
+	default:
+		next = from.Add(def)
+	}
+	if jitter > 0 { next = next.Add(time.Duration(rand.Int63n(int64(jitter)))) }
+	return next, nil
+}
+
+// isDue reports whether a scan of domain is due at now, based on its last
+// run. Intervals get 10% slack so a timer with the same period always hits.
+func isDue(t *Target, ds *DomainState, def time.Duration, now time.Time) bool {
+	if t != nil && t.Paused { return false }
+	if ds == nil || ds.LastRun.IsZero() { return true }
+	if t != nil && t.Cron != "" {
+		c, err := parseCron(t.Cron)
+		if err == nil { n := c.next(ds.LastRun); return !n.IsZero() && !n.After(now) }
+	}
+	iv := def
+	if t != nil && t.Interval != "" { if d, err := time.ParseDuration(t.Interval); err == nil { iv = d } }
+	return !ds.LastRun.Add(iv - iv/10).After(now)
+}
+
+// byPriority sorts domains by descending priority, then name.
+func byPriority(reg *Registry, domains []string) {
+	prio := func(d string) int { if t := reg.Targets[d]; t != nil { return t.Priority }; return 0 }
+	sort.SliceStable(domains, func(i, j int) bool {
+		if pi, pj := prio(domains[i]), prio(domains[j]); pi != pj { return pi > pj }
+		return domains[i] < domains[j]
+	})
+}
+
+// dueDomains filters domains to those that are due and not paused, in
+// priority order, and counts the paused and not-yet-due ones it left out.
+func dueDomains(domains []string, def time.Duration, force bool) (due []string, paused, notDue int, err error) {
+	reg, err := loadRegistry(); if err != nil { return nil, 0, 0, err }
+	st, err := loadState(); if err != nil { return nil, 0, 0, err }
+	now := time.Now()
+	for _, d := range domains {
+		t := reg.Targets[d]
+		switch {
+		case t != nil && t.Paused:
+			paused++
+		case force || isDue(t, st.Domains[d], def, now):
+			due = append(due, d)
+		default:
+			notDue++
+		}
+	}
+	byPriority(reg, due)
+	return due, paused, notDue, nil
+}
+
+// ---------- daemon ----------
+func cmdDaemon(ctx context.Context, a cmdArgs) int {
+	def, jitter, opts := a.duration("interval"), a.duration("jitter"), scanOptions{WithAI: a.bool("ai"), Resolve: a.bool("resolve"), Workers: a.int("concurrency"), Timeout: a.duration("timeout"), Trigger: "daemon"}
+	if def <= 0 { return a.usage("--interval must be positive") }
+	if jitter < 0 || opts.Timeout < 0 { return a.usage("--jitter and --timeout must not be negative") }
+	if opts.Workers < 1 { return a.usage("--concurrency must be a positive integer") }
+	if err := ensureSubfinder(); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	dl, err := acquireLock(ctx, "daemon", lockWait, os.Stderr)
+	if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	defer dl.unlock()
+	if addr := a.str("metrics-listen"); addr != "" {
+		if err := listenMetrics(ctx, addr); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	}
+
+	hb := &heartbeat{info: DaemonInfo{PID: os.Getpid(), Started: time.Now()}}
+	hbCtx, hbStop := context.WithCancel(ctx)
+	hbDone := make(chan struct{})
+	go func() { hb.run(hbCtx); close(hbDone) }()
+	opts.OnDone = func(d string, r scanResult, err error) {
+		// an interrupted scan stays due so it reruns right after a restart
+		if ctx.Err() != nil { return }
+		recordScan(d, r, err)
+		reg, _ := loadRegistry()
+		var t *Target; if reg != nil { t = reg.Targets[d] }
+		next, e := nextRun(t, def, jitter, time.Now())
+		if e != nil { fmt.Fprintf(os.Stderr, "schedule error: %s: %v (using default interval)\n", d, e); next, _ = nextRun(nil, def, jitter, time.Now()) }
+		updateState(func(st *SchedState) { st.domain(d).NextRun = next })
+	}
+	fmt.Printf("domwatch daemon started (pid %d, default interval %s, jitter %s, concurrency %d)\n", os.Getpid(), def, jitter, opts.Workers)
+	for {
+		due := dueForDaemon(def, jitter)
+		if len(due) > 0 {
+			fmt.Printf("%s scanning %d due domain(s): %s\n", time.Now().Format(time.RFC3339), len(due), strings.Join(due, ", "))
+			hb.setRunning(due)
+			if n := flushPending(ctx, os.Stderr); n > 0 { fmt.Printf("delivered %d pending notification(s)\n", n) }
+			scanMany(ctx, due, opts)
+			hb.setRunning(nil)
+		}
+		if ctx.Err() != nil { break }
+		if err := sleepCtx(ctx, daemonTick); err != nil { break }
+	}
+	hbStop(); <-hbDone
+	updateState(func(st *SchedState) { st.Daemon = nil })
+	fmt.Println("domwatch daemon stopped")
+	return 0
+}
+
+// heartbeat keeps SchedState.Daemon fresh while scans are running.
+type heartbeat struct {
+	mu   sync.Mutex
+	info DaemonInfo
+}
+
+func (h *heartbeat) setRunning(d []string) { h.mu.Lock(); h.info.Running = d; h.mu.Unlock(); h.beat() }
+func (h *heartbeat) beat() {
+	h.mu.Lock(); h.info.Heartbeat = time.Now(); info := h.info; h.mu.Unlock()
+	if err := updateState(func(st *SchedState) { st.Daemon = &info }); err != nil { fmt.Fprintln(os.Stderr, "state error:", err) }
+}
+func (h *heartbeat) run(ctx context.Context) {
+	for { h.beat(); if sleepCtx(ctx, daemonTick) != nil { return } }
+}
+
+// dueForDaemon assigns a first NextRun to new domains (spread over the
+// jitter window, or the next firing of their cron schedule) and returns
+// those that are due, by priority.
+func dueForDaemon(def, jitter time.Duration) []string {
+	domains, _ := readLines(filepath.Join(homeDir(), "domains.txt"))
+	reg, err := loadRegistry(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return nil }
+	now := time.Now()
+	var due []string
+	err = updateState(func(st *SchedState) {
+		for _, d := range domains {
+			d = strings.ToLower(d)
+			if t := reg.Targets[d]; t != nil && t.Paused { continue }
+			ds := st.domain(d)
+			if t := reg.Targets[d]; ds.NextRun.IsZero() && t != nil && t.Cron != "" {
+				next, err := nextRun(t, def, jitter, now)
+				if err != nil { fmt.Fprintf(os.Stderr, "schedule error: %s: %v (scanning now)\n", d, err) } else { ds.NextRun = next }
+			}
+			if ds.NextRun.IsZero() {
+				ds.NextRun = now
+				if jitter > 0 { ds.NextRun = now.Add(time.Duration(rand.Int63n(int64(jitter)))) }
+			}
+			if !ds.NextRun.After(now) { due = append(due, d) }
+		}
+	})
+	if err != nil { fmt.Fprintln(os.Stderr, "state error:", err) }
+	byPriority(reg, due)
+	return due
+}
+
+// ---------- schedule / status commands ----------
+func cmdSchedule(ctx context.Context, a cmdArgs) int {
+	domain, err := monitoredDomain(a.args[0]); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	reg, err := loadRegistry(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	t := reg.target(domain)
+	if a.isSet("priority") { t.Priority = a.int("priority") }
+	switch {
+	case a.bool("clear"):
+		t.Interval, t.Cron = "", ""
+	case a.str("cron") != "":
+		c := a.str("cron")
+		if _, err := parseCron(c); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 2 }
+		t.Cron = c
+	case a.isSet("every"):
+		d := a.duration("every")
+		if d < time.Minute { return a.usage("--every must be a duration of at least 1m, e.g. 12h") }
+		t.Interval, t.Cron = d.String(), ""
+	default:
+		if !a.isSet("priority") { fmt.Println(domain+":", describeTarget(t)); return 0 }
+	}
+	if err := saveRegistry(reg); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	// let the daemon pick the new schedule up on its next tick
+	updateState(func(st *SchedState) { if ds := st.Domains[domain]; ds != nil { ds.NextRun = time.Time{} } })
+	fmt.Println(domain+":", describeTarget(t))
+	return 0
+}
+
+func describeTarget(t *Target) string {
+	s := scheduleOf(t, DefaultScanInterval)
+	if t.Priority != 0 { s += fmt.Sprintf(", priority %d", t.Priority) }
+	if t.Paused { s += ", paused" }
+	return s
+}
+
+// cmdPause pauses (or with resume=true, resumes) scanning of domains.
+func cmdPause(a cmdArgs, resume bool) int {
+	verb := "pause"; if resume { verb = "resume" }
+	var ds []string
+	for _, arg := range a.args {
+		d, err := monitoredDomain(arg); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+		ds = append(ds, d)
+	}
+	reg, err := loadRegistry(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	for _, d := range ds { reg.target(d).Paused = !resume }
+	if err := saveRegistry(reg); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	for _, d := range ds { fmt.Printf("%sd: %s\n", verb, d) }
+	return 0
+}
+
+// statusReport is `domwatch status --json`.
+type statusReport struct {
+	Daemon  daemonStatus   `json:"daemon"`
+	Domains []domainStatus `json:"domains"`
+}
+
+type daemonStatus struct {
+	State string `json:"state"` // running, stale, stopped
+	*DaemonInfo
+}
+
+// domainStatus is one row of `domwatch status` (one JSON line / CSV row).
+type domainStatus struct {
+	Domain    string    `json:"domain"`
+	Schedule  string    `json:"schedule"`
+	Priority  int       `json:"priority"`
+	Paused    bool      `json:"paused"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastOK    bool       `json:"last_ok"`
+	LastError string     `json:"last_error,omitempty"`
+	LastNew   int        `json:"last_new"`
+	Total     int        `json:"total"`
+	Due       bool       `json:"due"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+}
+
+func buildStatus(st *SchedState, reg *Registry, now time.Time) statusReport {
+	rep := statusReport{Daemon: daemonStatus{State: "stopped", DaemonInfo: st.Daemon}, Domains: []domainStatus{}}
+	if d := st.Daemon; d != nil {
+		rep.Daemon.State = "running"
+		if now.Sub(d.Heartbeat) > 3*daemonTick { rep.Daemon.State = "stale" }
+	}
+	domains, _ := readLines(filepath.Join(homeDir(), "domains.txt"))
+	sort.Strings(domains)
+	byPriority(reg, domains)
+	for _, d := range domains {
+		t := reg.Targets[d]
+		ds := st.Domains[d]; if ds == nil { ds = &DomainState{} }
+		row := domainStatus{Domain: d, Schedule: scheduleOf(t, DefaultScanInterval), LastRun: optTime(ds.LastRun), LastOK: ds.LastOK,
+			LastError: ds.LastError, LastNew: ds.LastNew, Total: ds.Total, Due: isDue(t, st.Domains[d], DefaultScanInterval, now)}
+		if t != nil { row.Priority, row.Paused = t.Priority, t.Paused }
+		switch {
+		case row.Paused:
+		case st.Daemon != nil && !ds.NextRun.IsZero():
+			row.NextRun = optTime(ds.NextRun)
+		case !row.Due:
+			if n, err := nextRun(t, DefaultScanInterval, 0, ds.LastRun); err == nil { row.NextRun = optTime(n) }
+		}
+		rep.Domains = append(rep.Domains, row)
+	}
+	return rep
+}
+
+func cmdStatus(ctx context.Context, a cmdArgs) int {
+	format, err := outputFlag(a); if err != nil { return a.usage(err.Error()) }
+	st, err := loadState(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	reg, _ := loadRegistry()
+	if reg == nil { reg = &Registry{Targets: map[string]*Target{}} }
+	now := time.Now()
+	rep := buildStatus(st, reg, now)
+	switch format {
+	case formatJSON:
+		return exitWrite(writeJSON(os.Stdout, rep))
+	case formatJSONL, formatCSV:
+		cols := []string{"domain", "schedule", "priority", "paused", "last_run", "last_ok", "last_error", "last_new", "total", "due", "next_run"}
+		return exitWrite(writeRecords(os.Stdout, format, rep.Domains, cols, func(r domainStatus) []string {
+			return []string{r.Domain, r.Schedule, strconv.Itoa(r.Priority), strconv.FormatBool(r.Paused), csvOptTime(r.LastRun), strconv.FormatBool(r.LastOK),
+				r.LastError, strconv.Itoa(r.LastNew), strconv.Itoa(r.Total), strconv.FormatBool(r.Due), csvOptTime(r.NextRun)}
+		}))
+	}
+
+	switch d := st.Daemon; rep.Daemon.State {
+	case "stopped":
+		fmt.Println("daemon: not running")
+	case "stale":
+		fmt.Printf("daemon: stale (pid %d, last heartbeat %s ago)\n", d.PID, now.Sub(d.Heartbeat).Round(time.Second))
+	default:
+		fmt.Printf("daemon: running (pid %d, up %s, heartbeat %s ago)\n", d.PID, now.Sub(d.Started).Round(time.Second), now.Sub(d.Heartbeat).Round(time.Second))
+		if len(d.Running) > 0 { fmt.Println("scanning:", strings.Join(d.Running, ", ")) }
+	}
+	fmt.Printf("%-32s %-22s %4s %-20s %-6s %6s %-20s\n", "DOMAIN", "SCHEDULE", "PRIO", "LAST RUN", "STATUS", "NEW", "NEXT")
+	for _, r := range rep.Domains {
+		last, status, next := "never", "-", "-"
+		if r.LastRun != nil {
+			last = r.LastRun.Local().Format("2006-01-02 15:04")
+			status = "ok"; if !r.LastOK { status = "FAIL" }
+		}
+		switch {
+		case r.Paused:
+			next = "paused"
+		case r.NextRun != nil:
+			next = r.NextRun.Local().Format("2006-01-02 15:04")
+		case r.Due:
+			next = "due"
+		}
+		fmt.Printf("%-32s %-22s %4d %-20s %-6s %6d %-20s\n", r.Domain, r.Schedule, r.Priority, last, status, r.LastNew, next)
+		if r.LastError != "" && !r.LastOK { fmt.Printf("  last error: %s\n", r.LastError) }
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRemoveDomainClearsSchedule(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	for _, d := range []string{"example.com", "example.org"} {
+		if _, err := addDomain(d); err != nil { t.Fatal(err) }
+	}
+	next := time.Now().Add(time.Hour).Truncate(time.Second)
+	err := updateState(func(st *SchedState) {
+		st.domain("example.com").NextRun = next
+		st.domain("example.org").NextRun = next
+	})
+	if err != nil { t.Fatal(err) }
+	if err := removeDomain(context.Background(), "example.com", 0, io.Discard); err != nil { t.Fatal(err) }
+	st, err := loadState(); if err != nil { t.Fatal(err) }
+	if _, ok := st.Domains["example.com"]; ok { t.Error("schedule entry of the removed domain kept") }
+	if ds := st.Domains["example.org"]; ds == nil || !ds.NextRun.Equal(next) { t.Errorf("other domain's entry = %+v", ds) }
+}
+
+func TestSchedStateDomain(t *testing.T) {
+	st := &SchedState{Domains: map[string]*DomainState{}}
+	ds := st.domain("example.com")
+	ds.NextRun = time.Unix(1, 0)
+	if st.Domains["example.com"] != ds || st.domain("example.com") != ds { t.Error("entry not created once") }
+}
+
+func TestPauseMonitoredOnly(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	if _, err := addDomain("example.com"); err != nil { t.Fatal(err) }
+	pause, _ := findCommand("pause")
+	if code := pause.exec(context.Background(), []string{"example.com", "exmaple.com"}); code != 1 { t.Errorf("pause with a typo: exit code %d, want 1", code) }
+	reg, _ := loadRegistry()
+	if len(reg.Targets) != 0 { t.Errorf("targets recorded for a failed pause: %v", reg.Targets) }
+	if code := pause.exec(context.Background(), []string{"EXAMPLE.com."}); code != 0 { t.Fatalf("pause: exit code %d", code) }
+	reg, _ = loadRegistry()
+	if tg := reg.Targets["example.com"]; tg == nil || !tg.Paused || len(reg.Targets) != 1 { t.Errorf("targets = %v, want example.com paused", reg.Targets) }
+}
+
+func TestScheduleMonitoredOnly(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	if _, err := addDomain("example.com"); err != nil { t.Fatal(err) }
+	schedule, _ := findCommand("schedule")
+	if code := schedule.exec(context.Background(), []string{"exmaple.com", "--every", "2h"}); code != 1 { t.Errorf("schedule of a typo: exit code %d, want 1", code) }
+	if code := schedule.exec(context.Background(), []string{"Example.COM", "--every", "2h"}); code != 0 { t.Fatalf("schedule: exit code %d", code) }
+	reg, _ := loadRegistry()
+	if tg := reg.Targets["example.com"]; tg == nil || tg.Interval != "2h0m0s" || len(reg.Targets) != 1 { t.Errorf("targets = %v", reg.Targets) }
+}
+
+func TestDueForDaemonCron(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	for _, d := range []string{"example.com", "example.org"} {
+		if _, err := addDomain(d); err != nil { t.Fatal(err) }
+	}
+	reg, err := loadRegistry(); if err != nil { t.Fatal(err) }
+	// fires at 03:00 on the 1st of January only: never within the test
+	reg.target("example.org").Cron = "0 3 1 1 *"
+	if err := saveRegistry(reg); err != nil { t.Fatal(err) }
+	now := time.Now()
+	if due := dueForDaemon(time.Hour, 0); len(due) != 1 || due[0] != "example.com" { t.Errorf("due = %v, want [example.com]", due) }
+	st, err := loadState(); if err != nil { t.Fatal(err) }
+	want := time.Date(now.Year()+1, 1, 1, 3, 0, 0, 0, time.Local)
+	if now.Before(time.Date(now.Year(), 1, 1, 3, 0, 0, 0, time.Local)) { want = want.AddDate(-1, 0, 0) }
+	if got := st.Domains["example.org"].NextRun; !got.Equal(want) { t.Errorf("first NextRun of the cron domain = %v, want %v", got, want) }
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The dashboard is a static page compiled into the binary that talks to the
+// JSON API of `domwatch serve` with the same bearer token; the page itself
+// carries no data and needs no token. It loads nothing from other origins.
+//
+//go:embed web
+var webFiles embed.FS
+
+const maxSearchResults = 500
+
+func (s *apiServer) serveStatic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed"); return
+	}
+	sub, _ := fs.Sub(webFiles, "web")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; img-src 'self' data:; frame-ancestors 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	http.FileServer(http.FS(sub)).ServeHTTP(w, r)
+}
+
+// discovery is one host as it was first added to an inventory.
+type discovery struct {
+	Domain    string    `json:"domain"`
+	Host      string    `json:"host"`
+	Unicode   string    `json:"unicode,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	InScope   bool      `json:"in_scope"`
+}
+
+// GET /discoveries[?since=7d][&domain=d][&limit=N], newest first
+func (s *apiServer) listDiscoveries(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	since := now.Add(-DefaultReportPeriod)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := parseSince(v, now); if err != nil { writeAPIError(w, http.StatusBadRequest, err.Error()); return }
+		since = t
+	}
+	limit, err := intParam(r, "limit", defaultPageSize); if err != nil { writeAPIError(w, http.StatusBadRequest, err.Error()); return }
+	if limit == 0 || limit > maxPageSize { limit = maxPageSize }
+	domains, _ := readLines(filepath.Join(homeDir(), "domains.txt"))
+	if v := r.URL.Query().Get("domain"); v != "" {
+		d, err := normalizeDomain(v)
+		if err != nil || !monitored(d) { writeAPIError(w, http.StatusNotFound, "unknown domain "+v); return }
+		domains = []string{d}
+	}
+	out := []discovery{}
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		var batch []discovery
+		var hosts []string
+		for _, b := range newHostBatches(d) {
+			if b.Time.Before(since) { continue }
+			for _, h := range b.Hosts { batch = append(batch, discovery{Domain: d, Host: h, FirstSeen: b.Time}); hosts = append(hosts, h) }
+		}
+		if len(batch) == 0 { continue }
+		_, oos := splitScope(d, hosts)
+		isOOS := map[string]bool{}
+		for _, h := range oos { isOOS[h] = true }
+		for _, x := range batch {
+			x.InScope = !isOOS[x.Host]
+			if u := displayHost(x.Host); u != x.Host { x.Unicode = u }
+			out = append(out, x)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].FirstSeen.Equal(out[j].FirstSeen) { return out[i].FirstSeen.After(out[j].FirstSeen) }
+		return out[i].Host < out[j].Host
+	})
+	if len(out) > limit { out = out[:limit] }
+	writeAPI(w, http.StatusOK, out)
+}
+
+// hostDetail is GET /domains/{domain}/hosts/{host}. Records come from the
+// latest snapshot taken with --resolve, probe results from the output of
+// the hooks that named the host.
+type hostDetail struct {
+	HostRecord
+	Domain      string      `json:"domain"`
+	ScopeReason string      `json:"scope_reason,omitempty"`
+	FirstSeen   *time.Time  `json:"first_seen,omitempty"` // added to the inventory
+	LastSeen    *time.Time  `json:"last_seen,omitempty"`  // latest scan that returned it
+	LastRun     int         `json:"last_run,omitempty"`
+	Snapshots   int         `json:"snapshots"` // scans that returned it
+	Addrs       []string    `json:"addrs"`
+	CNAME       string      `json:"cname,omitempty"`
+	ResolvedAt  *time.Time  `json:"resolved_at,omitempty"`
+	Takeover    string      `json:"takeover,omitempty"` // service of a dangling CNAME, or "unknown"
+	Probes      []hostProbe `json:"probes"`             // see hostProbes
+}
+
+func (s *apiServer) getHost(w http.ResponseWriter, r *http.Request) {
+	d, ok := domainParam(w, r); if !ok { return }
+	h := segment(r, 3)
+	hosts, err := readLines(filepath.Join(dataDir(), d+".txt")); if err != nil { apiFail(w, err); return }
+	found := false
+	for _, x := range hosts { if x == h { found = true; break } }
+	if !found { writeAPIError(w, http.StatusNotFound, h+" is not in the inventory of "+d); return }
+	det := hostDetail{HostRecord: hostRecords(d, []string{h})[0], Domain: d, Addrs: []string{}}
+	if m, err := domainMatcher(d); err == nil && !m.empty() { _, det.ScopeReason = m.check(h) }
+	for _, b := range newHostBatches(d) {
+		if det.FirstSeen != nil { break }
+		for _, x := range b.Hosts { if x == h { det.FirstSeen = optTime(b.Time); break } }
+	}
+	refs, err := listSnapshots(d); if err != nil { apiFail(w, err); return }
+	var oldest time.Time
+	for i := len(refs) - 1; i >= 0; i-- {
+		snap, err := readSnapshot(refs[i]); if err != nil { apiFail(w, err); return }
+		addrs, ok := snap.Hosts[h]
+		if !ok { continue }
+		det.Snapshots++
+		oldest = snap.Time
+		if det.LastSeen == nil { det.LastSeen, det.LastRun = optTime(snap.Time), snap.Run }
+		if det.ResolvedAt == nil && addrs != nil {
+			det.Addrs, det.CNAME, det.ResolvedAt = addrs, snap.CNAMEs[h], optTime(snap.Time)
+			if det.CNAME != "" && len(addrs) == 0 {
+				if det.Takeover = takeoverService(det.CNAME); det.Takeover == "" { det.Takeover = "unknown" }
+			}
+		}
+	}
+	// hosts of the first scan have no new-host file
+	if det.FirstSeen == nil { det.FirstSeen = optTime(oldest) }
+	runs, err := loadRuns(d); if err != nil { apiFail(w, err); return }
+	if det.Probes = hostProbes(runs, []string{h})[h]; det.Probes == nil { det.Probes = []hostProbe{} }
+	writeAPI(w, http.StatusOK, det)
+}
+
+// searchHit is one result of GET /search.
+type searchHit struct {
+	Domain  string `json:"domain"`
+	Host    string `json:"host"`
+	Unicode string `json:"unicode,omitempty"`
+}
+
+// GET /search?q=substring[&limit=N]: hosts of every inventory
+func (s *apiServer) search(w http.ResponseWriter, r *http.Request) {
+	q := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+	if q == "" { writeAPIError(w, http.StatusBadRequest, "q is required"); return }
+	limit, err := intParam(r, "limit", defaultPageSize); if err != nil { writeAPIError(w, http.StatusBadRequest, err.Error()); return }
+	if limit == 0 || limit > maxSearchResults { limit = maxSearchResults }
+	domains, _ := readLines(filepath.Join(homeDir(), "domains.txt"))
+	sort.Strings(domains)
+	out := []searchHit{}
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		hosts, _ := readLines(filepath.Join(dataDir(), d+".txt"))
+		for _, h := range hosts {
+			u := displayHost(h)
+			if !strings.Contains(h, q) && !strings.Contains(u, q) { continue }
+			hit := searchHit{Domain: d, Host: h}
+			if u != h { hit.Unicode = u }
+			if out = append(out, hit); len(out) >= limit { writeAPI(w, http.StatusOK, out); return }
+		}
+	}
+	writeAPI(w, http.StatusOK, out)
+}
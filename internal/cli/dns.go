@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// dnsResolver is what --resolve looks hosts up with; tests swap in a stub.
+type dnsResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+var resolver dnsResolver = systemResolver{}
+
+// systemResolver gets addresses from the system resolver but asks the name
+// servers of /etc/resolv.conf for the CNAME record itself, over UDP and
+// again over TCP when the answer is truncated: net's LookupCNAME follows
+// the chain and fails when the target does not exist, which is the dangling
+// alias a takeover candidate is. Only the nameserver lines of resolv.conf
+// are read. Without them (Windows) it falls back to LookupCNAME.
+type systemResolver struct{}
+
+func (systemResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
+func (systemResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	servers := nameservers("/etc/resolv.conf")
+	if len(servers) == 0 {
+		c, err := net.DefaultResolver.LookupCNAME(ctx, host)
+		return strings.TrimSuffix(strings.ToLower(c), "."), err
+	}
+	var err error
+	for _, s := range servers {
+		var c string
+		if c, err = queryCNAME(ctx, s, host); err == nil { return c, nil }
+		if ctx.Err() != nil { break }
+	}
+	return "", err
+}
+
+// nameservers returns the "nameserver" entries of a resolv.conf as host:53.
+func nameservers(path string) []string {
+	b, err := os.ReadFile(path); if err != nil { return nil }
+	var out []string
+	for _, l := range strings.Split(string(b), "\n") {
+		f := strings.Fields(l)
+		if len(f) < 2 || f[0] != "nameserver" { continue }
+		ip, _, _ := strings.Cut(f[1], "%") // drop an IPv6 zone
+		if net.ParseIP(ip) != nil { out = append(out, net.JoinHostPort(f[1], "53")) }
+	}
+	return out
+}
+
+const (
+	dnsTypeCNAME = 5
+	dnsClassIN   = 1
+	dnsFlagTC    = 0x02 // truncated, in the first flags byte
+)
+
+// queryCNAME sends one CNAME question for host to server over UDP, or
+// again over TCP when the answer came back truncated, and returns the alias
+// target, or "" when host has no CNAME record. An NXDOMAIN answer is not an
+// error: it still carries the CNAME when only the target is missing.
+func queryCNAME(ctx context.Context, server, host string) (string, error) {
+	host = strings.TrimSuffix(host, ".")
+	q, err := dnsQuery(uint16(rand.Intn(1<<16)), host, dnsTypeCNAME); if err != nil { return "", err }
+	msg, err := dnsExchange(ctx, "udp", server, q)
+	if err == nil && msg[2]&dnsFlagTC != 0 { msg, err = dnsExchange(ctx, "tcp", server, q) }
+	if err != nil { return "", err }
+	return parseCNAMEAnswer(msg, host)
+}
+
+// dnsExchange sends query q to server over network (udp or tcp) and returns
+// the response to it.
+func dnsExchange(ctx context.Context, network, server string, q []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server); if err != nil { return nil, err }
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok { deadline = time.Now().Add(resolveTimeout) }
+	conn.SetDeadline(deadline)
+	if network == "tcp" {
+		// a message over TCP is preceded by its length
+		if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(q))), q...)); err != nil { return nil, err }
+		var l [2]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil { return nil, err }
+		msg := make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(conn, msg); err != nil { return nil, err }
+		if len(msg) < 12 || msg[0] != q[0] || msg[1] != q[1] { return nil, errors.New("dns: answer to another query") }
+		return msg, nil
+	}
+	if _, err := conn.Write(q); err != nil { return nil, err }
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf); if err != nil { return nil, err }
+		if n < 12 || buf[0] != q[0] || buf[1] != q[1] { continue } // not our answer
+		return buf[:n], nil
+	}
+}
+
+// dnsQuery builds a recursive query for name.
+func dnsQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	b := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[2:], 0x0100) // RD
+	binary.BigEndian.PutUint16(b[4:], 1)      // QDCOUNT
+	for _, l := range strings.Split(name, ".") {
+		if l == "" || len(l) > 63 { return nil, fmt.Errorf("dns: bad name %q", name) }
+		b = append(b, byte(len(l)))
+		b = append(b, l...)
+	}
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint16(b, qtype)
+	b = binary.BigEndian.AppendUint16(b, dnsClassIN)
+	return b, nil
+}
+
+var errDNSShort = errors.New("dns: short message")
+
+// parseCNAMEAnswer returns the CNAME target of host in the answer section of
+// msg, lower-cased and without the trailing dot.
+func parseCNAMEAnswer(msg []byte, host string) (string, error) {
+	if len(msg) < 12 { return "", errDNSShort }
+	if msg[2]&0x80 == 0 { return "", errors.New("dns: not a response") }
+	// a truncated answer may lack the record: never read it as "no CNAME"
+	if msg[2]&dnsFlagTC != 0 { return "", errors.New("dns: truncated answer") }
+	switch rcode := msg[3] & 0x0f; rcode {
+	case 0, 3: // NOERROR, NXDOMAIN
+	default:
+		return "", fmt.Errorf("dns: server returned rcode %d", rcode)
+	}
+	qd, an := binary.BigEndian.Uint16(msg[4:]), binary.BigEndian.Uint16(msg[6:])
+	off := 12
+	for i := 0; i < int(qd); i++ {
+		_, next, err := readDNSName(msg, off); if err != nil { return "", err }
+		off = next + 4
+	}
+	for i := 0; i < int(an); i++ {
+		owner, next, err := readDNSName(msg, off); if err != nil { return "", err }
+		if next+10 > len(msg) { return "", errDNSShort }
+		typ := binary.BigEndian.Uint16(msg[next:])
+		rdlen := int(binary.BigEndian.Uint16(msg[next+8:]))
+		rdata := next + 10
+		if rdata+rdlen > len(msg) { return "", errDNSShort }
+		if typ == dnsTypeCNAME && strings.EqualFold(owner, host) {
+			target, _, err := readDNSName(msg, rdata); if err != nil { return "", err }
+			return strings.ToLower(target), nil
+		}
+		off = rdata + rdlen
+	}
+	return "", nil
+}
+
+// readDNSName decodes the (possibly compressed) name at off and returns it
+// with the offset just past it.
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(msg) { return "", 0, errDNSShort }
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if next < 0 { next = off + 1 }
+			return strings.Join(labels, "."), next, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) { return "", 0, errDNSShort }
+			if next < 0 { next = off + 2 }
+			if jumps++; jumps > 10 { return "", 0, errors.New("dns: compression loop") }
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+		case n&0xc0 != 0:
+			return "", 0, errors.New("dns: bad label")
+		default:
+			if off+1+n > len(msg) { return "", 0, errDNSShort }
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
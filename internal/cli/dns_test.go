@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// dnsAnswer builds the response to query q: rcode plus one CNAME record
+// for the question name pointing at target (a compressed owner name).
+func dnsAnswer(q []byte, rcode byte, target string) []byte {
+	m := append([]byte{}, q...)
+	m[2] |= 0x80 // QR
+	m[3] = 0x80 | rcode
+	if target == "" { return m }
+	binary.BigEndian.PutUint16(m[6:], 1)
+	m = append(m, 0xc0, 12) // owner: the question name
+	m = binary.BigEndian.AppendUint16(m, dnsTypeCNAME)
+	m = binary.BigEndian.AppendUint16(m, dnsClassIN)
+	m = append(m, 0, 0, 1, 0)
+	rd, _ := dnsQuery(0, target, 0)
+	rd = rd[12 : len(rd)-4]
+	m = binary.BigEndian.AppendUint16(m, uint16(len(rd)))
+	return append(m, rd...)
+}
+
+func TestQueryCNAME(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0"); if err != nil { t.Skip(err) }
+	defer pc.Close()
+	answers := map[string]func(q []byte) []byte{
+		"dangling.example.com": func(q []byte) []byte { return dnsAnswer(q, 3, "gone.s3.amazonaws.com") }, // target NXDOMAIN
+		"www.example.com":      func(q []byte) []byte { return dnsAnswer(q, 0, "Example.GitHub.io") },
+		"plain.example.com":    func(q []byte) []byte { return dnsAnswer(q, 0, "") },
+		"broken.example.com":   func(q []byte) []byte { return dnsAnswer(q, 2, "") }, // SERVFAIL
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf); if err != nil { return }
+			q := append([]byte{}, buf[:n]...)
+			name, _, err := readDNSName(q, 12); if err != nil { continue }
+			if f := answers[name]; f != nil { pc.WriteTo(f(q), addr) }
+		}
+	}()
+	server := pc.LocalAddr().String()
+	for _, tt := range []struct{ host, want string; fail bool }{
+		{"dangling.example.com", "gone.s3.amazonaws.com", false},
+		{"www.example.com.", "example.github.io", false},
+		{"plain.example.com", "", false},
+		{"broken.example.com", "", true},
+	} {
+		got, err := queryCNAME(context.Background(), server, tt.host)
+		if (err != nil) != tt.fail || got != tt.want { t.Errorf("queryCNAME(%q) = %q, %v; want %q, fail %v", tt.host, got, err, tt.want, tt.fail) }
+	}
+}
+
+func TestQueryCNAMETruncated(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0"); if err != nil { t.Skip(err) }
+	defer pc.Close()
+	ln, err := net.Listen("tcp", pc.LocalAddr().String()); if err != nil { t.Skip(err) }
+	defer ln.Close()
+	tcpAnswers := map[string]string{"big.example.com": "big.azurewebsites.net", "trunc.example.com": ""}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf); if err != nil { return }
+			m := dnsAnswer(buf[:n], 0, "")
+			m[2] |= dnsFlagTC // too large for UDP: the record is left out
+			pc.WriteTo(m, addr)
+		}
+	}()
+	go func() {
+		for {
+			c, err := ln.Accept(); if err != nil { return }
+			var l [2]byte
+			if _, err := io.ReadFull(c, l[:]); err != nil { c.Close(); continue }
+			q := make([]byte, binary.BigEndian.Uint16(l[:]))
+			if _, err := io.ReadFull(c, q); err != nil { c.Close(); continue }
+			name, _, _ := readDNSName(q, 12)
+			m := dnsAnswer(q, 0, tcpAnswers[name])
+			if name == "trunc.example.com" { m[2] |= dnsFlagTC }
+			c.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(m))), m...))
+			c.Close()
+		}
+	}()
+	server := pc.LocalAddr().String()
+	if got, err := queryCNAME(context.Background(), server, "big.example.com"); err != nil || got != "big.azurewebsites.net" {
+		t.Errorf("truncated over UDP: %q, %v; want the CNAME from the TCP answer", got, err)
+	}
+	if got, err := queryCNAME(context.Background(), server, "trunc.example.com"); err == nil { t.Errorf("truncated over TCP too: %q, want an error", got) }
+}
+
+func TestReadDNSNameLoop(t *testing.T) {
+	msg := make([]byte, 14)
+	msg[12], msg[13] = 0xc0, 12 // points at itself
+	if _, _, err := readDNSName(msg, 12); err == nil { t.Error("compression loop accepted") }
+}
+
+func TestNameservers(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "resolv.conf")
+	os.WriteFile(p, []byte("# comment\nsearch lan\nnameserver 192.0.2.53\nnameserver fe80::1%eth0\nnameserver bogus\noptions ndots:1\n"), 0o644)
+	want := []string{"192.0.2.53:53", "[fe80::1%eth0]:53"}
+	if got := nameservers(p); !reflect.DeepEqual(got, want) { t.Errorf("nameservers = %q, want %q", got, want) }
+}
+
+// stubResolver answers from fixed tables; missing hosts are NXDOMAIN.
+type stubResolver struct {
+	addrs  map[string][]string
+	cnames map[string]string
+}
+
+func (r stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if a, ok := r.addrs[host]; ok { return a, nil }
+	return nil, errors.New("no such host")
+}
+
+func (r stubResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	return r.cnames[host], nil
+}
+
+func TestResolveHostsDangling(t *testing.T) {
+	old := resolver
+	defer func() { resolver = old }()
+	resolver = stubResolver{
+		addrs:  map[string][]string{"a.example.com": {"192.0.2.2", "192.0.2.1"}, "www.example.com": {"192.0.2.3"}},
+		cnames: map[string]string{"www.example.com": "example.github.io", "old.example.com": "gone.s3.amazonaws.com"},
+	}
+	got := resolveHosts(context.Background(), []string{"a.example.com", "www.example.com", "old.example.com", "none.example.com"})
+	want := map[string]resolution{
+		"a.example.com":    {Addrs: []string{"192.0.2.1", "192.0.2.2"}},
+		"www.example.com":  {Addrs: []string{"192.0.2.3"}, CNAME: "example.github.io"},
+		"old.example.com":  {CNAME: "gone.s3.amazonaws.com"},
+		"none.example.com": {},
+	}
+	if !reflect.DeepEqual(got, want) { t.Errorf("resolveHosts = %+v\nwant %+v", got, want) }
+}
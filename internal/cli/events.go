@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every scan, whichever process runs it (scan, daemon, serve), appends its
+// events to one JSON-lines log in the home directory. Event IDs increase
+// across processes, so a consumer that remembers the last ID it handled can
+// resume from there (`serve`'s /api/v1/events, or by reading the file).
+const (
+	EventsRelPath   = "events.jsonl"
+	maxEventsBytes  = 16 << 20 // past this the older half of the log is dropped
+	eventsPoll      = time.Second
+	eventsKeepAlive = 15 * time.Second
+)
+
+// Event types.
+const (
+	EventScanStarted   = "scan_started"
+	EventHostAdded     = "host_added"     // new in the inventory
+	EventHostRemoved   = "host_removed"   // returned by the previous scan, not by this one
+	EventRecordChanged = "record_changed" // addresses or CNAME changed (scans with --resolve)
+	EventScanFinished  = "scan_finished"
+)
+
+func eventsPath() string { return filepath.Join(homeDir(), EventsRelPath) }
+
+// Event is one line of the event log. Fields beyond id, type, time and
+// domain depend on the type.
+type Event struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Domain    string    `json:"domain"`
+	Run       int       `json:"run,omitempty"`
+	Trigger   string    `json:"trigger,omitempty"` // scan_started
+	Host      string    `json:"host,omitempty"`
+	InScope   *bool     `json:"in_scope,omitempty"` // host_added
+	From      []string  `json:"from,omitempty"`     // record_changed
+	To        []string  `json:"to,omitempty"`
+	FromCNAME string    `json:"from_cname,omitempty"`
+	ToCNAME   string    `json:"to_cname,omitempty"`
+	// scan_finished
+	Status string `json:"status,omitempty"`
+	Total  int    `json:"total,omitempty"`
+	New    int    `json:"new,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// appendEvents numbers evs after the last logged event and appends them.
+// The "events" lock orders writers of all processes.
+func appendEvents(evs ...Event) error {
+	if len(evs) == 0 { return nil }
+	l, err := acquireLock(context.Background(), "events", DefaultHomeLockWait, io.Discard); if err != nil { return err }
+	defer l.unlock()
+	last, size, err := lastEventID(); if err != nil { return err }
+	if size > maxEventsBytes {
+		if err := trimEvents(); err != nil { return err }
+	}
+	var b []byte
+	for i := range evs {
+		last++
+		evs[i].ID = last
+		if evs[i].Time.IsZero() { evs[i].Time = time.Now() }
+		line, _ := json.Marshal(evs[i])
+		b = append(append(b, line...), '\n')
+	}
+	f, err := os.OpenFile(eventsPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); if err != nil { return err }
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil { err = cerr }
+	return err
+}
+
+// lastEventID reads the ID of the last complete line and the log size.
+func lastEventID() (int64, int64, error) {
+	f, err := os.Open(eventsPath())
+	if os.IsNotExist(err) { return 0, 0, nil }
+	if err != nil { return 0, 0, err }
+	defer f.Close()
+	st, err := f.Stat(); if err != nil { return 0, 0, err }
+	off := st.Size() - 64*1024
+	if off < 0 { off = 0 }
+	b := make([]byte, st.Size()-off)
+	if _, err := f.ReadAt(b, off); err != nil && err != io.EOF { return 0, 0, err }
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		var ev Event
+		if json.Unmarshal([]byte(lines[i]), &ev) == nil && ev.ID > 0 { return ev.ID, st.Size(), nil }
+	}
+	return 0, st.Size(), nil
+}
+
+// trimEvents keeps the newer half of the log. Callers hold the events lock.
+func trimEvents() error {
+	b, err := os.ReadFile(eventsPath()); if err != nil { return err }
+	b = b[len(b)/2:]
+	if i := strings.IndexByte(string(b), '\n'); i >= 0 { b = b[i+1:] }
+	return writeFileAtomic(eventsPath(), b, 0o644)
+}
+
+// eventCursor follows the event log from after on.
+type eventCursor struct {
+	after  int64 // last ID handed out
+	offset int64
+	file   os.FileInfo
+}
+
+// next returns the events logged since the last call that keep accepts, at
+// most max of them (0 = all); the cursor stops after the last one returned.
+// A log that was replaced (trimmed) is read again from its start.
+func (c *eventCursor) next(keep func(Event) bool, max int) ([]Event, error) {
+	f, err := os.Open(eventsPath())
+	if os.IsNotExist(err) { return nil, nil }
+	if err != nil { return nil, err }
+	defer f.Close()
+	st, err := f.Stat(); if err != nil { return nil, err }
+	if c.file == nil || !os.SameFile(c.file, st) || st.Size() < c.offset { c.offset = 0 }
+	c.file = st
+	if _, err := f.Seek(c.offset, io.SeekStart); err != nil { return nil, err }
+	var out []Event
+	r := bufio.NewReader(f)
+	for max <= 0 || len(out) < max {
+		line, err := r.ReadBytes('\n')
+		if err != nil { break } // a partial last line is read again next time
+		c.offset += int64(len(line))
+		if id, ok := lineEventID(line); ok && id <= c.after { continue }
+		var ev Event
+		if json.Unmarshal(line, &ev) != nil || ev.ID <= c.after { continue }
+		c.after = ev.ID
+		if keep == nil || keep(ev) { out = append(out, ev) }
+	}
+	return out, nil
+}
+
+// lineEventID reads the id that starts every line appendEvents writes, so
+// lines before a cursor are skipped without decoding them.
+func lineEventID(line []byte) (int64, bool) {
+	rest, ok := bytes.CutPrefix(line, []byte(`{"id":`)); if !ok { return 0, false }
+	i := bytes.IndexByte(rest, ',')
+	if i < 0 { return 0, false }
+	id, err := strconv.ParseInt(string(rest[:i]), 10, 64)
+	return id, err == nil
+}
+
+// scanEvents lists the host events of a finished scan: added hosts and the
+// difference to the previous scan's snapshot (nil for the first scan).
+func scanEvents(domain string, run int, added, oos []string, prev, cur *snapshot) []Event {
+	var evs []Event
+	yes, no := true, false
+	for _, h := range added { evs = append(evs, Event{Type: EventHostAdded, Domain: domain, Run: run, Host: h, InScope: &yes}) }
+	for _, h := range oos { evs = append(evs, Event{Type: EventHostAdded, Domain: domain, Run: run, Host: h, InScope: &no}) }
+	if prev == nil { return evs }
+	d := diffSnapshots(domain, prev, cur)
+	for _, h := range d.Removed { evs = append(evs, Event{Type: EventHostRemoved, Domain: domain, Run: run, Host: h}) }
+	for _, c := range d.Changed { evs = append(evs, Event{Type: EventRecordChanged, Domain: domain, Run: run, Host: c.Host, From: c.From, To: c.To, FromCNAME: c.FromCNAME, ToCNAME: c.ToCNAME}) }
+	return evs
+}
+
+// emit appends evs and reports a failure on errw; events never fail a scan.
+// fn (scanOptions.OnEvent) gets them afterwards, numbered.
+func emit(errw io.Writer, fn func(Event), evs ...Event) {
+	if err := appendEvents(evs...); err != nil { fmt.Fprintln(errw, "error: event log:", err) }
+	if fn == nil { return }
+	for _, ev := range evs { fn(ev) }
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func appendTestEvents(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := appendEvents(Event{Type: EventHostAdded, Domain: "example.com", Host: "h.example.com"}); err != nil { t.Fatal(err) }
+	}
+}
+
+func eventIDs(t *testing.T, c *eventCursor) []int64 {
+	t.Helper()
+	evs, err := c.next(nil, 0); if err != nil { t.Fatal(err) }
+	var ids []int64
+	for _, ev := range evs { ids = append(ids, ev.ID) }
+	return ids
+}
+
+func TestEventCursorResume(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	c := &eventCursor{}
+	if ids := eventIDs(t, c); ids != nil { t.Errorf("no log: got %v", ids) }
+	appendTestEvents(t, 5)
+	if ids := eventIDs(t, &eventCursor{after: 3}); !reflect.DeepEqual(ids, []int64{4, 5}) { t.Errorf("after 3: got %v, want [4 5]", ids) }
+	if ids := eventIDs(t, c); len(ids) != 5 { t.Errorf("from the start: got %v", ids) }
+	appendTestEvents(t, 2)
+	if ids := eventIDs(t, c); !reflect.DeepEqual(ids, []int64{6, 7}) { t.Errorf("next call: got %v, want [6 7]", ids) }
+	if ids := eventIDs(t, c); ids != nil { t.Errorf("nothing new: got %v", ids) }
+
+	// keep filters, but filtered events still advance the cursor
+	appendTestEvents(t, 1)
+	evs, err := c.next(func(Event) bool { return false }, 0); if err != nil || len(evs) != 0 { t.Errorf("filtered: %v %v", evs, err) }
+	if c.after != 8 { t.Errorf("after = %d, want 8", c.after) }
+}
+
+func TestEventCursorLimit(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	appendTestEvents(t, 6)
+	c := &eventCursor{after: 1}
+	odd := func(ev Event) bool { return ev.ID%2 == 1 }
+	evs, err := c.next(odd, 1); if err != nil { t.Fatal(err) }
+	// reading stops at the limit: the next call goes on from there
+	if len(evs) != 1 || evs[0].ID != 3 || c.after != 3 { t.Errorf("limit 1: got %v, cursor after %d", evs, c.after) }
+	evs, err = c.next(odd, 5); if err != nil { t.Fatal(err) }
+	if len(evs) != 1 || evs[0].ID != 5 || c.after != 6 { t.Errorf("rest: got %v, cursor after %d", evs, c.after) }
+	if id, ok := lineEventID([]byte(`{"id":42,"type":"host_added"}`)); !ok || id != 42 { t.Errorf("lineEventID = %d, %v", id, ok) }
+}
+
+func TestEventCursorPartialLine(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	appendTestEvents(t, 2)
+	line := `{"id":3,"type":"host_added","time":"2026-01-02T03:04:05Z","domain":"example.com"}` + "\n"
+	f, err := os.OpenFile(eventsPath(), os.O_WRONLY|os.O_APPEND, 0o644); if err != nil { t.Fatal(err) }
+	defer f.Close()
+	// a writer is halfway through its line
+	if _, err := f.WriteString(line[:20]); err != nil { t.Fatal(err) }
+	c := &eventCursor{}
+	if ids := eventIDs(t, c); !reflect.DeepEqual(ids, []int64{1, 2}) { t.Errorf("with a partial line: got %v, want [1 2]", ids) }
+	if _, err := f.WriteString(line[20:]); err != nil { t.Fatal(err) }
+	if ids := eventIDs(t, c); !reflect.DeepEqual(ids, []int64{3}) { t.Errorf("completed line: got %v, want [3]", ids) }
+	if id, _, err := lastEventID(); err != nil || id != 3 { t.Errorf("lastEventID = %d, %v; want 3", id, err) }
+}
+
+func TestEventCursorTrimmedLog(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	appendTestEvents(t, 10)
+	c := &eventCursor{}
+	if ids := eventIDs(t, c); len(ids) != 10 { t.Fatalf("got %v", ids) }
+
+	// the log is replaced by its newer half: nothing is handed out twice
+	if err := trimEvents(); err != nil { t.Fatal(err) }
+	if ids := eventIDs(t, c); ids != nil { t.Errorf("after trim: got %v, want nothing", ids) }
+	appendTestEvents(t, 2)
+	if ids := eventIDs(t, c); !reflect.DeepEqual(ids, []int64{11, 12}) { t.Errorf("after trim and append: got %v, want [11 12]", ids) }
+
+	// a replacement larger than the old offset is detected by file identity
+	b, err := os.ReadFile(eventsPath()); if err != nil { t.Fatal(err) }
+	if err := trimEvents(); err != nil { t.Fatal(err) }
+	appendTestEvents(t, 1)
+	if err := writeFileAtomic(eventsPath(), append(b, mustReadFile(t, eventsPath())...), 0o644); err != nil { t.Fatal(err) }
+	if ids := eventIDs(t, c); !reflect.DeepEqual(ids, []int64{13}) { t.Errorf("after replacement: got %v, want [13]", ids) }
+}
+
+func mustReadFile(t *testing.T, p string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(p); if err != nil { t.Fatal(err) }
+	return b
+}
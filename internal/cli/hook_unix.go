@@ -0,0 +1,22 @@
+//go:build unix
+
+package cli
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// hookCommand runs command with sh in its own process group, so a timeout
+// also stops the tools the command started.
+func hookCommand(ctx context.Context, command string) *exec.Cmd {
+	c := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.Cancel = func() error { return syscall.Kill(-c.Process.Pid, syscall.SIGKILL) }
+	return c
+}
+
+// shellQuote quotes s as one sh word.
+func shellQuote(s string) string { return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'" }
@@ -0,0 +1,17 @@
+//go:build windows
+
+package cli
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// hookCommand runs command with cmd.exe.
+func hookCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
+
+// shellQuote quotes s as one cmd.exe argument.
+func shellQuote(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` }
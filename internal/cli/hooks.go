@@ -0,0 +1,321 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hooks chain external tools (httpx, nuclei, naabu, ...) to discoveries.
+// After a scan added in-scope hosts, every hook that applies to the domain
+// runs in turn, still under the domain lock, with the hosts one per line on
+// stdin or in a temp file and DOMAIN, SCAN_ID (the run number) and COUNT in
+// its environment. What it prints is kept with the run.
+const (
+	HooksRelPath       = "hooks.json"
+	DefaultHookTimeout = 10 * time.Minute
+	maxHookOutput      = 16 << 10 // bytes of output kept per hook, the tail
+	hookNotifyLines    = 20
+	maxProbeLines      = 20 // output lines kept per host and hook
+)
+
+// Hook is one entry of hooks.json. Command may use {domain}, {scan_id},
+// {count} and {file}; values are shell-quoted. Using {file} implies input
+// "file".
+type Hook struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command"`
+	Input   string   `json:"input,omitempty"`   // stdin (default) or file
+	Timeout string   `json:"timeout,omitempty"` // default 10m
+	Domains []string `json:"domains,omitempty"` // only these domains; empty = all
+	Notify  bool     `json:"notify,omitempty"`  // send the output's last lines to the notifiers
+}
+
+// HookResult is stored in the run record.
+type HookResult struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"` // ok, failed, timeout, interrupted
+	ExitCode int     `json:"exit_code,omitempty"`
+	Duration float64 `json:"duration_s"`
+	Output   string  `json:"output,omitempty"` // stdout and stderr
+	Error    string  `json:"error,omitempty"`
+}
+
+var hookNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func hooksPath() string { return filepath.Join(homeDir(), HooksRelPath) }
+
+func loadHooks() ([]Hook, error) {
+	var hooks []Hook
+	b, err := os.ReadFile(hooksPath())
+	if err != nil {
+		if os.IsNotExist(err) { return nil, nil }
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &hooks); err != nil { return nil, fmt.Errorf("%s: %w", hooksPath(), err) }
+	return hooks, nil
+}
+
+func saveHooks(hooks []Hook) error {
+	b, _ := json.MarshalIndent(hooks, "", "  ")
+	return writeFileAtomic(hooksPath(), b, 0o644)
+}
+
+func (h Hook) validate() error {
+	if !hookNameRe.MatchString(h.Name) { return fmt.Errorf("bad hook name %q (letters, digits, . _ -)", h.Name) }
+	if strings.TrimSpace(h.Command) == "" { return errors.New("empty hook command") }
+	if h.Input != "" && h.Input != "stdin" && h.Input != "file" { return fmt.Errorf("hook input must be stdin or file, not %q", h.Input) }
+	if _, err := h.timeout(); err != nil { return err }
+	return nil
+}
+
+func (h Hook) timeout() (time.Duration, error) {
+	if h.Timeout == "" { return DefaultHookTimeout, nil }
+	d, err := time.ParseDuration(h.Timeout)
+	if err != nil || d <= 0 { return 0, fmt.Errorf("hook timeout must be a positive duration like 10m, not %q", h.Timeout) }
+	return d, nil
+}
+
+func (h Hook) applies(domain string) bool {
+	if len(h.Domains) == 0 { return true }
+	for _, d := range h.Domains { if strings.EqualFold(d, domain) { return true } }
+	return false
+}
+
+func (h Hook) usesFile() bool { return h.Input == "file" || strings.Contains(h.Command, "{file}") }
+
+// runHooks runs the hooks that apply to domain on hosts. A broken
+// hooks.json or a failing hook is reported, never fails the scan.
+func runHooks(ctx context.Context, domain, scanID string, hosts []string, out, errw io.Writer) []HookResult {
+	hooks, err := loadHooks()
+	if err != nil { fmt.Fprintln(errw, "error: hooks:", err); return nil }
+	var results []HookResult
+	for _, h := range hooks {
+		if !h.applies(domain) { continue }
+		if ctx.Err() != nil { break }
+		r := runHook(ctx, h, domain, scanID, hosts)
+		results = append(results, r)
+		line := fmt.Sprintf("hook %s: %s (%s)", h.Name, r.Status, time.Duration(r.Duration*float64(time.Second)).Round(time.Millisecond))
+		if r.Error != "" { line += ": " + r.Error }
+		if r.Status == "ok" { fmt.Fprintln(out, line) } else { fmt.Fprintln(errw, line) }
+		if h.Notify && ctx.Err() == nil { notifyHook(ctx, errw, domain, len(hosts), r) }
+	}
+	return results
+}
+
+func runHook(ctx context.Context, h Hook, domain, scanID string, hosts []string) (r HookResult) {
+	r = HookResult{Name: h.Name}
+	start := time.Now()
+	defer func() { r.Duration = time.Since(start).Seconds() }()
+	timeout, err := h.timeout()
+	if err != nil { r.Status, r.Error = "failed", err.Error(); return r }
+	input := strings.Join(hosts, "\n") + "\n"
+	vars := map[string]string{"domain": domain, "scan_id": scanID, "count": strconv.Itoa(len(hosts))}
+	env := append(os.Environ(), "DOMAIN="+domain, "SCAN_ID="+scanID, "COUNT="+vars["count"])
+	if h.usesFile() {
+		f, err := os.CreateTemp("", "domwatch-hook-*.txt")
+		if err != nil { r.Status, r.Error = "failed", err.Error(); return r }
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(input)
+		if cerr := f.Close(); err == nil { err = cerr }
+		if err != nil { r.Status, r.Error = "failed", err.Error(); return r }
+		vars["file"] = f.Name()
+		env = append(env, "HOSTS_FILE="+f.Name())
+	}
+	hctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	c := hookCommand(hctx, expandHook(h.Command, vars))
+	c.Env = env
+	// tools still holding the pipes after the hook exits are not waited for
+	c.WaitDelay = 5 * time.Second
+	if !h.usesFile() { c.Stdin = strings.NewReader(input) }
+	buf := &tailBuffer{max: maxHookOutput}
+	c.Stdout, c.Stderr = buf, buf
+	err = c.Run()
+	r.Output = buf.String()
+	var ee *exec.ExitError
+	switch {
+	case err == nil:
+		r.Status = "ok"
+	case ctx.Err() != nil:
+		r.Status, r.Error = "interrupted", "interrupted"
+	case hctx.Err() != nil:
+		r.Status, r.Error = "timeout", "timed out after "+timeout.String()
+	case errors.As(err, &ee):
+		r.Status, r.ExitCode, r.Error = "failed", ee.ExitCode(), err.Error()
+	default:
+		r.Status, r.Error = "failed", err.Error()
+	}
+	return r
+}
+
+// expandHook replaces {name} placeholders with shell-quoted values.
+func expandHook(command string, vars map[string]string) string {
+	var pairs []string
+	for k, v := range vars { pairs = append(pairs, "{"+k+"}", shellQuote(v)) }
+	return strings.NewReplacer(pairs...).Replace(command)
+}
+
+func notifyHook(ctx context.Context, errw io.Writer, domain string, count int, r HookResult) {
+	title := fmt.Sprintf("🔗 Hook **%s** for **%s** (%d new): %s — %s", r.Name, domain, count, r.Status, time.Now().Format(time.RFC3339))
+	out := strings.Split(strings.TrimRight(r.Output, "\n"), "\n")
+	if len(out) > hookNotifyLines { out = out[len(out)-hookNotifyLines:] }
+	var lines []string
+	for _, l := range out {
+		if l = strings.TrimSpace(l); l == "" { continue }
+		if len(l) > 300 { l = l[:300] + "…" }
+		lines = append(lines, "`"+strings.ReplaceAll(l, "`", "'")+"`")
+	}
+	if len(lines) == 0 { lines = []string{"(no output)"} }
+	notify(ctx, errw, title, lines)
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max       int
+	b         []byte
+	truncated bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.b = append(t.b, p...)
+	if len(t.b) > t.max { t.b = append(t.b[:0:0], t.b[len(t.b)-t.max:]...); t.truncated = true }
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	if !t.truncated { return string(t.b) }
+	s := string(t.b)
+	if i := strings.IndexByte(s, '\n'); i >= 0 { s = s[i+1:] }
+	return "[...]\n" + s
+}
+
+// ---------- command ----------
+func cmdHook(ctx context.Context, a cmdArgs) int {
+	switch a.sub {
+	case "list":
+		hooks, err := loadHooks(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+		if len(hooks) == 0 { fmt.Println("no hooks; add one with: domwatch hook add <name> --cmd '...'"); return 0 }
+		for _, h := range hooks {
+			in, t, doms := "stdin", h.Timeout, "all domains"
+			if h.usesFile() { in = "file" }
+			if t == "" { t = DefaultHookTimeout.String() }
+			if len(h.Domains) > 0 { doms = strings.Join(h.Domains, ", ") }
+			notif := ""; if h.Notify { notif = ", notify" }
+			fmt.Printf("%s\t%s\n\t(%s input, timeout %s, %s%s)\n", h.Name, h.Command, in, t, doms, notif)
+		}
+		return 0
+	case "add":
+		return hookAdd(a)
+	case "rm":
+		name := a.args[0]
+		hooks, err := loadHooks(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+		i := hookIndex(hooks, name)
+		if i < 0 { fmt.Fprintln(os.Stderr, "error: no hook named", name); return 1 }
+		name = hooks[i].Name
+		if err := saveHooks(append(hooks[:i:i], hooks[i+1:]...)); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+		fmt.Println("removed hook", name)
+		return 0
+	}
+	// test
+	name, hosts := a.args[0], a.args[2:]
+	domain, err := domainArg(a, 1); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 2 }
+	hooks, err := loadHooks(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	i := hookIndex(hooks, name)
+	if i < 0 { fmt.Fprintln(os.Stderr, "error: no hook named", name); return 1 }
+	h := hooks[i]
+	if len(hosts) == 0 { hosts = latestNewHosts(domain) }
+	if len(hosts) == 0 { fmt.Println("no hosts for", domain); return 0 }
+	r := runHook(ctx, h, domain, "test", hosts)
+	fmt.Print(r.Output)
+	fmt.Printf("hook %s: %s on %d host(s) (%s)\n", h.Name, r.Status, len(hosts), time.Duration(r.Duration*float64(time.Second)).Round(time.Millisecond))
+	if r.Status != "ok" { fmt.Fprintln(os.Stderr, "error:", r.Error); return 1 }
+	return 0
+}
+
+// hookIndex finds hook name; names match case-insensitively, as domains do.
+func hookIndex(hooks []Hook, name string) int {
+	for i, h := range hooks { if strings.EqualFold(h.Name, name) { return i } }
+	return -1
+}
+
+func hookAdd(a cmdArgs) int {
+	name := a.args[0]
+	h := Hook{Name: name, Command: a.str("cmd"), Notify: a.bool("notify")}
+	if a.isSet("input") { h.Input = a.str("input") }
+	if a.isSet("timeout") {
+		if a.duration("timeout") <= 0 { return a.usage("--timeout must be a positive duration like 10m") }
+		h.Timeout = a.duration("timeout").String()
+	}
+	for _, d := range a.list("domain") {
+		n, err := normalizeDomain(d); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 2 }
+		h.Domains = append(h.Domains, n)
+	}
+	if err := h.validate(); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 2 }
+	hooks, err := loadHooks(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	verb := "added"
+	if i := hookIndex(hooks, name); i >= 0 { hooks[i], verb = h, "updated" } else { hooks = append(hooks, h) }
+	if err := saveHooks(hooks); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	fmt.Printf("%s hook %s\n", verb, name)
+	return 0
+}
+
+// hostProbe is what one hook printed about a host: the lines of its output
+// that name the host, from the newest run whose output does. This is where
+// probe results (httpx, nuclei, ...) come from; domwatch itself does not
+// connect to hosts.
+type hostProbe struct {
+	Hook   string    `json:"hook"`
+	Run    int       `json:"run"`
+	At     time.Time `json:"at"`
+	Status string    `json:"status"` // of the hook
+	Lines  []string  `json:"lines"`
+}
+
+// hostProbes finds the hook output about hosts in runs (oldest first, as
+// loadRuns returns them), one entry per host and hook, sorted by hook.
+func hostProbes(runs []RunRecord, hosts []string) map[string][]hostProbe {
+	want := map[string]bool{}
+	for _, h := range hosts { want[h] = true }
+	out := map[string][]hostProbe{}
+	done := map[[2]string]bool{} // host, hook: a newer run named the host
+	for i := len(runs) - 1; i >= 0; i-- {
+		for _, r := range runs[i].Hooks {
+			found := map[string]*hostProbe{}
+			for _, l := range strings.Split(r.Output, "\n") {
+				if l = strings.TrimSpace(l); l == "" { continue }
+				for h := range lineHosts(l) {
+					if !want[h] || done[[2]string{h, r.Name}] { continue }
+					p := found[h]
+					if p == nil { p = &hostProbe{Hook: r.Name, Run: runs[i].Seq, At: runs[i].Start, Status: r.Status}; found[h] = p }
+					if len(p.Lines) < maxProbeLines { p.Lines = append(p.Lines, l) }
+				}
+			}
+			for h, p := range found { done[[2]string{h, r.Name}] = true; out[h] = append(out[h], *p) }
+		}
+	}
+	for _, ps := range out { sort.Slice(ps, func(i, j int) bool { return ps[i].Hook < ps[j].Hook }) }
+	return out
+}
+
+// lineHosts returns the host names in a line of tool output, such as the
+// host of a URL: the runs of letters, digits, dots, dashes and underscores.
+func lineHosts(line string) map[string]bool {
+	out := map[string]bool{}
+	for _, f := range strings.FieldsFunc(strings.ToLower(line), func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_')
+	}) {
+		if f = strings.Trim(f, "."); strings.Contains(f, ".") { out[f] = true }
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+//go:build unix
+
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandHookQuoting(t *testing.T) {
+	vals := []string{"example.com", "a b", "it's", `$(touch pwned); "x" ` + "`id`", "x;y|z&&w>v", ""}
+	dir := t.TempDir()
+	for _, v := range vals {
+		cmd := expandHook("printf '%s' {domain}", map[string]string{"domain": v})
+		c := exec.Command("/bin/sh", "-c", cmd)
+		c.Dir = dir
+		out, err := c.Output()
+		if err != nil { t.Errorf("%s: %v", cmd, err); continue }
+		if string(out) != v { t.Errorf("%s printed %q, want %q", cmd, out, v) }
+	}
+	if ents, _ := os.ReadDir(dir); len(ents) > 0 { t.Errorf("quoted value ran a command: %s exists", ents[0].Name()) }
+	if got := expandHook("x {count} {unknown} {count}", map[string]string{"count": "3"}); got != "x '3' {unknown} '3'" { t.Errorf("expandHook = %q", got) }
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 12}
+	io.WriteString(b, "short\n")
+	if got := b.String(); got != "short\n" { t.Errorf("String() = %q", got) }
+	io.WriteString(b, "line two\nline three\n")
+	// the partial first line of the tail is dropped
+	if got := b.String(); got != "[...]\nline three\n" { t.Errorf("truncated String() = %q", got) }
+	if len(b.b) != 12 { t.Errorf("kept %d bytes, want 12", len(b.b)) }
+}
+
+// setupHooks writes hooks to a temp home and returns a directory for scripts.
+func setupHooks(t *testing.T, hooks ...Hook) string {
+	t.Helper()
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	if err := saveHooks(hooks); err != nil { t.Fatal(err) }
+	return t.TempDir()
+}
+
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte("#!/bin/sh\n"+body), 0o755); err != nil { t.Fatal(err) }
+	return p
+}
+
+func TestRunHooksInput(t *testing.T) {
+	dir := t.TempDir()
+	env := writeScript(t, dir, "env.sh", `echo "$DOMAIN $SCAN_ID $COUNT ${HOSTS_FILE:-nofile}"; echo "arg=$1"; cat ${1:+"$1"}`)
+	hosts := []string{"a.example.com", "b$(id).example.com"}
+	setupHooks(t,
+		Hook{Name: "stdin", Command: env},
+		Hook{Name: "file", Command: env + " {file}"},
+		Hook{Name: "other", Command: "echo should not run", Domains: []string{"example.org"}},
+	)
+	rs := runHooks(context.Background(), "example.com", "7", hosts, io.Discard, io.Discard)
+	if len(rs) != 2 { t.Fatalf("ran %d hooks, want 2 (example.org's skipped): %+v", len(rs), rs) }
+	for _, r := range rs {
+		if r.Status != "ok" { t.Errorf("%s: status %s (%s)", r.Name, r.Status, r.Error) }
+	}
+	lines := strings.Split(strings.TrimSpace(rs[0].Output), "\n")
+	if want := []string{"example.com 7 2 nofile", "arg=", "a.example.com", "b$(id).example.com"}; strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("stdin hook printed %q, want %q", lines, want)
+	}
+	lines = strings.Split(strings.TrimSpace(rs[1].Output), "\n")
+	if len(lines) != 4 { t.Fatalf("file hook printed %q", lines) }
+	file := strings.Fields(lines[0])[3]
+	if lines[0] != "example.com 7 2 "+file || lines[1] != "arg="+file { t.Errorf("HOSTS_FILE and {file} differ: %q", lines[:2]) }
+	if lines[2] != hosts[0] || lines[3] != hosts[1] { t.Errorf("file hook read %q, and stdin must be empty", lines[2:]) }
+	if _, err := os.Stat(file); !os.IsNotExist(err) { t.Errorf("hosts file %s not removed", file) }
+}
+
+func TestRunHookStatus(t *testing.T) {
+	dir := t.TempDir()
+	fail := writeScript(t, dir, "fail.sh", "echo oops >&2; exit 3\n")
+	hang := writeScript(t, dir, "hang.sh", "sleep 30\n")
+	hosts := []string{"a.example.com"}
+
+	if r := runHook(context.Background(), Hook{Name: "ok", Command: "true"}, "example.com", "1", hosts); r.Status != "ok" || r.Error != "" {
+		t.Errorf("ok hook: %+v", r)
+	}
+	r := runHook(context.Background(), Hook{Name: "fail", Command: fail}, "example.com", "1", hosts)
+	if r.Status != "failed" || r.ExitCode != 3 || r.Output != "oops\n" { t.Errorf("failing hook: %+v", r) }
+
+	start := time.Now()
+	r = runHook(context.Background(), Hook{Name: "slow", Command: hang, Timeout: "200ms"}, "example.com", "1", hosts)
+	if r.Status != "timeout" { t.Errorf("slow hook: %+v", r) }
+	if d := time.Since(start); d > 5*time.Second { t.Errorf("timeout took %s; the script was not killed", d) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	r = runHook(ctx, Hook{Name: "cut", Command: hang}, "example.com", "1", hosts)
+	if r.Status != "interrupted" { t.Errorf("interrupted hook: %+v", r) }
+
+	if r = runHook(context.Background(), Hook{Name: "bad", Command: "true", Timeout: "soon"}, "example.com", "1", hosts); r.Status != "failed" || r.Error == "" {
+		t.Errorf("hook with a bad timeout: %+v", r)
+	}
+}
+
+func TestHookIndex(t *testing.T) {
+	hooks := []Hook{{Name: "httpx"}, {Name: "Nuclei"}}
+	if i := hookIndex(hooks, "nuclei"); i != 1 { t.Errorf("hookIndex(nuclei) = %d, want 1", i) }
+	if i := hookIndex(hooks, "naabu"); i != -1 { t.Errorf("hookIndex(naabu) = %d, want -1", i) }
+}
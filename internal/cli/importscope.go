@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// scopeAsset is one row of a bug bounty platform scope export.
+type scopeAsset struct {
+	Program    string
+	Identifier string
+	Type       string
+	InScope    bool
+}
+
+// ---------- parsing ----------
+
+// parseScopeExport reads a HackerOne/Bugcrowd/Intigriti-style scope export.
+// JSON is walked generically so API responses, program exports and the
+// bounty-targets-data dumps all work; CSV needs an identifier-like column.
+func parseScopeExport(p string) ([]scopeAsset, error) {
+	b, err := os.ReadFile(p); if err != nil { return nil, err }
+	trimmed := strings.TrimSpace(strings.TrimPrefix(string(b), "\ufeff"))
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var v any
+		if err := json.Unmarshal([]byte(trimmed), &v); err != nil { return nil, fmt.Errorf("%s: %w", p, err) }
+		var out []scopeAsset
+		walkScopeJSON(v, "", true, &out)
+		return out, nil
+	}
+	return parseScopeCSV(strings.NewReader(trimmed))
+}
+
+func jsonStr(v any) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case map[string]any: // Intigriti wraps enums as {"id":1,"value":"Wildcard"}
+		return jsonStr(t["value"])
+	}
+	return ""
+}
+
+func walkScopeJSON(v any, program string, inScope bool, out *[]scopeAsset) {
+	switch t := v.(type) {
+	case []any:
+		for _, e := range t { walkScopeJSON(e, program, inScope, out) }
+	case map[string]any:
+		for _, k := range []string{"targets", "target_groups", "structured_scopes", "domains", "relationships"} {
+			if _, ok := t[k]; ok {
+				if h := jsonStr(t["handle"]); h != "" { program = h } else if n := jsonStr(t["name"]); n != "" { program = n }
+				break
+			}
+		}
+		if a, ok := jsonAsset(t, program, inScope); ok { *out = append(*out, a); return }
+		if b, ok := t["in_scope"].(bool); ok { inScope = b }
+		keys := make([]string, 0, len(t)); for k := range t { keys = append(keys, k) }
+		sort.Strings(keys)
+		for _, k := range keys {
+			switch k {
+			case "in_scope":
+				walkScopeJSON(t[k], program, true, out)
+			case "out_of_scope":
+				walkScopeJSON(t[k], program, false, out)
+			default:
+				walkScopeJSON(t[k], program, inScope, out)
+			}
+		}
+	}
+}
+
+func jsonAsset(m map[string]any, program string, inScope bool) (scopeAsset, bool) {
+	if attrs, ok := m["attributes"].(map[string]any); ok && attrs["asset_identifier"] != nil { m = attrs }
+	typ := ""
+	for _, k := range []string{"asset_type", "type", "category"} { if typ = jsonStr(m[k]); typ != "" { break } }
+	id := ""
+	for _, k := range []string{"asset_identifier", "identifier", "endpoint", "target", "uri"} { if id = jsonStr(m[k]); id != "" { break } }
+	if id == "" && typ != "" { id = jsonStr(m["name"]) }
+	if id == "" { return scopeAsset{}, false }
+	if b, ok := m["eligible_for_submission"].(bool); ok && !b { inScope = false }
+	if b, ok := m["in_scope"].(bool); ok { inScope = b }
+	if strings.EqualFold(jsonStr(m["tier"]), "out of scope") { inScope = false }
+	return scopeAsset{Program: program, Identifier: id, Type: typ, InScope: inScope}, true
+}
+
+func parseScopeCSV(r io.Reader) ([]scopeAsset, error) {
+	cr := csv.NewReader(r); cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll(); if err != nil { return nil, err }
+	if len(rows) < 2 { return nil, nil }
+	col := func(names ...string) int {
+		for i, h := range rows[0] {
+			h = strings.ToLower(strings.TrimSpace(h))
+			for _, n := range names { if h == n { return i } }
+		}
+		return -1
+	}
+	idc := col("identifier", "asset_identifier", "endpoint", "target", "asset", "name")
+	if idc < 0 { return nil, fmt.Errorf("csv: no identifier column in header %v", rows[0]) }
+	tc, ec, sc, tierc := col("asset_type", "type", "category"), col("eligible_for_submission"), col("in_scope", "in scope"), col("tier")
+	get := func(row []string, i int) string { if i < 0 || i >= len(row) { return "" }; return strings.TrimSpace(row[i]) }
+	var out []scopeAsset
+	for _, row := range rows[1:] {
+		a := scopeAsset{Identifier: get(row, idc), Type: get(row, tc), InScope: true}
+		if a.Identifier == "" { continue }
+		if v := get(row, ec); v != "" { if b, err := strconv.ParseBool(v); err == nil { a.InScope = b } }
+		if v := strings.ToLower(get(row, sc)); v != "" { a.InScope = v == "true" || v == "yes" || v == "1" }
+		if strings.EqualFold(get(row, tierc), "out of scope") { a.InScope = false }
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// ---------- mapping to domains/rules ----------
+
+// assetHost reduces an identifier (URL, host:port, wildcard) to a lowercase
+// host pattern, or "" when the asset is not a domain (apps, source code...).
+func assetHost(id string) string {
+	h := strings.ToLower(strings.TrimSpace(id))
+	if i := strings.Index(h, "://"); i >= 0 { h = h[i+3:] }
+	if i := strings.IndexAny(h, "/?#"); i >= 0 { h = h[:i] }
+	if host, _, err := net.SplitHostPort(h); err == nil { h = host }
+	h = strings.TrimSuffix(h, ".")
+	wild := strings.HasPrefix(h, "*.")
+	if strings.Contains(strings.TrimPrefix(h, "*."), "*") { return h } // e.g. "example.*": kept so it is reported as skipped
+	h, err := normalizeHost(h)
+	if err != nil || !strings.Contains(h, ".") { return "" }
+	if wild { h = "*." + h }
+	return h
+}
+
+// nonDomainTypes are asset types whose identifiers only look like hosts
+// (Android package names, bundle IDs, repos...).
+var nonDomainTypes = []string{"app", "android", "ios", "mobile", "source", "executable", "binary", "hardware", "contract", "other", "windows"}
+
+func isDomainAsset(a scopeAsset) bool {
+	t := strings.ToLower(a.Type)
+	for _, n := range nonDomainTypes { if strings.Contains(t, n) { return false } }
+	return assetHost(a.Identifier) != ""
+}
+
+func isIPAsset(a scopeAsset) bool {
+	t := strings.ToLower(a.Type)
+	if strings.Contains(t, "cidr") || strings.HasPrefix(t, "ip") { return true }
+	_, _, err := net.ParseCIDR(a.Identifier)
+	return err == nil || net.ParseIP(a.Identifier) != nil
+}
+
+// planScopeImport turns assets into root domain -> Scope. Wildcards define
+// roots; exact hosts join a covering root or become their own; out-of-scope
+// hosts become exclusions on the roots they fall under, and out-of-scope IPs
+// and CIDRs program-wide exclusions (ipExclude).
+func planScopeImport(assets []scopeAsset) (plan map[string]*Scope, ipExclude, skipped []string) {
+	plan = map[string]*Scope{}
+	rootOf := func(h string) string {
+		best := ""
+		for r := range plan { if (h == r || strings.HasSuffix(h, "."+r)) && len(r) > len(best) { best = r } }
+		return best
+	}
+	for _, a := range assets {
+		if h := assetHost(a.Identifier); a.InScope && isDomainAsset(a) && strings.HasPrefix(h, "*.") && !strings.Contains(h[2:], "*") {
+			root := h[2:]
+			if plan[root] == nil { plan[root] = &Scope{} }
+			plan[root].Include = append(plan[root].Include, h)
+		}
+	}
+	for _, a := range assets {
+		h := assetHost(a.Identifier)
+		if !a.InScope || strings.HasPrefix(h, "*.") && !strings.Contains(h[2:], "*") { continue }
+		if isIPAsset(a) || !isDomainAsset(a) || strings.Contains(h, "*") { skipped = append(skipped, a.Identifier); continue }
+		root := rootOf(h)
+		if root == "" { root = h; plan[root] = &Scope{} }
+		m, _ := compileScope(&Scope{Include: plan[root].Include})
+		if ok, _ := m.check(h); !ok || len(plan[root].Include) == 0 { plan[root].Include = append(plan[root].Include, h) }
+	}
+	for _, a := range assets {
+		if a.InScope { continue }
+		if isIPAsset(a) {
+			if _, err := parseScopeRule(a.Identifier); err != nil { skipped = append(skipped, a.Identifier); continue }
+			ipExclude = append(ipExclude, a.Identifier)
+			continue
+		}
+		h := assetHost(a.Identifier)
+		root := rootOf(strings.TrimPrefix(h, "*."))
+		if !isDomainAsset(a) || root == "" { skipped = append(skipped, a.Identifier); continue }
+		plan[root].Exclude = append(plan[root].Exclude, h)
+	}
+	for _, s := range plan { s.Include, s.Exclude = uniqueSorted(s.Include), uniqueSorted(s.Exclude) }
+	return plan, uniqueSorted(ipExclude), uniqueSorted(skipped)
+}
+
+// rejectRoots removes the roots `domwatch add` would refuse (public
+// suffixes such as herokuapp.com, IPs, all-numeric TLDs) from plan and
+// returns them with the reason.
+func rejectRoots(plan map[string]*Scope, errw io.Writer) []string {
+	var out []string
+	for root := range plan {
+		if _, err := checkNewDomain(root, false, false, errw); err != nil { out = append(out, err.Error()); delete(plan, root) }
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ---------- command ----------
+func cmdImportScope(ctx context.Context, a cmdArgs) int {
+	file, program, dry := a.args[0], a.str("program"), a.bool("dry-run")
+	assets, err := parseScopeExport(file); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+
+	// group by program; --program either names an unnamed export or picks one out of a multi-program dump
+	byProg := map[string][]scopeAsset{}
+	for _, a := range assets {
+		p := a.Program
+		if program != "" && p != "" && !strings.EqualFold(p, program) { continue }
+		if program != "" { p = program }
+		if p == "" { p = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) }
+		byProg[p] = append(byProg[p], a)
+	}
+	if len(byProg) == 0 { fmt.Println("no scope assets found in", file); return 1 }
+
+	scopes, err := loadScopes(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	reg, err := loadRegistry(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	progs := make([]string, 0, len(byProg)); for p := range byProg { progs = append(progs, p) }
+	sort.Strings(progs)
+	for _, name := range progs {
+		plan, ipExclude, skipped := planScopeImport(byProg[name])
+		rejected := rejectRoots(plan, os.Stderr)
+		prog := reg.programName(name) // an existing program keeps its spelling
+		fmt.Printf("== program %s: %d root domain(s)\n", prog, len(plan))
+		printScopeChanges(prog, reg, scopes, plan, ipExclude)
+		for _, s := range skipped { fmt.Println("  skipped (not a domain asset):", s) }
+		for _, s := range rejected { fmt.Println("  skipped:", s) }
+		if dry { continue }
+		for root, s := range plan {
+			if _, err := addDomain(root); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+			scopes[root] = mergeScope(scopes[root], s)
+			reg.assign(root, prog, nil)
+		}
+		if p := reg.Programs[prog]; p != nil { p.Exclude = uniqueSorted(append(p.Exclude, ipExclude...)) } else if len(ipExclude) > 0 { reg.Programs[prog] = &Program{Exclude: ipExclude} }
+	}
+	if dry { fmt.Println("dry run: nothing written"); return 0 }
+	if err := saveScopes(scopes); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	if err := saveRegistry(reg); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	return 0
+}
+
+// printScopeChanges shows what importing plan adds to the stored scope of
+// a program. Imports only add rules: rules missing from the export are
+// reported and kept, and so are domains that dropped out of the program
+// (use `domwatch scope rm` and `domwatch remove` for those).
+func printScopeChanges(prog string, reg *Registry, cur, plan map[string]*Scope, ipExclude []string) {
+	var roots []string
+	seen := map[string]bool{}
+	for r, t := range reg.Targets { if strings.EqualFold(t.Program, prog) { roots = append(roots, r); seen[r] = true } }
+	for r := range plan { if !seen[r] { roots = append(roots, r) } }
+	sort.Strings(roots)
+	changed := false
+	for _, r := range roots {
+		old, now := cur[r], plan[r]
+		if old == nil { old = &Scope{} }
+		switch {
+		case now == nil:
+			fmt.Println("  - domain", r, "(no longer in scope export; still monitored)"); changed = true; continue
+		case !seen[r]:
+			fmt.Println("  + domain", r); changed = true
+		}
+		for _, x := range without(now.Include, old.Include) { fmt.Println("  + include", r, x); changed = true }
+		for _, x := range without(now.Exclude, old.Exclude) { fmt.Println("  + exclude", r, x); changed = true }
+		for _, x := range without(old.Include, now.Include) { fmt.Println("  = include", r, x, "(not in the export; kept)") }
+		for _, x := range without(old.Exclude, now.Exclude) { fmt.Println("  = exclude", r, x, "(not in the export; kept)") }
+	}
+	var oldIP []string
+	if p := reg.Programs[prog]; p != nil { oldIP = p.Exclude }
+	for _, x := range without(ipExclude, oldIP) { fmt.Println("  + exclude (all domains)", x); changed = true }
+	for _, x := range without(oldIP, ipExclude) { fmt.Println("  = exclude (all domains)", x, "(not in the export; kept)") }
+	if !changed { fmt.Println("  (no scope changes)") }
+}
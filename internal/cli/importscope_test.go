@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestPlanScopeImport(t *testing.T) {
+	assets := []scopeAsset{
+		{Identifier: "*.example.com", Type: "WILDCARD", InScope: true},
+		{Identifier: "https://shop.example.org/", Type: "URL", InScope: true},
+		{Identifier: "blog.example.com", Type: "URL", InScope: false},
+		{Identifier: "10.0.0.0/8", Type: "CIDR", InScope: false},
+		{Identifier: "192.0.2.1", Type: "IP_ADDRESS", InScope: false},
+		{Identifier: "192.0.2.0/24", Type: "CIDR", InScope: true},
+		{Identifier: "com.example.app", Type: "GOOGLE_PLAY_APP_ID", InScope: true},
+	}
+	plan, ipExclude, skipped := planScopeImport(assets)
+	want := map[string]*Scope{
+		"example.com":      {Include: []string{"*.example.com"}, Exclude: []string{"blog.example.com"}},
+		"shop.example.org": {Include: []string{"shop.example.org"}, Exclude: []string{}},
+	}
+	for root, s := range plan { if !reflect.DeepEqual(s, want[root]) { t.Errorf("plan[%s] = %+v, want %+v", root, s, want[root]) } }
+	if len(plan) != len(want) { t.Errorf("plan has %d roots, want %d", len(plan), len(want)) }
+	// IP exclusions stay on the program instead of every root
+	if want := []string{"10.0.0.0/8", "192.0.2.1"}; !reflect.DeepEqual(ipExclude, want) { t.Errorf("ipExclude = %q, want %q", ipExclude, want) }
+	if want := []string{"192.0.2.0/24", "com.example.app"}; !reflect.DeepEqual(skipped, want) { t.Errorf("skipped = %q, want %q", skipped, want) }
+}
+
+func TestRejectRoots(t *testing.T) {
+	plan, _, _ := planScopeImport([]scopeAsset{
+		{Identifier: "*.example.com", Type: "WILDCARD", InScope: true},
+		{Identifier: "*.herokuapp.com", Type: "WILDCARD", InScope: true},
+		{Identifier: "*.s3.amazonaws.com", Type: "WILDCARD", InScope: true},
+		{Identifier: "*.co.uk", Type: "WILDCARD", InScope: true},
+	})
+	rejected := rejectRoots(plan, io.Discard)
+	if len(plan) != 1 || plan["example.com"] == nil { t.Errorf("plan keeps %v, want only example.com", plan) }
+	if len(rejected) != 3 { t.Errorf("rejected = %q, want the three public suffixes", rejected) }
+}
+
+func TestMergeScope(t *testing.T) {
+	old := &Scope{Include: []string{"*.example.com"}, Exclude: []string{"blog.example.com"}}
+	got := mergeScope(old, &Scope{Include: []string{"*.example.com", "api.example.net"}, Exclude: []string{"dev.example.com"}})
+	want := &Scope{Include: []string{"*.example.com", "api.example.net"}, Exclude: []string{"blog.example.com", "dev.example.com"}}
+	if !reflect.DeepEqual(got, want) { t.Errorf("mergeScope = %v, want %v", got, want) }
+	if want := []string{"blog.example.com"}; !reflect.DeepEqual(old.Exclude, want) { t.Errorf("old scope changed: %v", old) }
+	if add := (&Scope{Include: []string{"x.example.com"}}); mergeScope(nil, add) != add { t.Error("mergeScope(nil, add) != add") }
+}
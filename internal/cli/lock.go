@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Run locks keep a manual `domwatch scan`, the timer and the daemon from
+// interleaving writes. The home lock guards the shared files in the home
+// directory (domains.txt, programs.json, scopes.json, schedule.json, ...)
+// and is only held for short edits; a domain lock is held for a whole scan
+// of that domain. Locks are advisory OS locks (flock, LockFileEx), so the
+// kernel releases them when a process dies.
+const (
+	LocksRelDir         = "locks"
+	DefaultHomeLockWait = 30 * time.Second
+	lockPoll            = 250 * time.Millisecond
+)
+
+// lockWait is how long to wait for a busy lock, set by --wait:
+// 0 fails at once, < 0 waits forever.
+var lockWait time.Duration
+
+var errLockBusy = errors.New("lock is held by another process")
+
+func locksDir() string { return filepath.Join(homeDir(), LocksRelDir) }
+
+// lockInfo is written into a held lock file so others can tell who holds it.
+type lockInfo struct {
+	PID   int       `json:"pid"`
+	Host  string    `json:"host"`
+	Cmd   string    `json:"cmd"`
+	Since time.Time `json:"since"`
+}
+
+func (i lockInfo) String() string {
+	return fmt.Sprintf("pid %d on %s (%s) since %s", i.PID, i.Host, i.Cmd, i.Since.Local().Format("2006-01-02 15:04:05"))
+}
+
+// lockBusyError is returned when a lock stays busy past the wait time.
+type lockBusyError struct {
+	Name   string
+	Holder lockInfo
+}
+
+func (e *lockBusyError) Error() string {
+	if e.Holder.PID == 0 { return e.Name + " is locked by another domwatch process (use --wait)" }
+	return fmt.Sprintf("%s is locked by %s (use --wait)", e.Name, e.Holder)
+}
+
+type fileLock struct{ f *os.File }
+
+// acquireLock takes the named lock, retrying until wait elapses (see
+// lockWait for the meaning of 0 and negative values) or ctx is done.
+func acquireLock(ctx context.Context, name string, wait time.Duration, errw io.Writer) (*fileLock, error) {
+	if err := os.MkdirAll(locksDir(), 0o755); err != nil { return nil, err }
+	p := filepath.Join(locksDir(), name+".lock")
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0o644); if err != nil { return nil, err }
+	deadline := time.Now().Add(wait)
+	for {
+		err := tryLockFile(f)
+		if err == nil { break }
+		if !errors.Is(err, errLockBusy) { f.Close(); return nil, fmt.Errorf("lock %s: %w", p, err) }
+		if wait >= 0 && !time.Now().Before(deadline) { holder := readLockInfo(f); f.Close(); return nil, &lockBusyError{name, holder} }
+		if err := sleepCtx(ctx, lockPoll); err != nil { f.Close(); return nil, err }
+	}
+	// a free lock that still names its holder was left behind by a crash
+	if old := readLockInfo(f); old.PID != 0 {
+		fmt.Fprintf(errw, "note: recovered stale %s lock of %s\n", name, old)
+	}
+	host, _ := os.Hostname()
+	b, _ := json.Marshal(lockInfo{PID: os.Getpid(), Host: host, Cmd: strings.Join(os.Args[1:], " "), Since: time.Now()})
+	if err := f.Truncate(0); err == nil { f.WriteAt(append(b, '\n'), 0) }
+	return &fileLock{f: f}, nil
+}
+
+// unlock clears the holder info and releases the lock.
+func (l *fileLock) unlock() {
+	l.f.Truncate(0)
+	unlockFile(l.f)
+	l.f.Close()
+}
+
+func readLockInfo(f *os.File) lockInfo {
+	var i lockInfo
+	b := make([]byte, 4096)
+	n, _ := f.ReadAt(b, 0)
+	json.Unmarshal(b[:n], &i)
+	return i
+}
+
+// domainLockName maps a domain to its lock name.
+func domainLockName(domain string) string { return "domain-" + domain }
+
+// homeLock is reentrant within the process: a command that holds it for its
+// whole run can still call helpers (updateState, ...) that take it again.
+var homeLock struct {
+	sync.Mutex
+	n int
+	l *fileLock
+}
+
+// lockHome takes the home lock and returns its release function. It waits
+// at least DefaultHomeLockWait, since holders only keep it for short edits.
+func lockHome(ctx context.Context, errw io.Writer) (func(), error) {
+	homeLock.Lock()
+	defer homeLock.Unlock()
+	if homeLock.n == 0 {
+		wait := lockWait
+		if wait >= 0 && wait < DefaultHomeLockWait { wait = DefaultHomeLockWait }
+		l, err := acquireLock(ctx, "home", wait, errw); if err != nil { return nil, err }
+		homeLock.l = l
+	}
+	homeLock.n++
+	return func() {
+		homeLock.Lock()
+		defer homeLock.Unlock()
+		if homeLock.n--; homeLock.n == 0 { homeLock.l.unlock(); homeLock.l = nil }
+	}, nil
+}
+
+// withHomeLock runs a mutating command while holding the home lock.
+func withHomeLock(ctx context.Context, cmd func() int) int {
+	release, err := lockHome(ctx, os.Stderr)
+	if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	defer release()
+	return cmd()
+}
+
+// waitFlag is the --wait[=<duration>] flag every command's flag set has. It
+// sets lockWait: plain --wait waits forever.
+type waitFlag struct{}
+
+func (waitFlag) String() string   { return "" }
+func (waitFlag) IsBoolFlag() bool { return true }
+
+func (waitFlag) Set(v string) error {
+	if v == "true" { lockWait = -1; return nil }
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 { return errors.New("must be a duration like 10m") }
+	lockWait = d
+	return nil
+}
+
+// splitWait removes --wait / --wait=<duration> from the arguments of a raw
+// command, which has no flag set, up to "--", and sets lockWait.
+func splitWait(args []string) ([]string, error) {
+	var out []string
+	for i, a := range args {
+		if a == "--" { return append(out, args[i:]...), nil }
+		v, ok := strings.CutPrefix(a, "--wait=")
+		if a == "--wait" { v, ok = "true", true }
+		if !ok { out = append(out, a); continue }
+		if err := (waitFlag{}).Set(v); err != nil { return nil, fmt.Errorf("--wait %v", err) }
+	}
+	return out, nil
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// busy reports whether a second handle fails to take the named lock at once.
+func busy(t *testing.T, name string) bool {
+	t.Helper()
+	l, err := acquireLock(context.Background(), name, 0, io.Discard)
+	var be *lockBusyError
+	if errors.As(err, &be) { return true }
+	if err != nil { t.Fatal(err) }
+	l.unlock()
+	return false
+}
+
+func TestLockHomeReentrant(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	ctx := context.Background()
+	outer, err := lockHome(ctx, io.Discard); if err != nil { t.Fatal(err) }
+	inner, err := lockHome(ctx, io.Discard); if err != nil { t.Fatalf("taking the home lock again: %v", err) }
+	if !busy(t, "home") { t.Fatal("home lock not held") }
+	// helpers that lock again work under a held lock
+	if err := updateState(func(st *SchedState) { st.domain("example.com").LastNew = 1 }); err != nil { t.Fatal(err) }
+	inner()
+	if !busy(t, "home") { t.Fatal("inner release freed the home lock") }
+	outer()
+	if busy(t, "home") { t.Fatal("home lock still held after the last release") }
+	if homeLock.n != 0 || homeLock.l != nil { t.Errorf("home lock state left: n=%d", homeLock.n) }
+}
+
+func TestDomainLockBusy(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	ctx := context.Background()
+	l, err := acquireLock(ctx, domainLockName("example.com"), 0, io.Discard); if err != nil { t.Fatal(err) }
+	_, err = acquireLock(ctx, domainLockName("example.com"), 0, io.Discard)
+	var be *lockBusyError
+	if !errors.As(err, &be) { t.Fatalf("second handle: %v, want a lockBusyError", err) }
+	if be.Holder.PID != os.Getpid() || !strings.Contains(err.Error(), "locked by pid") { t.Errorf("holder %+v, error %q", be.Holder, err) }
+	if runStatus(err) != "locked" { t.Errorf("runStatus = %q", runStatus(err)) }
+	if busy(t, domainLockName("example.org")) { t.Error("another domain's lock is busy") }
+
+	// a scan of the domain fails with the same error instead of waiting
+	_, err = scanOne(ctx, "example.com", scanOptions{}, io.Discard, io.Discard)
+	if !errors.As(err, &be) { t.Errorf("scanOne on a locked domain: %v", err) }
+
+	// --wait: the lock is taken once the holder lets go
+	go func() { time.Sleep(3 * lockPoll / 2); l.unlock() }()
+	var errw bytes.Buffer
+	l2, err := acquireLock(ctx, domainLockName("example.com"), 10*time.Second, &errw); if err != nil { t.Fatalf("waiting: %v", err) }
+	l2.unlock()
+	if errw.Len() != 0 { t.Errorf("cleanly released lock reported: %q", errw.String()) }
+}
+
+func TestLockWaitCancel(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	l, err := acquireLock(context.Background(), "home", 0, io.Discard); if err != nil { t.Fatal(err) }
+	defer l.unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*lockPoll)
+	defer cancel()
+	if _, err := acquireLock(ctx, "home", -1, io.Discard); !errors.Is(err, context.DeadlineExceeded) { t.Errorf("waiting forever: %v, want the context's error", err) }
+}
+
+func TestStaleLock(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	// holder info without a held lock: the process died
+	if err := os.MkdirAll(locksDir(), 0o755); err != nil { t.Fatal(err) }
+	stale := `{"pid": 999999, "host": "old", "cmd": "scan example.com", "since": "2024-01-01T00:00:00Z"}`
+	if err := os.WriteFile(filepath.Join(locksDir(), "domain-example.com.lock"), []byte(stale), 0o644); err != nil { t.Fatal(err) }
+	var errw bytes.Buffer
+	l, err := acquireLock(context.Background(), domainLockName("example.com"), 0, &errw); if err != nil { t.Fatal(err) }
+	defer l.unlock()
+	if !strings.Contains(errw.String(), "recovered stale domain-example.com lock of pid 999999") { t.Errorf("note = %q", errw.String()) }
+	if got := readLockInfo(l.f); got.PID != os.Getpid() { t.Errorf("holder = %+v, want this process", got) }
+}
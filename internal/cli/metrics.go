@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Metrics are computed when scraped: scan counts, durations and new hosts
+// from the run history, sizes from the inventories. Only what leaves no
+// other trace (notifier failures, AI requests) is counted in metrics.json,
+// so a oneshot `scan` from the timer and a long-running daemon report the
+// same numbers. Since the per-domain counters come from the run history,
+// they start over when a domain is removed and added again, which
+// Prometheus treats as a counter reset. Expose them with `serve`
+// (/metrics), `daemon --metrics-listen` or `domwatch metrics --textfile`
+// for node_exporter.
+const MetricsRelPath = "metrics.json"
+
+var (
+	scanDurationBuckets = []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200}
+	aiDurationBuckets   = []float64{0.5, 1, 2, 5, 10, 20, 30, 60}
+)
+
+func metricsPath() string { return filepath.Join(homeDir(), MetricsRelPath) }
+
+// storedMetrics are the counters kept in metrics.json.
+type storedMetrics struct {
+	NotifyFailures map[string]int64 `json:"notify_failures"` // per channel
+	AIRequests     int64            `json:"ai_requests"`
+	AIErrors       int64            `json:"ai_errors"`
+	AIDuration     histogram        `json:"ai_duration"`
+}
+
+// histogram holds per-bucket (not cumulative) counts; the last one is +Inf.
+type histogram struct {
+	Counts []int64   `json:"counts"`
+	Sum    float64 `json:"sum"`
+	Count  int64   `json:"count"`
+}
+
+func (h *histogram) observe(bounds []float64, v float64) {
+	if len(h.Counts) != len(bounds)+1 { h.Counts = make([]int64, len(bounds)+1) }
+	i := sort.SearchFloat64s(bounds, v)
+	h.Counts[i]++
+	h.Sum += v
+	h.Count++
+}
+
+func loadMetrics() (*storedMetrics, error) {
+	m := &storedMetrics{}
+	b, err := os.ReadFile(metricsPath())
+	if err != nil && !os.IsNotExist(err) { return nil, err }
+	if err == nil {
+		if err := json.Unmarshal(b, m); err != nil { return nil, fmt.Errorf("%s: %w", metricsPath(), err) }
+	}
+	if m.NotifyFailures == nil { m.NotifyFailures = map[string]int64{} }
+	return m, nil
+}
+
+// updateMetrics applies fn to metrics.json under the "metrics" lock.
+// Failures are only reported: metrics never fail a scan.
+func updateMetrics(fn func(*storedMetrics)) {
+	err := func() error {
+		l, err := acquireLock(context.Background(), "metrics", DefaultHomeLockWait, io.Discard); if err != nil { return err }
+		defer l.unlock()
+		m, err := loadMetrics(); if err != nil { return err }
+		fn(m)
+		b, _ := json.MarshalIndent(m, "", "  ")
+		return writeFileAtomic(metricsPath(), b, 0o644)
+	}()
+	if err != nil { fmt.Fprintln(os.Stderr, "error: metrics:", err) }
+}
+
+func countNotifyFailure(channel string) {
+	updateMetrics(func(m *storedMetrics) { m.NotifyFailures[channel]++ })
+}
+
+func observeAIRequest(d time.Duration, err error) {
+	updateMetrics(func(m *storedMetrics) {
+		m.AIRequests++
+		if err != nil { m.AIErrors++ }
+		m.AIDuration.observe(aiDurationBuckets, d.Seconds())
+	})
+}
+
+// ---------- exposition ----------
+
+// promWriter writes the Prometheus text format, one metric family at a time.
+type promWriter struct{ bytes.Buffer }
+
+func (w *promWriter) family(name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (w *promWriter) sample(name string, labels []string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 { w.WriteByte(',') }
+			fmt.Fprintf(w, "%s=\"%s\"", labels[i], promEscape.Replace(labels[i+1]))
+		}
+		w.WriteByte('}')
+	}
+	fmt.Fprintf(w, " %s\n", csvFloat(v))
+}
+
+// histogram writes the _bucket, _sum and _count samples of h.
+func (w *promWriter) histogram(name string, labels []string, bounds []float64, h histogram) {
+	var cum int64
+	for i, b := range append(bounds, 0) {
+		if i < len(h.Counts) { cum += h.Counts[i] }
+		le := "+Inf"
+		if i < len(bounds) { le = csvFloat(b) }
+		w.sample(name+"_bucket", append(append([]string{}, labels...), "le", le), float64(cum))
+	}
+	w.sample(name+"_sum", labels, h.Sum)
+	w.sample(name+"_count", labels, float64(h.Count))
+}
+
+var promEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeMetrics renders all metrics.
+func writeMetrics(out io.Writer) error {
+	domains, _ := readLines(filepath.Join(homeDir(), "domains.txt"))
+	domains = uniqueSorted(domains)
+	type domainMetrics struct {
+		scans           map[string]int // by status
+		failed, added   int
+		duration        histogram
+		hosts           int
+		lastRun, lastOK time.Time
+	}
+	per := map[string]*domainMetrics{}
+	seen := map[string]bool{}
+	var statuses []string
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		dm := &domainMetrics{scans: map[string]int{}, duration: histogram{Counts: make([]int64, len(scanDurationBuckets)+1)}}
+		per[d] = dm
+		hosts, _ := readLines(filepath.Join(dataDir(), d+".txt"))
+		dm.hosts = len(hosts)
+		runs, err := loadRuns(d); if err != nil { return err }
+		for _, r := range runs {
+			dm.scans[r.Status]++
+			if !seen[r.Status] { seen[r.Status] = true; statuses = append(statuses, r.Status) }
+			if r.Status != "ok" { dm.failed++ }
+			dm.added += r.Added
+			if r.Status != "locked" { dm.duration.observe(scanDurationBuckets, r.Duration) }
+			if r.Start.After(dm.lastRun) { dm.lastRun = r.Start }
+			if r.Status == "ok" && r.End.After(dm.lastOK) { dm.lastOK = r.End }
+		}
+	}
+	names := make([]string, 0, len(per))
+	for d := range per { names = append(names, d) }
+	sort.Strings(names)
+	sort.Strings(statuses)
+	stored, err := loadMetrics(); if err != nil { return err }
+
+	w := &promWriter{}
+	w.family("domwatch_domains", "gauge", "Monitored domains.")
+	w.sample("domwatch_domains", nil, float64(len(names)))
+	w.family("domwatch_hosts", "gauge", "Hosts in the inventory of a domain.")
+	for _, d := range names { w.sample("domwatch_hosts", []string{"domain", d}, float64(per[d].hosts)) }
+	w.family("domwatch_scans_total", "counter", "Scans in the run history, by status (ok, failed, timeout, interrupted, locked).")
+	for _, d := range names {
+		for _, st := range statuses { w.sample("domwatch_scans_total", []string{"domain", d, "status", st}, float64(per[d].scans[st])) }
+	}
+	w.family("domwatch_scans_failed_total", "counter", "Scans that did not finish ok.")
+	for _, d := range names { w.sample("domwatch_scans_failed_total", []string{"domain", d}, float64(per[d].failed)) }
+	w.family("domwatch_scan_duration_seconds", "histogram", "Duration of scans.")
+	for _, d := range names { w.histogram("domwatch_scan_duration_seconds", []string{"domain", d}, scanDurationBuckets, per[d].duration) }
+	w.family("domwatch_new_hosts_total", "counter", "Hosts added to the inventory by scans.")
+	for _, d := range names { w.sample("domwatch_new_hosts_total", []string{"domain", d}, float64(per[d].added)) }
+	w.family("domwatch_last_scan_timestamp_seconds", "gauge", "Start of the latest scan (Unix time).")
+	for _, d := range names { if !per[d].lastRun.IsZero() { w.sample("domwatch_last_scan_timestamp_seconds", []string{"domain", d}, float64(per[d].lastRun.Unix())) } }
+	w.family("domwatch_last_success_timestamp_seconds", "gauge", "End of the latest successful scan (Unix time).")
+	for _, d := range names { if !per[d].lastOK.IsZero() { w.sample("domwatch_last_success_timestamp_seconds", []string{"domain", d}, float64(per[d].lastOK.Unix())) } }
+	w.family("domwatch_notify_failures_total", "counter", "Notifications a channel failed to deliver.")
+	for _, ch := range []string{"discord", "telegram"} { w.sample("domwatch_notify_failures_total", []string{"channel", ch}, float64(stored.NotifyFailures[ch])) }
+	w.family("domwatch_ai_requests_total", "counter", "AI summary requests.")
+	w.sample("domwatch_ai_requests_total", nil, float64(stored.AIRequests))
+	w.family("domwatch_ai_request_errors_total", "counter", "AI summary requests that failed.")
+	w.sample("domwatch_ai_request_errors_total", nil, float64(stored.AIErrors))
+	w.family("domwatch_ai_request_duration_seconds", "histogram", "Latency of AI summary requests.")
+	w.histogram("domwatch_ai_request_duration_seconds", nil, aiDurationBuckets, stored.AIDuration)
+	_, err = out.Write(w.Bytes())
+	return err
+}
+
+func serveMetrics(w http.ResponseWriter) {
+	var b bytes.Buffer
+	if err := writeMetrics(&b); err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// listenMetrics serves /metrics on addr until ctx is done (daemon mode).
+func listenMetrics(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr); if err != nil { return err }
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) { serveMetrics(w) })
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() { <-ctx.Done(); srv.Close() }()
+	go srv.Serve(ln)
+	fmt.Printf("metrics on http://%s/metrics\n", ln.Addr())
+	return nil
+}
+
+// cmdMetrics prints the metrics, or writes them atomically for
+// node_exporter's textfile collector.
+func cmdMetrics(ctx context.Context, a cmdArgs) int {
+	var b bytes.Buffer
+	if err := writeMetrics(&b); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	p := a.str("textfile")
+	if p == "" { os.Stdout.Write(b.Bytes()); return 0 }
+	if !strings.HasSuffix(p, ".prom") { return a.usage("--textfile must end in .prom (node_exporter ignores other files)") }
+	return exitWrite(writeFileAtomic(p, b.Bytes(), 0o644))
+}
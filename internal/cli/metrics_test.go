@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	for _, d := range []string{"example.com", "example.org"} {
+		if _, err := addDomain(d); err != nil { t.Fatal(err) }
+	}
+	start := time.Unix(1700000000, 0).UTC()
+	for i, r := range []RunRecord{
+		{Status: "ok", Duration: 5, Added: 3},
+		{Status: "failed", Duration: 45},
+		{Status: "ok", Duration: 700, Added: 1},
+		{Status: "timeout", Duration: 9000},
+		{Status: "locked", Duration: 1}, // not a scan that ran
+	} {
+		r.Domain, r.Start = "example.com", start.Add(time.Duration(i)*time.Hour)
+		r.End = r.Start.Add(time.Duration(r.Duration) * time.Second)
+		if err := appendRun(&r); err != nil { t.Fatal(err) }
+	}
+	var b bytes.Buffer
+	if err := writeMetrics(&b); err != nil { t.Fatal(err) }
+
+	// example.org has no runs; its zero samples are left out
+	want := `domwatch_domains 2
+domwatch_scans_total{domain="example.com",status="failed"} 1
+domwatch_scans_total{domain="example.com",status="locked"} 1
+domwatch_scans_total{domain="example.com",status="ok"} 2
+domwatch_scans_total{domain="example.com",status="timeout"} 1
+domwatch_scans_failed_total{domain="example.com"} 3
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="10"} 1
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="30"} 1
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="60"} 2
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="120"} 2
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="300"} 2
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="600"} 2
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="1200"} 3
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="1800"} 3
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="3600"} 3
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="7200"} 3
+domwatch_scan_duration_seconds_bucket{domain="example.com",le="+Inf"} 4
+domwatch_scan_duration_seconds_sum{domain="example.com"} 9750
+domwatch_scan_duration_seconds_count{domain="example.com"} 4
+domwatch_new_hosts_total{domain="example.com"} 4
+domwatch_last_scan_timestamp_seconds{domain="example.com"} 1700014400
+domwatch_last_success_timestamp_seconds{domain="example.com"} 1700007900
+`
+	if got := promLines(b.String(), `domain="example.org"`); got != want { t.Errorf("metrics:\n%s\nwant:\n%s", got, want) }
+	for _, f := range []string{"domwatch_scans_total", "domwatch_scan_duration_seconds"} {
+		if !strings.Contains(b.String(), "# TYPE "+f+" ") { t.Errorf("no TYPE line for %s", f) }
+	}
+
+	// counters come from the run history: removing the domain resets them
+	if err := removeDomain(context.Background(), "example.com", 0, io.Discard); err != nil { t.Fatal(err) }
+	b.Reset()
+	if err := writeMetrics(&b); err != nil { t.Fatal(err) }
+	if got := promLines(b.String(), `domain="example.org"`); got != "domwatch_domains 1\n" { t.Errorf("after remove:\n%s", got) }
+}
+
+// promLines are the samples of the domwatch_domains, scan and timestamp
+// metrics in out, without those that contain skip.
+func promLines(out, skip string) string {
+	var b strings.Builder
+	for _, l := range strings.Split(out, "\n") {
+		if l == "" || strings.HasPrefix(l, "#") || strings.Contains(l, skip) { continue }
+		if strings.HasPrefix(l, "domwatch_domains ") || strings.HasPrefix(l, "domwatch_scan") || strings.HasPrefix(l, "domwatch_new_hosts") || strings.HasPrefix(l, "domwatch_last_") {
+			b.WriteString(l + "\n")
+		}
+	}
+	return b.String()
+}
+
+func TestPromLabelEscaping(t *testing.T) {
+	w := &promWriter{}
+	w.sample("m", []string{"a", `back\slash "quoted"` + "\nnext", "b", "x"}, 1.5)
+	if want := `m{a="back\\slash \"quoted\"\nnext",b="x"} 1.5` + "\n"; w.String() != want { t.Errorf("sample = %q, want %q", w.String(), want) }
+	w.Reset()
+	w.histogram("h", nil, []float64{1, 2}, histogram{Counts: []int64{1, 0, 2}, Sum: 9, Count: 3})
+	want := "h_bucket{le=\"1\"} 1\nh_bucket{le=\"2\"} 1\nh_bucket{le=\"+Inf\"} 3\nh_sum 9\nh_count 3\n"
+	if w.String() != want { t.Errorf("histogram = %q, want %q", w.String(), want) }
+}
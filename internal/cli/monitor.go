@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Monitor is the engine behind the commands: adding and removing domains,
+// scanning, listing. pkg/domwatch exposes it to other Go programs; the CLI
+// and `serve` use it too, so both share the files, locks, run history and
+// event log of a home directory with every other domwatch process.
+type Monitor struct {
+	opts   MonitorOptions
+	mu     sync.Mutex // serializes domain list changes within the process
+	home   string     // claimed in homes by NewMonitor; "" for the CLI's
+	closed sync.Once
+
+	lmu       sync.Mutex
+	listeners []listener // see OnEvent
+	lastID    int
+}
+
+type listener struct {
+	id int
+	fn func(Event)
+}
+
+// MonitorOptions configure a Monitor. Notifiers, hooks, scopes and API
+// keys come from the home directory's files and the environment, as for
+// the CLI.
+type MonitorOptions struct {
+	Home     string        // default $DOMWATCH_HOME, then /opt/domwatch
+	Timeout  time.Duration // per-domain enumeration; 0 = none, as for scan --timeout 0 (the CLI default is DefaultDomainTimeout)
+	LockWait time.Duration // wait for a domain another process is scanning; 0 = fail at once
+	Resolve  bool          // store the addresses of returned hosts in the snapshot
+	AI       bool          // summarize new hosts with the configured AI provider
+	Trigger  string        // recorded in the run history; default "library"
+	Output   io.Writer     // scan progress and warnings, as the CLI prints them; default none
+}
+
+// AddOptions are the choices of `domwatch add`.
+type AddOptions struct {
+	Program     string
+	Tags        []string
+	Force       bool // add a public suffix anyway
+	Registrable bool // replace a subdomain by its registrable domain
+}
+
+// homes is the home directory of the open Monitors. Every path helper goes
+// through homeDir, so all Monitors of a process share one home; it is
+// released when the last of them is closed. The CLI commands and the Public
+// Suffix List cache (loaded once per process) read it the same way.
+var homes struct {
+	sync.RWMutex
+	dir  string
+	open int
+}
+
+var errOtherHome = errors.New("a Monitor with another home directory is open in this process (Close it first)")
+
+// NewMonitor returns a Monitor for opts.Home. A process works on one home
+// directory at a time: while a Monitor is open, NewMonitor fails with
+// errOtherHome for any other directory, and Close of the last Monitor
+// releases it. A public_suffix_list.dat installed in the home is read by the
+// first Monitor only.
+func NewMonitor(opts MonitorOptions) (*Monitor, error) {
+	if opts.Trigger == "" { opts.Trigger = "library" }
+	if opts.Output == nil { opts.Output = io.Discard }
+	if opts.Home == "" { opts.Home = envHome() }
+	home, err := filepath.Abs(opts.Home); if err != nil { return nil, err }
+	homes.Lock()
+	if homes.dir != "" && homes.dir != home { homes.Unlock(); return nil, errOtherHome }
+	homes.dir = home; homes.open++
+	homes.Unlock()
+	m := &Monitor{opts: opts, home: home}
+	if err := ensureDirs(); err != nil { m.Close(); return nil, err }
+	return m, nil
+}
+
+// Close releases the Monitor's home directory; once every Monitor is
+// closed, one for another home can be opened. Close it only after its
+// scans have returned.
+func (m *Monitor) Close() error {
+	if m.home == "" { return nil }
+	m.closed.Do(func() {
+		homes.Lock()
+		if homes.open--; homes.open == 0 { homes.dir = "" }
+		homes.Unlock()
+	})
+	return nil
+}
+
+// cliMonitor is the Monitor of commands: the environment's home, the global
+// --wait, warnings on stderr.
+func cliMonitor(trigger string) *Monitor {
+	return &Monitor{opts: MonitorOptions{Timeout: DefaultDomainTimeout, LockWait: lockWait, Trigger: trigger, Output: os.Stderr}}
+}
+
+// Home returns the directory the Monitor keeps its data in.
+func (m *Monitor) Home() string {
+	if m.home != "" { return m.home }
+	return homeDir()
+}
+
+// locked runs fn under the home lock, as mutating commands do.
+func (m *Monitor) locked(ctx context.Context, fn func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	release, err := lockHome(ctx, io.Discard); if err != nil { return err }
+	defer release()
+	return fn()
+}
+
+// AddDomain starts monitoring domain and returns its normalized form and
+// whether it was new.
+func (m *Monitor) AddDomain(ctx context.Context, domain string, opts AddOptions) (string, bool, error) {
+	d, err := checkNewDomain(domain, opts.Force, opts.Registrable, m.opts.Output); if err != nil { return "", false, err }
+	var created bool
+	err = m.locked(ctx, func() error {
+		if created, err = addDomain(d); err != nil { return err }
+		if tags := splitTags(opts.Tags); opts.Program != "" || len(tags) > 0 {
+			reg, err := loadRegistry(); if err != nil { return err }
+			reg.assign(d, opts.Program, tags)
+			return saveRegistry(reg)
+		}
+		return nil
+	})
+	return d, created, err
+}
+
+// RemoveDomain stops monitoring domain and deletes everything stored for it.
+func (m *Monitor) RemoveDomain(ctx context.Context, domain string) error {
+	d, err := m.monitoredDomain(domain); if err != nil { return err }
+	return m.locked(ctx, func() error { return removeDomain(ctx, d, m.opts.LockWait, m.opts.Output) })
+}
+
+// monitoredDomain normalizes domain and fails unless it is monitored.
+func (m *Monitor) monitoredDomain(domain string) (string, error) {
+	d, err := normalizeDomain(domain); if err != nil { return "", err }
+	if !monitored(d) { return "", fmt.Errorf("%s is not monitored", d) }
+	return d, nil
+}
+
+// List returns the monitored domains, sorted.
+func (m *Monitor) List() ([]DomainRecord, error) {
+	all, err := readLines(filepath.Join(homeDir(), "domains.txt")); if err != nil { return nil, err }
+	var domains []string
+	for _, d := range all { if d = strings.ToLower(strings.TrimSpace(d)); d != "" { domains = append(domains, d) } }
+	return domainRecords(uniqueSorted(domains))
+}
+
+// Hosts returns the inventory of domain.
+func (m *Monitor) Hosts(domain string) ([]HostRecord, error) {
+	d, err := m.monitoredDomain(domain); if err != nil { return nil, err }
+	hosts, err := readLines(filepath.Join(dataDir(), d+".txt")); if err != nil { return nil, err }
+	return hostRecords(d, hosts), nil
+}
+
+// Runs returns the run history of domain, oldest first.
+func (m *Monitor) Runs(domain string) ([]RunRecord, error) {
+	d, err := m.monitoredDomain(domain); if err != nil { return nil, err }
+	return loadRuns(d)
+}
+
+// Scan enumerates domain once, like `domwatch scan <domain>`: the inventory,
+// run history, snapshot and schedule are updated, notifications and hooks
+// run. The record is filled in on failure too; its Status tells timeouts and
+// lock conflicts apart.
+func (m *Monitor) Scan(ctx context.Context, domain string) (ScanRecord, error) {
+	d, err := normalizeDomain(domain); if err != nil { return ScanRecord{Domain: domain, Status: "failed", Error: err.Error()}, err }
+	r, err := scanOne(ctx, d, m.scanOptions(), m.opts.Output, m.opts.Output)
+	recordScan(d, r, err)
+	return newScanRecord(d, r, err), err
+}
+
+// scanDomains is Scan for several domains, as `scan --all` runs them: pool
+// gives the Workers, FailFast, Quiet and OnDone of scanMany, the rest comes
+// from the Monitor's options. Each result is recorded before OnDone is
+// called. Progress goes to stdout and stderr, as the CLI prints it.
+func (m *Monitor) scanDomains(ctx context.Context, domains []string, pool scanOptions) scanSummary {
+	opts := m.scanOptions()
+	opts.Workers, opts.FailFast, opts.Quiet = pool.Workers, pool.FailFast, pool.Quiet
+	opts.OnDone = func(d string, r scanResult, err error) {
+		recordScan(d, r, err)
+		if pool.OnDone != nil { pool.OnDone(d, r, err) }
+	}
+	return scanMany(ctx, domains, opts)
+}
+
+func (m *Monitor) scanOptions() scanOptions {
+	return scanOptions{WithAI: m.opts.AI, Workers: 1, Timeout: m.opts.Timeout, LockWait: m.opts.LockWait, Trigger: m.opts.Trigger, Resolve: m.opts.Resolve, OnEvent: m.dispatch}
+}
+
+// OnEvent registers fn for the events of scans run by this Monitor (see
+// Event), in order and on the scanning goroutine, and returns the function
+// that unregisters it. Events of other Monitors and processes are in the
+// home's event log only.
+func (m *Monitor) OnEvent(fn func(Event)) (cancel func()) {
+	m.lmu.Lock()
+	defer m.lmu.Unlock()
+	m.lastID++
+	id := m.lastID
+	m.listeners = append(m.listeners, listener{id, fn})
+	return func() {
+		m.lmu.Lock()
+		defer m.lmu.Unlock()
+		for i, l := range m.listeners {
+			if l.id == id { m.listeners = append(m.listeners[:i:i], m.listeners[i+1:]...); return }
+		}
+	}
+}
+
+// dispatch hands ev to the listeners registered when it happened.
+func (m *Monitor) dispatch(ev Event) {
+	m.lmu.Lock()
+	ls := m.listeners
+	m.lmu.Unlock()
+	for _, l := range ls { l.fn(ev) }
+}
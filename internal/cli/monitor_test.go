@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMonitorNotMonitored(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	if _, err := addDomain("example.com"); err != nil { t.Fatal(err) }
+	m := cliMonitor("library")
+	if _, err := m.Hosts("Example.COM."); err != nil { t.Errorf("Hosts of a monitored domain: %v", err) }
+	if _, err := m.Runs("example.com"); err != nil { t.Errorf("Runs of a monitored domain: %v", err) }
+	for name, f := range map[string]func() error{
+		"Hosts":        func() error { _, err := m.Hosts("example.org"); return err },
+		"Runs":         func() error { _, err := m.Runs("example.org"); return err },
+		"RemoveDomain": func() error { return m.RemoveDomain(context.Background(), "example.org") },
+	} {
+		if err := f(); err == nil || !strings.Contains(err.Error(), "example.org is not monitored") { t.Errorf("%s(example.org): %v, want not monitored", name, err) }
+	}
+}
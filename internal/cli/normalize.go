@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------- hostname normalization ----------
+
+// normalizeHost lowercases, strips trailing dots and leading "*." wildcards,
+// converts Unicode labels to punycode (xn--) and validates the result.
+// Lowercasing stands in for full IDNA2008 mapping, which needs x/text.
+func normalizeHost(s string) (string, error) {
+	h := strings.ToLower(strings.TrimSpace(s))
+	h = strings.TrimRight(h, ".")
+	for strings.HasPrefix(h, "*.") { h = h[2:] }
+	if h == "" { return "", errors.New("empty hostname") }
+	labels := strings.Split(h, ".")
+	for i, l := range labels {
+		if l == "" { return "", fmt.Errorf("%q: empty label", s) }
+		if !isASCII(l) {
+			enc, err := punyEncode(l); if err != nil { return "", fmt.Errorf("%q: %w", s, err) }
+			l = "xn--" + enc
+		} else if strings.HasPrefix(l, "xn--") {
+			if _, err := punyDecode(l[4:]); err != nil { return "", fmt.Errorf("%q: bad punycode label %q", s, l) }
+		}
+		if len(l) > 63 { return "", fmt.Errorf("%q: label longer than 63 octets", s) }
+		if l[0] == '-' || l[len(l)-1] == '-' { return "", fmt.Errorf("%q: label %q starts or ends with '-'", s, l) }
+		for _, r := range l {
+			if !(r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')) { return "", fmt.Errorf("%q: invalid character %q", s, r) }
+		}
+		labels[i] = l
+	}
+	h = strings.Join(labels, ".")
+	if len(h) > 253 { return "", fmt.Errorf("%q: name longer than 253 octets", s) }
+	return h, nil
+}
+
+// normalizeDomain validates a root domain given on the command line. IP
+// addresses and names under an all-numeric TLD are refused.
+func normalizeDomain(s string) (string, error) {
+	if strings.Contains(strings.TrimPrefix(strings.TrimSpace(s), "*."), "*") { return "", fmt.Errorf("%q: wildcards are not allowed in a root domain", s) }
+	if net.ParseIP(strings.Trim(strings.TrimSpace(s), "[]")) != nil { return "", fmt.Errorf("%q: an IP address, not a domain", s) }
+	h, err := normalizeHost(s); if err != nil { return "", err }
+	if !strings.Contains(h, ".") { return "", fmt.Errorf("%q: not a fully qualified domain", s) }
+	if tld := h[strings.LastIndex(h, ".")+1:]; strings.Trim(tld, "0123456789") == "" { return "", fmt.Errorf("%q: top-level domain %q is all-numeric", s, tld) }
+	return h, nil
+}
+
+func underDomain(host, domain string) bool { return host == domain || strings.HasSuffix(host, "."+domain) }
+
+// normalizeResults cleans enumerator output for domain, dropping invalid
+// names and names outside the queried domain.
+func normalizeResults(domain string, lines []string) (hosts, dropped []string) {
+	for _, l := range lines {
+		h, err := normalizeHost(l)
+		if err != nil || !underDomain(h, domain) { dropped = append(dropped, l); continue }
+		hosts = append(hosts, h)
+	}
+	return uniqueSorted(hosts), dropped
+}
+
+// displayHost renders punycode labels as Unicode.
+func displayHost(h string) string {
+	if !strings.Contains(h, "xn--") { return h }
+	labels := strings.Split(h, ".")
+	for i, l := range labels {
+		if strings.HasPrefix(l, "xn--") { if u, err := punyDecode(l[4:]); err == nil { labels[i] = u } }
+	}
+	return strings.Join(labels, ".")
+}
+
+// hostLabel is h, followed by its Unicode form when it is an IDN.
+func hostLabel(h string) string {
+	if u := displayHost(h); u != h { return h + " (" + u + ")" }
+	return h
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ { if s[i] >= utf8.RuneSelf { return false } }
+	return true
+}
+
+func warnDropped(w io.Writer, domain string, dropped []string) {
+	if len(dropped) == 0 { return }
+	msg := fmt.Sprintf("%s: dropped %d invalid/out-of-domain result(s)", domain, len(dropped))
+	if len(dropped) <= 5 { msg += ": " + strings.Join(dropped, ", ") }
+	fmt.Fprintln(w, msg)
+}
+
+// ---------- punycode (RFC 3492) ----------
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+func punyAdapt(delta, numPoints int32, first bool) int32 {
+	if first { delta /= punyDamp } else { delta /= 2 }
+	delta += delta / numPoints
+	k := int32(0)
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 { delta /= punyBase - punyTMin; k += punyBase }
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+func punyDigit(d int32) byte {
+	if d < 26 { return byte('a' + d) }
+	return byte('0' + d - 26)
+}
+func punyThreshold(k, bias int32) int32 {
+	switch {
+	case k <= bias:
+		return punyTMin
+	case k >= bias+punyTMax:
+		return punyTMax
+	}
+	return k - bias
+}
+
+func punyEncode(s string) (string, error) {
+	var out []byte
+	runes := []rune(s)
+	for _, r := range runes { if r < 0x80 { out = append(out, byte(r)) } }
+	b := int32(len(out))
+	h := b
+	if b > 0 { out = append(out, '-') }
+	n, delta, bias := int32(punyInitialN), int32(0), int32(punyInitialBias)
+	for h < int32(len(runes)) {
+		m := int32(0x7fffffff)
+		for _, r := range runes { if r >= n && r < m { m = r } }
+		if (m-n) > (0x7fffffff-delta)/(h+1) { return "", errors.New("punycode overflow") }
+		delta += (m - n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if r < n { delta++; if delta < 0 { return "", errors.New("punycode overflow") } }
+			if r != n { continue }
+			q := delta
+			for k := int32(punyBase); ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t { break }
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++; n++
+	}
+	return string(out), nil
+}
+
+func punyDecode(s string) (string, error) {
+	var out []rune
+	pos := 0
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		for _, r := range s[:i] { if r >= 0x80 { return "", errors.New("non-ASCII in punycode") } }
+		out = []rune(s[:i])
+		pos = i + 1
+	}
+	n, i, bias := int32(punyInitialN), int32(0), int32(punyInitialBias)
+	for pos < len(s) {
+		oldi, w := i, int32(1)
+		for k := int32(punyBase); ; k += punyBase {
+			if pos >= len(s) { return "", errors.New("truncated punycode") }
+			c := s[pos]; pos++
+			var d int32
+			switch {
+			case c >= 'a' && c <= 'z':
+				d = int32(c - 'a')
+			case c >= 'A' && c <= 'Z':
+				d = int32(c - 'A')
+			case c >= '0' && c <= '9':
+				d = int32(c-'0') + 26
+			default:
+				return "", fmt.Errorf("bad punycode digit %q", c)
+			}
+			if d > (0x7fffffff-i)/w { return "", errors.New("punycode overflow") }
+			i += d * w
+			t := punyThreshold(k, bias)
+			if d < t { break }
+			w *= punyBase - t
+		}
+		l := int32(len(out) + 1)
+		bias = punyAdapt(i-oldi, l, oldi == 0)
+		n += i / l
+		i %= l
+		if n > utf8.MaxRune { return "", errors.New("punycode out of range") }
+		out = append(out[:i], append([]rune{n}, out[i:]...)...)
+		i++
+	}
+	return string(out), nil
+}
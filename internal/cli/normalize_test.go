@@ -0,0 +1,68 @@
+package cli
+
+import "testing"
+
+// RFC 3492, section 7.1.
+var punycodeSamples = []struct{ name, unicode, encoded string }{
+	{"Arabic (Egyptian)", "ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+	{"Chinese (simplified)", "他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+	{"Chinese (traditional)", "他們爲什麽不說中文", "ihqwctvzc91f659drss3x8bo0yb"},
+	{"Czech", "Pročprostěnemluvíčesky", "Proprostnemluvesky-uyb24dma41a"},
+	{"Hebrew", "למההםפשוטלאמדבריםעברית", "4dbcagdahymbxekheh6e0a7fei0b"},
+	{"Hindi (Devanagari)", "यहलोगहिन्दीक्योंनहींबोलसकतेहैं", "i1baa7eci9glrd9b2ae1bj0hfcgg6iyaf8o0a1dig0cd"},
+	{"Japanese (kanji and hiragana)", "なぜみんな日本語を話してくれないのか", "n8jok5ay5dzabd5bym9f0cm5685rrjetr6pdxa"},
+	{"Korean (Hangul syllables)", "세계의모든사람들이한국어를이해한다면얼마나좋을까", "989aomsvi5e83db1d2a355cv1e0vak1dwrv93d5xbh15a0dt30a5jpsd879ccm6fea98c"},
+	{"Russian (Cyrillic)", "почемужеонинеговорятпорусски", "b1abfaaepdrnnbgefbadotcwatmq2g4l"},
+	{"Spanish", "PorquénopuedensimplementehablarenEspañol", "PorqunopuedensimplementehablarenEspaol-fmd56a"},
+	{"Vietnamese", "TạisaohọkhôngthểchỉnóitiếngViệt", "TisaohkhngthchnitingVit-kjcr8268qyxafd2f1b9g"},
+	{"Japanese 3nen B gumi", "3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+	{"Japanese Amuro Namie", "安室奈美恵-with-SUPER-MONKEYS", "-with-SUPER-MONKEYS-pc58ag80a8qai00g7n9n"},
+	{"Japanese Hello Another Way", "Hello-Another-Way-それぞれの場所", "Hello-Another-Way--fc4qua05auwb3674vfr0b"},
+	{"Japanese under one roof 2", "ひとつ屋根の下2", "2-u9tlzr9756bt3uc0v"},
+	{"Japanese Maji de Koi suru", "MajiでKoiする5秒前", "MajiKoi5-783gue6qz075azm5e"},
+	{"Japanese Puffy de Rumba", "パフィーdeルンバ", "de-jg4avhby1noc0d"},
+	{"Japanese at that speed", "そのスピードで", "d9juau41awczczp"},
+	{"ASCII only", "-> $1.00 <-", "-> $1.00 <--"},
+}
+
+func TestPunycode(t *testing.T) {
+	for _, tt := range punycodeSamples {
+		if got, err := punyEncode(tt.unicode); err != nil || got != tt.encoded {
+			t.Errorf("%s: punyEncode = %q, %v; want %q", tt.name, got, err, tt.encoded)
+		}
+		if got, err := punyDecode(tt.encoded); err != nil || got != tt.unicode {
+			t.Errorf("%s: punyDecode = %q, %v; want %q", tt.name, got, err, tt.unicode)
+		}
+	}
+	for _, bad := range []string{"a$", "99999999999", "egbpdaj6bu4bxfgehfvwx9"} {
+		if got, err := punyDecode(bad); err == nil { t.Errorf("punyDecode(%q) = %q, want an error", bad, got) }
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Example.COM.", "example.com"},
+		{"*.example.com", "example.com"},
+		{"bücher.de", "xn--bcher-kva.de"},
+		{"xn--bcher-kva.de", "xn--bcher-kva.de"},
+		{"123.example.com", "123.example.com"},
+		{"example.xn--p1ai", "example.xn--p1ai"},
+		{"10.0.0.1", ""},
+		{"[2001:db8::1]", ""},
+		{"2001:db8::1", ""},
+		{"1.2.3.4.5", ""},
+		{"example.123", ""},
+		{"example", ""},
+		{"a.*.example.com", ""},
+		{"-bad.example.com", ""},
+		{"xn--zz-.example.com", ""},
+	}
+	for _, tt := range tests {
+		got, err := normalizeDomain(tt.in)
+		if tt.want == "" {
+			if err == nil { t.Errorf("normalizeDomain(%q) = %q, want an error", tt.in, got) }
+			continue
+		}
+		if err != nil || got != tt.want { t.Errorf("normalizeDomain(%q) = %q, %v; want %q", tt.in, got, err, tt.want) }
+	}
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const PendingRelDir = "pending"
+
+func pendingDir() string { return filepath.Join(homeDir(), PendingRelDir) }
+
+// pendingNotification is a message that could not be delivered because the
+// run was interrupted or the channel was unreachable. It is retried by the
+// next scan (or `domwatch notify-flush`).
+type pendingNotification struct {
+	Channel string    `json:"channel"`
+	Title   string    `json:"title"`
+	Lines   []string  `json:"lines"`
+	Style   string    `json:"style,omitempty"` // "" Markdown text, "plain" text, "embed" (Discord)
+	Sent    int       `json:"sent,omitempty"`  // leading messages already delivered
+	Created time.Time `json:"created"`
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// notify sends title/lines to every configured channel.
+func notify(ctx context.Context, errw io.Writer, title string, lines []string) {
+	for _, ch := range []string{"discord", "telegram"} {
+		deliver(ctx, errw, pendingNotification{Channel: ch, Title: title, Lines: lines})
+	}
+}
+
+// deliver sends n to its channel. A delivery that was cut short by ctx or
+// failed in transport is spooled to disk, without the messages that already
+// went out; rejected requests (HTTP status errors) are only reported.
+func deliver(ctx context.Context, errw io.Writer, n pendingNotification) {
+	sent, err := send(ctx, n)
+	if err == nil || errors.Is(err, errNoChannel) { return }
+	countNotifyFailure(n.Channel)
+	fmt.Fprintf(errw, "%s notify error: %v\n", channelNames[n.Channel], err)
+	var ue *url.Error
+	if ctx.Err() != nil || errors.As(err, &ue) {
+		n.Created, n.Sent = time.Now(), sent
+		if err := spoolNotification(n); err != nil {
+			fmt.Fprintln(errw, "error: could not spool notification:", err)
+		} else {
+			fmt.Fprintf(errw, "%s notification saved to %s for retry\n", channelNames[n.Channel], pendingDir())
+		}
+	}
+}
+
+var (
+	errNoChannel = errors.New("channel not configured")
+	channelNames = map[string]string{"discord": "Discord", "telegram": "Telegram"}
+)
+
+// send delivers the messages of n after the first n.Sent and returns how
+// many of its messages have been delivered.
+func send(ctx context.Context, n pendingNotification) (int, error) {
+	if ctx.Err() != nil { return n.Sent, ctx.Err() }
+	switch n.Channel {
+	case "discord":
+		d := getDiscordWebhook(); if d == "" { return n.Sent, errNoChannel }
+		if n.Style == "embed" { return postDiscordEmbed(ctx, d, n.Title, n.Lines, n.Sent) }
+		return postDiscord(ctx, d, n.Title, n.Lines, n.Sent)
+	case "telegram":
+		tb, tc := getTelegram(); if tb == "" || tc == "" { return n.Sent, errNoChannel }
+		return postTelegram(ctx, tb, tc, n.Title, n.Lines, n.Style == "plain", n.Sent)
+	}
+	return n.Sent, fmt.Errorf("unknown channel %q", n.Channel)
+}
+
+// AI summary delivery per channel (config set-ai-notify).
+var aiNotifyModes = map[string][]string{"discord": {"message", "embed", "off"}, "telegram": {"message", "off"}}
+
+func aiNotifyMode(cfg *Config, channel string) string {
+	m := cfg.DiscordAISummary
+	if channel == "telegram" { m = cfg.TelegramAISummary }
+	if m == "" { return "message" }
+	return m
+}
+
+// notifyAISummary sends a scan's AI summary as a follow-up to the new-hosts
+// notification: a message (plain text on Telegram) or a Discord embed.
+func notifyAISummary(ctx context.Context, errw io.Writer, domain string, count int, summary string) {
+	cfg, err := loadConfig(); if err != nil { fmt.Fprintln(errw, "error:", err); return }
+	lines := strings.Split(strings.TrimSpace(summary), "\n")
+	for _, ch := range []string{"discord", "telegram"} {
+		n := pendingNotification{Channel: ch, Lines: lines}
+		switch aiNotifyMode(cfg, ch) {
+		case "off":
+			continue
+		case "embed":
+			n.Style, n.Title = "embed", fmt.Sprintf("🤖 AI summary for %s (%d new)", domain, count)
+		default:
+			n.Title = fmt.Sprintf("🤖 AI summary for **%s** (%d new) — %s", domain, count, time.Now().Format(time.RFC3339))
+			if ch == "telegram" { n.Style, n.Title = "plain", strings.ReplaceAll(n.Title, "**", "") }
+		}
+		deliver(ctx, errw, n)
+	}
+}
+
+func spoolNotification(n pendingNotification) error {
+	if err := os.MkdirAll(pendingDir(), 0o700); err != nil { return err }
+	b, _ := json.MarshalIndent(n, "", "  ")
+	p := filepath.Join(pendingDir(), fmt.Sprintf("%d-%s.json", n.Created.UnixNano(), n.Channel))
+	return writeFileAtomic(p, b, 0o600)
+}
+
+// flushPending retries spooled notifications, oldest first, and returns how
+// many were delivered. Messages a channel rejects or that are no longer
+// configured are dropped so the spool cannot grow forever.
+func flushPending(ctx context.Context, errw io.Writer) int {
+	entries, err := os.ReadDir(pendingDir()); if err != nil || len(entries) == 0 { return 0 }
+	// another run already flushing would send the same messages twice
+	l, err := acquireLock(ctx, "pending", 0, errw); if err != nil { return 0 }
+	defer l.unlock()
+	var names []string
+	for _, e := range entries { if strings.HasSuffix(e.Name(), ".json") { names = append(names, e.Name()) } }
+	sort.Strings(names)
+	sent := 0
+	for _, name := range names {
+		if ctx.Err() != nil { break }
+		p := filepath.Join(pendingDir(), name)
+		b, err := os.ReadFile(p); if err != nil { continue }
+		var n pendingNotification
+		if err := json.Unmarshal(b, &n); err != nil { fmt.Fprintln(errw, "dropping bad spool file", p, err); os.Remove(p); continue }
+		done, err := send(ctx, n)
+		if err != nil && !errors.Is(err, errNoChannel) { countNotifyFailure(n.Channel) }
+		var ue *url.Error
+		switch {
+		case err == nil:
+			sent++
+			os.Remove(p)
+		case ctx.Err() != nil || errors.As(err, &ue):
+			fmt.Fprintf(errw, "pending %s notification still undeliverable: %v\n", n.Channel, err)
+			// keep what went out this time from being sent again
+			if done > n.Sent {
+				n.Sent = done
+				b, _ := json.MarshalIndent(n, "", "  ")
+				if err := writeFileAtomic(p, b, 0o600); err != nil { fmt.Fprintln(errw, "error: could not update spool file:", err) }
+			}
+			return sent
+		default:
+			fmt.Fprintf(errw, "dropping pending %s notification: %v\n", n.Channel, err)
+			os.Remove(p)
+		}
+	}
+	return sent
+}
+
+func cmdNotifyFlush(ctx context.Context, a cmdArgs) int {
+	n := flushPending(ctx, os.Stderr)
+	left, _ := os.ReadDir(pendingDir())
+	fmt.Printf("delivered %d pending notification(s), %d left\n", n, len(left))
+	if len(left) > 0 { return 1 }
+	return 0
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubDiscord records the messages posted to it and drops the connection
+// of request number failAt (1-based; 0 = never).
+type stubDiscord struct {
+	mu     sync.Mutex
+	n      int
+	failAt int
+	got    []string
+}
+
+func (s *stubDiscord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.n++; s.n == s.failAt {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+		return
+	}
+	var p struct{ Content string }
+	json.NewDecoder(r.Body).Decode(&p)
+	s.got = append(s.got, p.Content)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestDeliverSpoolsUnsentChunks(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	t.Setenv("DISCORD_WEBHOOK_URL", "")
+	t.Setenv("TELEGRAM_BOT_TOKEN", "")
+	stub := &stubDiscord{failAt: 2}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+	if err := saveConfig(&Config{DiscordWebhookURL: srv.URL}); err != nil { t.Fatal(err) }
+
+	// one line per message
+	var lines []string
+	for _, c := range []string{"a", "b", "c"} { lines = append(lines, c+strings.Repeat("x", 1000)) }
+	deliver(context.Background(), io.Discard, pendingNotification{Channel: "discord", Title: "title", Lines: lines})
+	if len(stub.got) != 1 || !strings.Contains(stub.got[0], lines[0]) { t.Fatalf("first delivery got %d messages", len(stub.got)) }
+	entries, _ := os.ReadDir(pendingDir())
+	if len(entries) != 1 { t.Fatalf("%d spool files, want 1", len(entries)) }
+	b, _ := os.ReadFile(filepath.Join(pendingDir(), entries[0].Name()))
+	var n pendingNotification
+	if err := json.Unmarshal(b, &n); err != nil || n.Sent != 1 { t.Fatalf("spooled %+v (%v), want sent 1", n, err) }
+
+	// the retry sends the second and third message only
+	stub.got, stub.failAt = nil, 0
+	if sent := flushPending(context.Background(), io.Discard); sent != 1 { t.Errorf("flushPending = %d, want 1", sent) }
+	if len(stub.got) != 2 || !strings.Contains(stub.got[0], lines[1]) || !strings.Contains(stub.got[1], lines[2]) {
+		t.Errorf("retry sent %d messages, want the last two", len(stub.got))
+	}
+	if entries, _ := os.ReadDir(pendingDir()); len(entries) != 0 { t.Errorf("%d spool files left", len(entries)) }
+}
+
+func TestPostChunks(t *testing.T) {
+	var got []string
+	post := func(m string) error { got = append(got, m); return nil }
+	if n, err := postChunks(context.Background(), []string{"1", "2"}, 5, post); n != 2 || err != nil || len(got) != 0 { t.Errorf("skip past the end: %d, %v, %q", n, err, got) }
+
+	// a cancel during the pause after the last message is not a failure
+	ctx, cancel := context.WithCancel(context.Background())
+	post = func(m string) error { got = append(got, m); cancel(); return nil }
+	if n, err := postChunks(ctx, []string{"1", "2"}, 1, post); n != 2 || err != nil || len(got) != 1 || got[0] != "2" { t.Errorf("last message: %d, %v, %q", n, err, got) }
+	got = nil
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	if n, err := postChunks(ctx, []string{"1", "2"}, 0, post); n != 1 || err == nil { t.Errorf("cancelled after the first: %d, %v, %q", n, err, got) }
+}
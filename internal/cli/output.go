@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// outputFormat selects how commands print results. Field names of the JSON
+// forms are part of the CLI's interface (see "Machine-readable output" in
+// the README): add fields, never rename or remove them.
+type outputFormat string
+
+const (
+	formatText  outputFormat = ""
+	formatJSON  outputFormat = "json"
+	formatJSONL outputFormat = "jsonl"
+	formatCSV   outputFormat = "csv"
+)
+
+// outputFlag reads the --json, --jsonl and --csv flags the command has.
+func outputFlag(a cmdArgs) (outputFormat, error) {
+	var f outputFormat
+	for _, name := range []outputFormat{formatJSON, formatJSONL, formatCSV} {
+		if a.fs.Lookup(string(name)) == nil || !a.bool(string(name)) { continue }
+		if f != formatText { return "", fmt.Errorf("use only one of --json, --jsonl and --csv") }
+		f = name
+	}
+	return f, nil
+}
+
+// writeRecords prints recs as a JSON array, JSON lines or CSV; columns and
+// row give the CSV view of a record.
+func writeRecords[T any](w io.Writer, f outputFormat, recs []T, columns []string, row func(T) []string) error {
+	switch f {
+	case formatJSON:
+		if recs == nil { recs = []T{} }
+		return writeJSON(w, recs)
+	case formatJSONL:
+		enc := json.NewEncoder(w)
+		for _, r := range recs { if err := enc.Encode(r); err != nil { return err } }
+		return nil
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(columns)
+		for _, r := range recs { cw.Write(row(r)) }
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unsupported output format %q", f)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// optTime is t, or nil for the zero time so that omitempty drops it.
+func optTime(t time.Time) *time.Time {
+	if t.IsZero() { return nil }
+	return &t
+}
+
+// CSV cell helpers.
+func csvTime(t time.Time) string {
+	if t.IsZero() { return "" }
+	return t.UTC().Format(time.RFC3339)
+}
+func csvOptTime(t *time.Time) string {
+	if t == nil { return "" }
+	return csvTime(*t)
+}
+func csvFloat(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+func csvList(v []string) string  { return strings.Join(v, " ") }
+
+// ---------- list / config views ----------
+
+// HostRecord is one inventory host (`list <domain> --json`).
+type HostRecord struct {
+	Host    string `json:"host"`
+	Unicode string `json:"unicode,omitempty"` // display form of IDN hosts
+	InScope bool   `json:"in_scope"`
+}
+
+func hostRecords(domain string, hosts []string) []HostRecord {
+	_, oos := splitScope(domain, hosts)
+	out := map[string]bool{}
+	for _, h := range oos { out[h] = true }
+	recs := make([]HostRecord, 0, len(hosts))
+	for _, h := range hosts {
+		r := HostRecord{Host: h, InScope: !out[h]}
+		if u := displayHost(h); u != h { r.Unicode = u }
+		recs = append(recs, r)
+	}
+	return recs
+}
+
+func listHostRecords(f outputFormat, domain string, hosts []string) int {
+	recs := hostRecords(domain, hosts)
+	return exitWrite(writeRecords(os.Stdout, f, recs, []string{"host", "unicode", "in_scope"}, func(r HostRecord) []string {
+		return []string{r.Host, r.Unicode, strconv.FormatBool(r.InScope)}
+	}))
+}
+
+// DomainRecord is one monitored root domain (`list --program/--tag --json`).
+type DomainRecord struct {
+	Domain   string   `json:"domain"`
+	Program  string   `json:"program,omitempty"`
+	Tags     []string `json:"tags"`
+	Priority int      `json:"priority"`
+	Paused   bool     `json:"paused"`
+}
+
+func domainRecords(domains []string) ([]DomainRecord, error) {
+	reg, err := loadRegistry(); if err != nil { return nil, err }
+	recs := make([]DomainRecord, 0, len(domains))
+	for _, d := range domains {
+		r := DomainRecord{Domain: d, Tags: []string{}}
+		if t := reg.Targets[d]; t != nil {
+			r.Program, r.Priority, r.Paused = t.Program, t.Priority, t.Paused
+			if t.Tags != nil { r.Tags = t.Tags }
+		}
+		recs = append(recs, r)
+	}
+	return recs, nil
+}
+
+func listDomainRecords(f outputFormat, domains []string) int {
+	recs, err := domainRecords(domains); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	return exitWrite(writeRecords(os.Stdout, f, recs, []string{"domain", "program", "tags", "priority", "paused"}, func(r DomainRecord) []string {
+		return []string{r.Domain, r.Program, csvList(r.Tags), strconv.Itoa(r.Priority), strconv.FormatBool(r.Paused)}
+	}))
+}
+
+// configRecord is `config show --json`; secrets are masked as in the text
+// form. An AI setup that does not resolve is shown as configured, with the
+// reason in AIError.
+type configRecord struct {
+	Home              string  `json:"home"`
+	Config            string  `json:"config"`
+	DiscordWebhookURL string  `json:"discord_webhook_url"`
+	TelegramBotToken  string  `json:"telegram_bot_token"`
+	TelegramChatID    string  `json:"telegram_chat_id"`
+	OpenAIAPIKey      string  `json:"openai_api_key"`
+	AIProvider        string  `json:"ai_provider"`
+	AIModel           string  `json:"ai_model"`
+	AIEndpoint        string  `json:"ai_endpoint"`
+	AITemperature     float64 `json:"ai_temperature"`
+	AIAPIKey          string  `json:"ai_api_key"`
+	AIError           string  `json:"ai_error,omitempty"`
+	DiscordAISummary  string  `json:"discord_ai_summary"`
+	TelegramAISummary string  `json:"telegram_ai_summary"`
+	APIToken          string  `json:"api_token"`
+	SnapshotKeep      int     `json:"snapshot_keep"`
+	SnapshotMaxDays   int     `json:"snapshot_max_days"`
+}
+
+func newConfigRecord(cfg *Config) configRecord {
+	m := func(s string) string { if strings.TrimSpace(s) == "" { return "" }; return mask(s) }
+	r := configRecord{Home: homeDir(), Config: configPath(), DiscordWebhookURL: m(cfg.DiscordWebhookURL), TelegramBotToken: m(cfg.TelegramBotToken),
+		TelegramChatID: m(cfg.TelegramChatID), OpenAIAPIKey: m(cfg.OpenAIAPIKey), AIAPIKey: m(cfg.AIAPIKey),
+		DiscordAISummary: aiNotifyMode(cfg, "discord"), TelegramAISummary: aiNotifyMode(cfg, "telegram"), APIToken: m(cfg.APIToken),
+		SnapshotKeep: cfg.SnapshotKeep, SnapshotMaxDays: cfg.SnapshotMaxDays}
+	if ai, err := resolveAI(cfg); err != nil {
+		r.AIProvider, r.AIModel, r.AIEndpoint, r.AIError = cfg.AIProvider, cfg.AIModel, cfg.AIEndpoint, err.Error()
+		r.AITemperature = DefaultAITemperature
+		if cfg.AITemperature != nil { r.AITemperature = *cfg.AITemperature }
+	} else {
+		r.AIProvider, r.AIModel, r.AIEndpoint, r.AITemperature = ai.Provider, ai.Model, ai.Endpoint, ai.Temperature
+	}
+	return r
+}
+
+func configRecords(f outputFormat) int {
+	cfg, err := loadConfig(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	r := newConfigRecord(cfg)
+	switch f {
+	case formatJSON:
+		return exitWrite(writeJSON(os.Stdout, r))
+	case formatJSONL:
+		return exitWrite(writeRecords(os.Stdout, f, []configRecord{r}, nil, nil))
+	}
+	// CSV is key,value rows
+	kv := [][2]string{{"home", r.Home}, {"config", r.Config}, {"discord_webhook_url", r.DiscordWebhookURL},
+		{"telegram_bot_token", r.TelegramBotToken}, {"telegram_chat_id", r.TelegramChatID}, {"openai_api_key", r.OpenAIAPIKey},
+		{"ai_provider", r.AIProvider}, {"ai_model", r.AIModel}, {"ai_endpoint", r.AIEndpoint}, {"ai_temperature", strconv.FormatFloat(r.AITemperature, 'g', -1, 64)},
+		{"ai_api_key", r.AIAPIKey}}
+	if r.AIError != "" { kv = append(kv, [2]string{"ai_error", r.AIError}) }
+	kv = append(kv, [][2]string{{"discord_ai_summary", r.DiscordAISummary}, {"telegram_ai_summary", r.TelegramAISummary}, {"api_token", r.APIToken},
+		{"snapshot_keep", strconv.Itoa(r.SnapshotKeep)}, {"snapshot_max_days", strconv.Itoa(r.SnapshotMaxDays)}}...)
+	return exitWrite(writeRecords(os.Stdout, f, kv, []string{"key", "value"}, func(p [2]string) []string { return p[:] }))
+}
+
+func exitWrite(err error) int {
+	if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	return 0
+}
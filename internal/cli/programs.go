@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Program groups the root domains owned by one bug bounty program or client.
+type Program struct {
+	Owner   string   `json:"owner,omitempty"`
+	Notes   string   `json:"notes,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Exclude []string `json:"exclude,omitempty"` // scope exclusions for all its domains (IPs, CIDRs)
+}
+
+// Target is the per-domain metadata kept next to domains.txt.
+type Target struct {
+	Program  string   `json:"program,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Interval string   `json:"interval,omitempty"` // Go duration, e.g. "12h"
+	Cron     string   `json:"cron,omitempty"`     // 5-field cron, wins over Interval
+	Priority int      `json:"priority,omitempty"` // higher scans first
+	Paused   bool     `json:"paused,omitempty"`
+}
+
+// Registry is the on-disk programs.json.
+type Registry struct {
+	Programs map[string]*Program `json:"programs"`
+	Targets  map[string]*Target  `json:"targets"`
+}
+
+const RegistryRelPath = "programs.json"
+
+func registryPath() string { return filepath.Join(homeDir(), RegistryRelPath) }
+
+func loadRegistry() (*Registry, error) {
+	r := &Registry{}
+	b, err := os.ReadFile(registryPath())
+	if err != nil && !os.IsNotExist(err) { return nil, err }
+	if err == nil {
+		if err := json.Unmarshal(b, r); err != nil { return nil, fmt.Errorf("%s: %w", registryPath(), err) }
+	}
+	if r.Programs == nil { r.Programs = map[string]*Program{} }
+	if r.Targets == nil { r.Targets = map[string]*Target{} }
+	return r, nil
+}
+func saveRegistry(r *Registry) error {
+	b, _ := json.MarshalIndent(r, "", "  ")
+	return writeFileAtomic(registryPath(), b, 0o644)
+}
+
+// target returns the metadata for domain, creating it when missing.
+func (r *Registry) target(domain string) *Target {
+	t := r.Targets[domain]
+	if t == nil { t = &Target{}; r.Targets[domain] = t }
+	return t
+}
+
+// programName returns the registered spelling of program. Program names
+// match case-insensitively everywhere (see selectDomains); the first
+// spelling used is kept.
+func (r *Registry) programName(program string) string {
+	if _, ok := r.Programs[program]; ok { return program }
+	for n := range r.Programs { if strings.EqualFold(n, program) { return n } }
+	return program
+}
+
+// assign moves domain into program (creating the program if needed) and adds tags.
+func (r *Registry) assign(domain, program string, tags []string) {
+	t := r.target(domain)
+	if program != "" {
+		program = r.programName(program)
+		t.Program = program
+		if r.Programs[program] == nil { r.Programs[program] = &Program{} }
+	}
+	if len(tags) > 0 { t.Tags = uniqueSorted(append(t.Tags, tags...)) }
+}
+
+// hasTag matches tags set on the domain itself or on its program.
+func (r *Registry) hasTag(domain, tag string) bool {
+	t := r.Targets[domain]
+	if t == nil { return false }
+	for _, x := range t.Tags { if strings.EqualFold(x, tag) { return true } }
+	if p := r.Programs[t.Program]; p != nil {
+		for _, x := range p.Tags { if strings.EqualFold(x, tag) { return true } }
+	}
+	return false
+}
+
+func (r *Registry) programOf(domain string) string {
+	if t := r.Targets[domain]; t != nil { return t.Program }
+	return ""
+}
+
+// selectDomains filters domains.txt by program and/or tag (empty = any).
+func selectDomains(program, tag string) ([]string, error) {
+	all, err := readLines(filepath.Join(homeDir(), "domains.txt")); if err != nil { return nil, err }
+	if program == "" && tag == "" { return all, nil }
+	reg, err := loadRegistry(); if err != nil { return nil, err }
+	var out []string
+	for _, d := range all {
+		d = strings.ToLower(d)
+		if program != "" && !strings.EqualFold(reg.programOf(d), program) { continue }
+		if tag != "" && !reg.hasTag(d, tag) { continue }
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// domainArg is positional argument i normalized as a root domain, the way
+// `add` stored it: lowercase, punycode, no trailing dot.
+func domainArg(a cmdArgs, i int) (string, error) { return normalizeDomain(a.args[i]) }
+
+// monitoredDomain normalizes a domain argument and checks that it is in
+// domains.txt, so no metadata is recorded for a typo.
+func monitoredDomain(arg string) (string, error) {
+	d, err := normalizeDomain(arg); if err != nil { return "", err }
+	if !monitored(d) { return "", fmt.Errorf("%s is not monitored (add it with: domwatch add %s)", d, d) }
+	return d, nil
+}
+
+// splitTags accepts repeated and comma-separated --tag values.
+func splitTags(vals []string) []string {
+	var out []string
+	for _, v := range vals {
+		for _, t := range strings.Split(v, ",") { if t = strings.TrimSpace(t); t != "" { out = append(out, t) } }
+	}
+	return out
+}
+
+// ---------- stats ----------
+type programStats struct {
+	Domains, Hosts, OutOfScope, NewRecent int
+}
+
+// statsFor sums inventory sizes and hosts discovered in the last `recent`.
+func statsFor(domains []string, recent time.Duration) programStats {
+	var st programStats
+	cutoff := time.Now().Add(-recent).Unix()
+	entries, _ := os.ReadDir(dataDir())
+	for _, d := range domains {
+		st.Domains++
+		hosts, _ := readLines(filepath.Join(dataDir(), d+".txt"))
+		st.Hosts += len(hosts)
+		_, oos := splitScope(d, hosts)
+		st.OutOfScope += len(oos)
+		for _, e := range entries {
+			name := e.Name()
+			if !strings.HasPrefix(name, d+"_new_") || !strings.HasSuffix(name, ".txt") { continue }
+			var ts int64; fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, d+"_new_"), ".txt"), "%d", &ts)
+			if ts >= cutoff { n, _ := readLines(filepath.Join(dataDir(), name)); st.NewRecent += len(n) }
+		}
+	}
+	return st
+}
+
+// ---------- commands ----------
+func cmdProgram(ctx context.Context, a cmdArgs) int {
+	reg, err := loadRegistry(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	pos := a.args
+	if a.sub != "list" { pos[0] = reg.programName(pos[0]) }
+	switch a.sub {
+	case "list":
+		names := map[string]bool{}
+		for n := range reg.Programs { names[n] = true }
+		all, _ := readLines(filepath.Join(homeDir(), "domains.txt"))
+		for _, d := range all { if reg.programOf(d) == "" { names[""] = true } }
+		var sorted []string; for n := range names { sorted = append(sorted, n) }
+		sort.Strings(sorted)
+		fmt.Printf("%-24s %8s %8s %8s %8s\n", "PROGRAM", "DOMAINS", "HOSTS", "OOS", "NEW(7d)")
+		for _, n := range sorted {
+			ds, _ := selectDomains(n, "")
+			if n == "" {
+				ds = nil
+				for _, d := range all { if reg.programOf(d) == "" { ds = append(ds, d) } }
+			}
+			st := statsFor(ds, 7*24*time.Hour)
+			label := n; if label == "" { label = "(none)" }
+			fmt.Printf("%-24s %8d %8d %8d %8d\n", label, st.Domains, st.Hosts, st.OutOfScope, st.NewRecent)
+		}
+		return 0
+	case "show":
+		p := reg.Programs[pos[0]]
+		if p == nil { fmt.Println("no such program:", pos[0]); return 1 }
+		ds, _ := selectDomains(pos[0], "")
+		st := statsFor(ds, 7*24*time.Hour)
+		fmt.Println("program:", pos[0])
+		if p.Owner != "" { fmt.Println("owner  :", p.Owner) }
+		if p.Notes != "" { fmt.Println("notes  :", p.Notes) }
+		if len(p.Tags) > 0 { fmt.Println("tags   :", strings.Join(p.Tags, ",")) }
+		if len(p.Exclude) > 0 { fmt.Println("exclude:", strings.Join(p.Exclude, " ")) }
+		fmt.Printf("stats  : %d domains, %d hosts, %d out-of-scope, %d new in 7d\n", st.Domains, st.Hosts, st.OutOfScope, st.NewRecent)
+		for _, d := range ds {
+			if t := reg.Targets[d]; t != nil && len(t.Tags) > 0 { fmt.Printf("  %s [%s]\n", d, strings.Join(t.Tags, ",")) } else { fmt.Println(" ", d) }
+		}
+		return 0
+	case "set":
+		p := reg.Programs[pos[0]]; if p == nil { p = &Program{}; reg.Programs[pos[0]] = p }
+		if a.isSet("owner") { p.Owner = a.str("owner") }
+		if a.isSet("notes") { p.Notes = a.str("notes") }
+		if v := splitTags(a.list("tag")); len(v) > 0 { p.Tags = uniqueSorted(append(p.Tags, v...)) }
+		if a.bool("clear-exclude") { p.Exclude = nil }
+		for _, r := range a.list("exclude") {
+			if _, err := parseScopeRule(r); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 2 }
+			p.Exclude = uniqueSorted(append(p.Exclude, r))
+		}
+	case "assign":
+		var ds []string
+		for _, arg := range pos[1:] {
+			d, err := monitoredDomain(arg); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+			ds = append(ds, d)
+		}
+		for _, d := range ds { reg.assign(d, pos[0], nil) }
+	case "rm":
+		delete(reg.Programs, pos[0])
+		for _, t := range reg.Targets { if strings.EqualFold(t.Program, pos[0]) { t.Program = "" } }
+	}
+	if err := saveRegistry(reg); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	fmt.Println("saved", registryPath())
+	return 0
+}
+
+func cmdTag(ctx context.Context, a cmdArgs) int {
+	domain, err := monitoredDomain(a.args[0]); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	reg, err := loadRegistry(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	t := reg.target(domain)
+	for _, arg := range a.args[1:] {
+		if strings.HasPrefix(arg, "-") {
+			// tags match case-insensitively (see hasTag)
+			var kept []string
+			for _, x := range t.Tags { if !strings.EqualFold(x, strings.TrimPrefix(arg, "-")) { kept = append(kept, x) } }
+			t.Tags = kept
+			continue
+		}
+		t.Tags = uniqueSorted(append(t.Tags, splitTags([]string{strings.TrimPrefix(arg, "+")})...))
+	}
+	if err := saveRegistry(reg); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	fmt.Printf("%s tags: %s\n", domain, strings.Join(t.Tags, ","))
+	return 0
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestProgramNameCase(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	for _, d := range []string{"example.com", "example.org"} {
+		if _, err := addDomain(d); err != nil { t.Fatal(err) }
+	}
+	reg, _ := loadRegistry()
+	reg.assign("example.com", "Acme", nil)
+	reg.assign("example.org", "ACME", []string{"prod"})
+	if len(reg.Programs) != 1 || reg.Programs["Acme"] == nil { t.Fatalf("programs = %v, want only Acme", reg.Programs) }
+	if got := reg.programOf("example.org"); got != "Acme" { t.Errorf("programOf = %q", got) }
+	if got := reg.programName("acme"); got != "Acme" { t.Errorf("programName = %q", got) }
+	if got := reg.programName("other"); got != "other" { t.Errorf("programName(unknown) = %q", got) }
+	if err := saveRegistry(reg); err != nil { t.Fatal(err) }
+	ds, err := selectDomains("aCmE", ""); if err != nil { t.Fatal(err) }
+	if len(ds) != 2 { t.Errorf("selectDomains = %v", ds) }
+}
+
+func TestMonitoredDomain(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	if _, err := addDomain("example.com"); err != nil { t.Fatal(err) }
+	for arg, want := range map[string]string{"example.com": "example.com", "EXAMPLE.com.": "example.com", " Example.COM ": "example.com"} {
+		if got, err := monitoredDomain(arg); err != nil || got != want { t.Errorf("monitoredDomain(%q) = %q, %v", arg, got, err) }
+	}
+	for _, arg := range []string{"example.org", "exmaple.com", "10.0.0.1", "*", "com"} {
+		if got, err := monitoredDomain(arg); err == nil { t.Errorf("monitoredDomain(%q) = %q, want an error", arg, got) }
+	}
+}
+
+func TestDomainArg(t *testing.T) {
+	for arg, want := range map[string]string{"münchen.de": "xn--mnchen-3ya.de", "Example.COM.": "example.com", "*.example.com": "example.com"} {
+		if got, err := domainArg(cmdArgs{args: []string{"x", arg}}, 1); err != nil || got != want { t.Errorf("domainArg(%q) = %q, %v; want %q", arg, got, err, want) }
+	}
+	if got, err := domainArg(cmdArgs{args: []string{"10.0.0.1"}}, 0); err == nil { t.Errorf("domainArg(10.0.0.1) = %q", got) }
+
+	// an IDN stored as punycode is found by its Unicode form too
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	if _, err := addDomain("xn--mnchen-3ya.de"); err != nil { t.Fatal(err) }
+	if err := writeLines(filepath.Join(dataDir(), "xn--mnchen-3ya.de.txt"), []string{"www.xn--mnchen-3ya.de"}); err != nil { t.Fatal(err) }
+	for _, args := range [][]string{{"list", "münchen.de."}, {"history", "MÜNCHEN.de"}, {"scope", "show", "xn--mnchen-3ya.de."}, {"remove", "münchen.de"}} {
+		c, _ := findCommand(args[0])
+		if code := c.exec(context.Background(), args[1:]); code != 0 { t.Errorf("domwatch %q: exit code %d", args, code) }
+	}
+	if monitored("xn--mnchen-3ya.de") { t.Error("remove münchen.de left the domain monitored") }
+}
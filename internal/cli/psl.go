@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ---------- Public Suffix List ----------
+
+// embeddedPSL is the snapshot shipped with the binary. `domwatch psl update
+// <file>` installs a newer copy under DOMWATCH_HOME, which takes precedence.
+//
+//go:embed public_suffix_list.dat
+var embeddedPSL string
+
+const PSLRelPath = "public_suffix_list.dat"
+
+func pslPath() string { return filepath.Join(homeDir(), PSLRelPath) }
+
+type pslRules struct {
+	exact, wildcard, exception map[string]bool
+	source                     string
+}
+
+var (
+	pslOnce   sync.Once
+	pslLoaded *pslRules
+)
+
+func parsePSL(data, source string) *pslRules {
+	r := &pslRules{exact: map[string]bool{}, wildcard: map[string]bool{}, exception: map[string]bool{}, source: source}
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "//") { continue }
+		if i := strings.IndexAny(line, " \t"); i >= 0 { line = line[:i] }
+		set := r.exact
+		switch {
+		case strings.HasPrefix(line, "!"):
+			set, line = r.exception, line[1:]
+		case strings.HasPrefix(line, "*."):
+			set, line = r.wildcard, line[2:]
+		}
+		if h, err := normalizeHost(line); err == nil { set[h] = true }
+	}
+	return r
+}
+
+func (r *pslRules) size() int { return len(r.exact) + len(r.wildcard) + len(r.exception) }
+
+func psl() *pslRules {
+	pslOnce.Do(func() {
+		if b, err := os.ReadFile(pslPath()); err == nil {
+			if r := parsePSL(string(b), pslPath()); r.size() > 0 { pslLoaded = r; return }
+			fmt.Fprintln(os.Stderr, "warning: ignoring empty public suffix list", pslPath())
+		}
+		pslLoaded = parsePSL(embeddedPSL, "embedded")
+	})
+	return pslLoaded
+}
+
+// publicSuffix returns the public suffix of a normalized host using the PSL
+// algorithm (longest match, wildcards, exceptions; default rule "*").
+func publicSuffix(host string) string {
+	r := psl()
+	labels := strings.Split(host, ".")
+	for i := range labels {
+		cand := strings.Join(labels[i:], ".")
+		if r.exception[cand] { return strings.Join(labels[i+1:], ".") }
+		if r.exact[cand] { return cand }
+		if i > 0 && r.wildcard[cand] { return strings.Join(labels[i-1:], ".") }
+	}
+	return labels[len(labels)-1]
+}
+
+func isPublicSuffix(host string) bool { return publicSuffix(host) == host }
+
+// registrableDomain is the public suffix plus one label (eTLD+1).
+func registrableDomain(host string) (string, error) {
+	suffix := publicSuffix(host)
+	if host == suffix { return "", fmt.Errorf("%s is a public suffix", host) }
+	rest := strings.TrimSuffix(host, "."+suffix)
+	return rest[strings.LastIndex(rest, ".")+1:] + "." + suffix, nil
+}
+
+// rootsFromHosts derives the unique registrable domains of a host list.
+// Wildcards count as their base host; IP addresses have no root and are
+// reported as bad.
+func rootsFromHosts(lines []string) (roots, bad []string) {
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l == "" || strings.HasPrefix(l, "#") { continue }
+		h := strings.TrimPrefix(assetHost(l), "*.")
+		if h == "" || strings.Contains(h, "*") || net.ParseIP(h) != nil { bad = append(bad, l); continue }
+		rd, err := registrableDomain(h)
+		if err != nil { bad = append(bad, l); continue }
+		roots = append(roots, rd)
+	}
+	return uniqueSorted(roots), bad
+}
+
+// ---------- command ----------
+func cmdPSL(ctx context.Context, a cmdArgs) int {
+	switch a.sub {
+	case "info":
+		r := psl()
+		fmt.Printf("source: %s\nrules : %d (%d wildcard, %d exception)\n", r.source, r.size(), len(r.wildcard), len(r.exception))
+		return 0
+	case "update":
+		b, err := os.ReadFile(a.args[0]); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+		r := parsePSL(string(b), a.args[0])
+		if r.size() < 1000 || !strings.Contains(string(b), "===BEGIN ICANN DOMAINS===") {
+			fmt.Fprintln(os.Stderr, "error:", errors.New("file does not look like the Public Suffix List")); return 1
+		}
+		if err := writeFileAtomic(pslPath(), b, 0o644); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+		fmt.Printf("installed %d rules to %s\n", r.size(), pslPath())
+		return 0
+	}
+	for _, arg := range a.args {
+		h, err := normalizeHost(arg); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); continue }
+		rd, err := registrableDomain(h)
+		if err != nil { fmt.Printf("%s\tsuffix:%s\t(public suffix)\n", h, publicSuffix(h)); continue }
+		fmt.Printf("%s\tsuffix:%s\tregistrable:%s\n", h, publicSuffix(h), rd)
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestRegistrableDomain runs the ASCII and IDN vectors of the Public
+// Suffix List's tests/test_psl.txt against the embedded list.
+func TestRegistrableDomain(t *testing.T) {
+	tests := []struct{ host, want string }{
+		{"com", ""},
+		{"example.com", "example.com"},
+		{"b.example.com", "example.com"},
+		{"a.b.example.com", "example.com"},
+		{"biz", ""},
+		{"domain.biz", "domain.biz"},
+		{"b.domain.biz", "domain.biz"},
+		{"a.b.domain.biz", "domain.biz"},
+		{"test.ac", "test.ac"},
+		{"b.test.ac", "test.ac"},
+		{"mm", ""},
+		{"c.mm", ""},
+		{"b.c.mm", "b.c.mm"},
+		{"a.b.c.mm", "b.c.mm"},
+		{"jp", ""},
+		{"test.jp", "test.jp"},
+		{"www.test.jp", "test.jp"},
+		{"ac.jp", ""},
+		{"test.ac.jp", "test.ac.jp"},
+		{"www.test.ac.jp", "test.ac.jp"},
+		{"kyoto.jp", ""},
+		{"test.kyoto.jp", "test.kyoto.jp"},
+		{"ide.kyoto.jp", ""},
+		{"b.ide.kyoto.jp", "b.ide.kyoto.jp"},
+		{"a.b.ide.kyoto.jp", "b.ide.kyoto.jp"},
+		{"c.kobe.jp", ""},
+		{"b.c.kobe.jp", "b.c.kobe.jp"},
+		{"a.b.c.kobe.jp", "b.c.kobe.jp"},
+		{"city.kobe.jp", "city.kobe.jp"},
+		{"www.city.kobe.jp", "city.kobe.jp"},
+		{"ck", ""},
+		{"test.ck", ""},
+		{"b.test.ck", "b.test.ck"},
+		{"a.b.test.ck", "b.test.ck"},
+		{"www.ck", "www.ck"},
+		{"www.www.ck", "www.ck"},
+		{"us", ""},
+		{"test.us", "test.us"},
+		{"www.test.us", "test.us"},
+		{"ak.us", ""},
+		{"test.ak.us", "test.ak.us"},
+		{"www.test.ak.us", "test.ak.us"},
+		{"k12.ak.us", ""},
+		{"test.k12.ak.us", "test.k12.ak.us"},
+		{"www.test.k12.ak.us", "test.k12.ak.us"},
+		// unlisted TLD: the default rule "*"
+		{"example", ""},
+		{"example.example", "example.example"},
+		{"b.example.example", "example.example"},
+		// IDN, as Unicode and as punycode
+		{"食狮.com.cn", "xn--85x722f.com.cn"},
+		{"食狮.公司.cn", "xn--85x722f.xn--55qx5d.cn"},
+		{"www.食狮.公司.cn", "xn--85x722f.xn--55qx5d.cn"},
+		{"shishi.公司.cn", "shishi.xn--55qx5d.cn"},
+		{"公司.cn", ""},
+		{"食狮.中国", "xn--85x722f.xn--fiqs8s"},
+		{"www.食狮.中国", "xn--85x722f.xn--fiqs8s"},
+		{"中国", ""},
+		{"xn--85x722f.com.cn", "xn--85x722f.com.cn"},
+		{"xn--85x722f.xn--55qx5d.cn", "xn--85x722f.xn--55qx5d.cn"},
+		{"www.xn--85x722f.xn--55qx5d.cn", "xn--85x722f.xn--55qx5d.cn"},
+		{"shishi.xn--55qx5d.cn", "shishi.xn--55qx5d.cn"},
+		{"xn--55qx5d.cn", ""},
+		{"xn--85x722f.xn--fiqs8s", "xn--85x722f.xn--fiqs8s"},
+		{"shishi.xn--fiqs8s", "shishi.xn--fiqs8s"},
+		{"xn--fiqs8s", ""},
+	}
+	for _, tt := range tests {
+		h, err := normalizeHost(tt.host); if err != nil { t.Errorf("normalizeHost(%q): %v", tt.host, err); continue }
+		got, err := registrableDomain(h)
+		if tt.want == "" {
+			if err == nil { t.Errorf("registrableDomain(%q) = %q, want a public suffix error", tt.host, got) }
+			continue
+		}
+		if err != nil || got != tt.want { t.Errorf("registrableDomain(%q) = %q, %v; want %q", tt.host, got, err, tt.want) }
+	}
+}
+
+func TestRootsFromHosts(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		roots, bad []string
+	}{
+		{"subdomains", []string{"a.example.com", "b.c.example.com", "www.example.co.uk"}, []string{"example.co.uk", "example.com"}, nil},
+		{"wildcards", []string{"*.example.com", "*.api.example.org"}, []string{"example.com", "example.org"}, nil},
+		{"urls and ports", []string{"https://app.example.com/login", "api.example.net:8443"}, []string{"example.com", "example.net"}, nil},
+		{"comments and blanks", []string{"# hosts", "", "  ", "example.com"}, []string{"example.com"}, nil},
+		{"ip literals", []string{"10.0.0.1", "https://192.168.1.1:8443/", "[2001:db8::1]:443", "example.com"}, []string{"example.com"}, []string{"10.0.0.1", "https://192.168.1.1:8443/", "[2001:db8::1]:443"}},
+		{"public suffixes", []string{"co.uk", "*.github.io", "*.com"}, nil, []string{"co.uk", "*.github.io", "*.com"}},
+		{"bad wildcards", []string{"example.*", "a.*.example.com"}, nil, []string{"example.*", "a.*.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots, bad := rootsFromHosts(tt.lines)
+			if len(roots) == 0 { roots = nil }
+			if !reflect.DeepEqual(roots, tt.roots) || !reflect.DeepEqual(bad, tt.bad) {
+				t.Errorf("rootsFromHosts(%q) = %q, %q; want %q, %q", tt.lines, roots, bad, tt.roots, tt.bad)
+			}
+		})
+	}
+}
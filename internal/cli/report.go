@@ -0,0 +1,293 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// The report templates are compiled into the binary; `report --template`
+// renders a custom file with the same data instead.
+var (
+	//go:embed templates/report.html.tmpl
+	reportHTMLTemplate string
+	//go:embed templates/report.md.tmpl
+	reportMarkdownTemplate string
+)
+
+const DefaultReportPeriod = 7 * 24 * time.Hour
+
+// takeoverServices maps CNAME suffixes of hosting services that are known to
+// allow claiming an abandoned name to the service's name.
+var takeoverServices = []struct{ suffix, name string }{
+	{".s3.amazonaws.com", "AWS S3"}, {".s3-website", "AWS S3 website"}, {".elasticbeanstalk.com", "AWS Elastic Beanstalk"},
+	{".cloudfront.net", "AWS CloudFront"}, {".azurewebsites.net", "Azure App Service"}, {".cloudapp.net", "Azure Cloud Services"},
+	{".cloudapp.azure.com", "Azure VM"}, {".trafficmanager.net", "Azure Traffic Manager"}, {".blob.core.windows.net", "Azure Blob Storage"},
+	{".azureedge.net", "Azure CDN"}, {".herokuapp.com", "Heroku"}, {".herokudns.com", "Heroku"}, {".github.io", "GitHub Pages"},
+	{".bitbucket.io", "Bitbucket"}, {".pantheonsite.io", "Pantheon"}, {".readthedocs.io", "Read the Docs"}, {".ghost.io", "Ghost"},
+	{".myshopify.com", "Shopify"}, {".surge.sh", "Surge"}, {".zendesk.com", "Zendesk"}, {".wordpress.com", "WordPress.com"},
+	{".netlify.app", "Netlify"}, {".webflow.io", "Webflow"}, {".fly.dev", "Fly.io"}, {".unbouncepages.com", "Unbounce"},
+	{".helpscoutdocs.com", "Help Scout"}, {".agilecrm.com", "Agile CRM"}, {".teamwork.com", "Teamwork"},
+}
+
+// reportData is what the templates render.
+type reportData struct {
+	Generated    time.Time
+	Since, Until time.Time
+	Domains      []domainReport
+	Totals       reportTotals
+}
+
+type reportTotals struct {
+	Domains, Hosts, New, Removed, Changed, Takeover, Runs, FailedRuns int
+}
+
+type domainReport struct {
+	Domain, Program  string
+	Hosts            int // inventory size
+	OutOfScope       int
+	New              []reportHost // first seen in the period
+	Probed           int          // new hosts with probe results
+	Removed          []string     // returned at the start of the period, not at its end
+	Changed          []hostChange // addresses or CNAME changed (needs --resolve)
+	Resolution       *resolutionSummary
+	Takeover         []takeoverCandidate
+	Runs, FailedRuns int
+	LastRun          *RunRecord
+	Anomalies        []string
+	AISummary        string
+	AISummaryAt      time.Time
+}
+
+type reportHost struct {
+	Host, Unicode string
+	FirstSeen     time.Time
+	InScope       bool
+	Probes        []hostProbe // hook output naming the host
+}
+
+// resolutionSummary is taken from the latest snapshot made with --resolve.
+type resolutionSummary struct {
+	Run                  int
+	At                   time.Time
+	Resolved, Unresolved int
+	Hosts                []resolvedHost
+}
+
+type resolvedHost struct {
+	Host  string
+	Addrs []string
+	CNAME string
+}
+
+// takeoverCandidate is a host whose CNAME target no longer resolves.
+type takeoverCandidate struct {
+	Host, CNAME, Service string
+}
+
+// buildReport gathers the report of domain for [since, until] from the
+// inventory, the new-host files, snapshots and the run history.
+func buildReport(domain string, since, until time.Time) (domainReport, error) {
+	r := domainReport{Domain: domain}
+	if reg, err := loadRegistry(); err == nil { r.Program = reg.programOf(domain) }
+	hosts, err := readLines(filepath.Join(dataDir(), domain+".txt")); if err != nil { return r, err }
+	r.Hosts = len(hosts)
+	_, oos := splitScope(domain, hosts)
+	r.OutOfScope = len(oos)
+
+	// new hosts: the per-scan *_new_<unix>.txt files inside the period
+	seen := map[string]bool{}
+	for _, b := range newHostBatches(domain) {
+		if b.Time.Before(since) || b.Time.After(until) { continue }
+		for _, h := range b.Hosts { if !seen[h] { seen[h] = true; r.New = append(r.New, reportHost{Host: h, FirstSeen: b.Time}) } }
+	}
+	var newHosts []string
+	for _, h := range r.New { newHosts = append(newHosts, h.Host) }
+	_, newOOS := splitScope(domain, newHosts)
+	isOOS := map[string]bool{}
+	for _, h := range newOOS { isOOS[h] = true }
+	for i := range r.New {
+		r.New[i].InScope = !isOOS[r.New[i].Host]
+		if u := displayHost(r.New[i].Host); u != r.New[i].Host { r.New[i].Unicode = u }
+	}
+	sort.Slice(r.New, func(i, j int) bool { return r.New[i].Host < r.New[j].Host })
+
+	// removed / changed: snapshot at the start of the period vs its end
+	refs, err := listSnapshots(domain); if err != nil { return r, err }
+	to, okTo := snapshotAt(refs, until)
+	from, okFrom := snapshotAt(refs, since)
+	if !okFrom {
+		for _, ref := range refs { if !ref.Time.Before(since) { from, okFrom = ref, true; break } }
+	}
+	if okTo && okFrom && from.Run < to.Run {
+		a, err := readSnapshot(from); if err != nil { return r, err }
+		b, err := readSnapshot(to); if err != nil { return r, err }
+		d := diffSnapshots(domain, a, b)
+		r.Removed, r.Changed = d.Removed, d.Changed
+	}
+
+	// resolution and takeover candidates: latest resolved snapshot
+	for i := len(refs) - 1; i >= 0 && r.Resolution == nil; i-- {
+		if refs[i].Time.After(until) { continue }
+		s, err := readSnapshot(refs[i]); if err != nil { return r, err }
+		res := &resolutionSummary{Run: s.Run, At: s.Time}
+		for h, addrs := range s.Hosts {
+			if addrs == nil { continue }
+			if len(addrs) > 0 { res.Resolved++ } else { res.Unresolved++ }
+			res.Hosts = append(res.Hosts, resolvedHost{Host: h, Addrs: addrs, CNAME: s.CNAMEs[h]})
+			if c := s.CNAMEs[h]; c != "" && len(addrs) == 0 {
+				r.Takeover = append(r.Takeover, takeoverCandidate{Host: h, CNAME: c, Service: takeoverService(c)})
+			}
+		}
+		if len(res.Hosts) == 0 { continue } // scanned without --resolve
+		sort.Slice(res.Hosts, func(i, j int) bool { return res.Hosts[i].Host < res.Hosts[j].Host })
+		sort.Slice(r.Takeover, func(i, j int) bool { return r.Takeover[i].Host < r.Takeover[j].Host })
+		r.Resolution = res
+	}
+
+	// runs in the period; probe results of the new hosts from the hooks of
+	// any run up to its end
+	runs, err := loadRuns(domain); if err != nil { return r, err }
+	n := len(runs)
+	for n > 0 && runs[n-1].Start.After(until) { n-- }
+	probes := hostProbes(runs[:n], newHosts)
+	for i := range r.New {
+		if r.New[i].Probes = probes[r.New[i].Host]; r.New[i].Probes != nil { r.Probed++ }
+	}
+	anomalies := map[string]bool{}
+	for i := range runs {
+		run := runs[i]
+		if run.Start.Before(since) || run.Start.After(until) { continue }
+		r.Runs++
+		if run.Status != "ok" { r.FailedRuns++ }
+		r.LastRun = &runs[i]
+		for _, a := range run.Anomalies { if !anomalies[a] { anomalies[a] = true; r.Anomalies = append(r.Anomalies, a) } }
+		if run.AISummary != "" { r.AISummary, r.AISummaryAt = run.AISummary, run.Start }
+	}
+	return r, nil
+}
+
+// newHostBatch is one data/<domain>_new_<unix>.txt file: the hosts a scan
+// added to the inventory.
+type newHostBatch struct {
+	Time  time.Time
+	Hosts []string
+}
+
+// newHostBatches returns the new-host files of domain, oldest first.
+func newHostBatches(domain string) []newHostBatch {
+	var out []newHostBatch
+	entries, _ := os.ReadDir(dataDir())
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, domain+"_new_") || !strings.HasSuffix(name, ".txt") { continue }
+		ts, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, domain+"_new_"), ".txt"), 10, 64)
+		if err != nil { continue }
+		hosts, _ := readLines(filepath.Join(dataDir(), name))
+		out = append(out, newHostBatch{time.Unix(ts, 0), hosts})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out
+}
+
+func takeoverService(cname string) string {
+	for _, s := range takeoverServices { if strings.Contains(cname+".", s.suffix) { return s.name } }
+	return ""
+}
+
+// parseSince accepts a duration back from now (72h, 7d) or a date.
+func parseSince(v string, now time.Time) (time.Time, error) {
+	if n, err := strconv.Atoi(strings.TrimSuffix(v, "d")); err == nil && strings.HasSuffix(v, "d") && n >= 0 {
+		return now.AddDate(0, 0, -n), nil
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 { return now.Add(-d), nil }
+	if t, _, err := parseDate(v); err == nil { return t, nil }
+	return time.Time{}, fmt.Errorf("%q is not a duration (7d, 48h) or date (YYYY-MM-DD)", v)
+}
+
+var reportFuncs = map[string]any{
+	"date":  func(t time.Time) string { if t.IsZero() { return "-" }; return t.Local().Format("2006-01-02 15:04") },
+	"day":   func(t time.Time) string { return t.Local().Format("2006-01-02") },
+	"join":  strings.Join,
+	"lines": func(s string) []string { return strings.Split(strings.TrimSpace(s), "\n") },
+	"mdesc": func(s string) string { return strings.NewReplacer("|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`").Replace(s) },
+}
+
+// renderReport executes the embedded (or a custom) template for format.
+func renderReport(w io.Writer, format, custom string, data reportData) error {
+	src := reportHTMLTemplate
+	if format == "md" { src = reportMarkdownTemplate }
+	if custom != "" {
+		b, err := os.ReadFile(custom); if err != nil { return err }
+		src = string(b)
+	}
+	var buf bytes.Buffer
+	if format == "html" {
+		t, err := htmltemplate.New("report").Funcs(reportFuncs).Parse(src); if err != nil { return err }
+		if err := t.Execute(&buf, data); err != nil { return err }
+	} else {
+		t, err := texttemplate.New("report").Funcs(reportFuncs).Parse(src); if err != nil { return err }
+		if err := t.Execute(&buf, data); err != nil { return err }
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func cmdReport(ctx context.Context, a cmdArgs) int {
+	now := time.Now()
+	since, until := now.Add(-DefaultReportPeriod), now
+	var err error
+	if v := a.str("since"); v != "" {
+		if since, err = parseSince(v, now); err != nil { return a.usage(err.Error()) }
+	}
+	if v := a.str("until"); v != "" {
+		t, dayOnly, err := parseDate(v); if err != nil { return a.usage(err.Error()) }
+		if dayOnly { t = t.AddDate(0, 0, 1).Add(-time.Second) }
+		until = t
+	}
+	out, format := a.str("out"), a.str("format")
+	if format == "" {
+		format = "html"
+		if strings.HasSuffix(out, ".md") { format = "md" }
+	}
+	if format == "markdown" { format = "md" }
+	if format != "html" && format != "md" { return a.usage("--format must be html or md") }
+
+	var domains []string
+	if prog, tag := a.str("program"), a.str("tag"); a.bool("all") || prog != "" || tag != "" {
+		if len(a.args) > 0 { return a.usage("give either a domain or --all/--program/--tag") }
+		if domains, err = selectDomains(prog, tag); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	} else if len(a.args) == 1 {
+		d, err := domainArg(a, 0); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 2 }
+		domains = []string{d}
+	}
+	if len(domains) == 0 { return a.usage("give a domain, or --all/--program/--tag matching some domains") }
+
+	data := reportData{Generated: now, Since: since, Until: until}
+	for _, d := range uniqueSorted(domains) {
+		r, err := buildReport(d, since, until); if err != nil { fmt.Fprintln(os.Stderr, "error:", d+":", err); return 1 }
+		data.Domains = append(data.Domains, r)
+		t := &data.Totals
+		t.Domains++; t.Hosts += r.Hosts; t.New += len(r.New); t.Removed += len(r.Removed); t.Changed += len(r.Changed)
+		t.Takeover += len(r.Takeover); t.Runs += r.Runs; t.FailedRuns += r.FailedRuns
+	}
+	w := io.Writer(os.Stdout)
+	var buf bytes.Buffer
+	if out != "" { w = &buf }
+	if err := renderReport(w, format, a.str("template"), data); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	if out != "" {
+		if err := writeFileAtomic(out, buf.Bytes(), 0o644); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+		fmt.Println("wrote", out)
+	}
+	return 0
+}
@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunsRelDir holds one append-only JSON-lines file of run records per domain.
+const RunsRelDir = "runs"
+
+const maxRunError = 2000
+
+func runsDir() string              { return filepath.Join(homeDir(), RunsRelDir) }
+func runsPath(domain string) string { return filepath.Join(runsDir(), domain+".jsonl") }
+
+// RunRecord describes one scan of one domain, successful or not.
+type RunRecord struct {
+	Seq      int            `json:"seq"` // 1, 2, ... per domain
+	Domain   string         `json:"domain"`
+	Trigger  string         `json:"trigger,omitempty"` // scan, daemon
+	Start    time.Time      `json:"start"`
+	End      time.Time      `json:"end"`
+	Duration float64        `json:"duration_s"`
+	Status   string         `json:"status"` // ok, failed, timeout, interrupted
+	Error    string         `json:"error,omitempty"`
+	ExitCode int            `json:"exit_code,omitempty"` // of a failed subfinder
+	Sources  map[string]int `json:"sources,omitempty"`   // hosts returned per passive source
+	Returned int            `json:"returned"`            // valid hosts returned by enumeration
+	Dropped  int            `json:"dropped,omitempty"`
+	Added    int            `json:"added"`
+	// Removed counts inventory hosts this run did not return; the
+	// inventory itself keeps them.
+	Removed    int          `json:"removed"`
+	OutOfScope int          `json:"out_of_scope,omitempty"` // of the added hosts
+	Total      int          `json:"total"`
+	Anomalies  []string     `json:"anomalies,omitempty"` // see detectAnomalies
+	AISummary  string       `json:"ai_summary,omitempty"`
+	Hooks      []HookResult `json:"hooks,omitempty"` // see runHooks
+}
+
+// runStatus classifies the error of a scan: ok, failed, timeout,
+// interrupted, or locked (another run was scanning the domain).
+func runStatus(err error) string {
+	var busy *lockBusyError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.As(err, &busy):
+		return "locked"
+	case errors.Is(err, context.Canceled):
+		return "interrupted"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "failed"
+}
+
+// finish fills in the outcome of the run from the error scanOne returns.
+func (r *RunRecord) finish(ctx context.Context, err error) {
+	r.End = time.Now()
+	r.Duration = r.End.Sub(r.Start).Round(time.Millisecond).Seconds()
+	r.Status = runStatus(err)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) { r.Status = "interrupted" }
+	if err != nil {
+		// keep subfinder's stderr, which usually names the failing source
+		r.Error = strings.TrimSpace(err.Error())
+		if len(r.Error) > maxRunError { r.Error = r.Error[:maxRunError] + "..." }
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) { r.ExitCode = ee.ExitCode() }
+}
+
+// nextRunSeq returns the number the next run of domain gets. Callers hold
+// the domain lock.
+func nextRunSeq(domain string) (int, error) {
+	prev, err := loadRuns(domain); if err != nil { return 0, err }
+	if len(prev) == 0 { return 1, nil }
+	return prev[len(prev)-1].Seq + 1, nil
+}
+
+// appendRun appends r to the domain's run log, numbering it if needed.
+// Callers hold the domain lock.
+func appendRun(r *RunRecord) error {
+	if r.Seq == 0 {
+		seq, err := nextRunSeq(r.Domain); if err != nil { return err }
+		r.Seq = seq
+	}
+	if err := os.MkdirAll(runsDir(), 0o755); err != nil { return err }
+	f, err := os.OpenFile(runsPath(r.Domain), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); if err != nil { return err }
+	b, _ := json.Marshal(r)
+	_, err = f.Write(append(b, '\n'))
+	if cerr := f.Close(); err == nil { err = cerr }
+	return err
+}
+
+// loadRuns returns the run log of domain, oldest first. Lines that do not
+// parse (e.g. cut short by a crash) are skipped.
+func loadRuns(domain string) ([]RunRecord, error) {
+	f, err := os.Open(runsPath(domain))
+	if os.IsNotExist(err) { return nil, nil }
+	if err != nil { return nil, err }
+	defer f.Close()
+	var out []RunRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var r RunRecord
+		if json.Unmarshal(sc.Bytes(), &r) == nil && r.Seq > 0 { out = append(out, r) }
+	}
+	return out, sc.Err()
+}
+
+// ---------- command ----------
+func cmdHistory(ctx context.Context, a cmdArgs) int {
+	domain, err := domainArg(a, 0); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 2 }
+	format, err := outputFlag(a); if err != nil { return a.usage(err.Error()) }
+	runs, err := loadRuns(domain); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	if a.bool("reset-baseline") {
+		if !monitored(domain) { fmt.Fprintf(os.Stderr, "error: %s is not monitored\n", domain); return 1 }
+		from := 1
+		if len(runs) > 0 { from = runs[len(runs)-1].Seq + 1 }
+		if err := updateState(func(st *SchedState) { st.domain(domain).BaselineFrom = from }); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+		fmt.Printf("anomaly baseline of %s reset: runs from #%d on form the new one (alerts resume after %d)\n", domain, from, anomalyMinRuns)
+		return 0
+	}
+	if len(runs) == 0 && format == formatText { fmt.Println("no recorded runs for", domain); return 0 }
+
+	if a.isSet("run") {
+		seq := a.int("run")
+		for _, r := range runs {
+			if r.Seq != seq { continue }
+			switch format {
+			case formatText:
+				printRun(r)
+			case formatJSON:
+				return exitWrite(writeJSON(os.Stdout, r))
+			default:
+				return writeRunRecords(format, []RunRecord{r})
+			}
+			return 0
+		}
+		fmt.Printf("no run #%d for %s\n", seq, domain); return 1
+	}
+	limit := a.int("limit")
+	if limit < 1 { return a.usage("--limit must be a positive integer") }
+	if a.bool("failed") {
+		var failed []RunRecord
+		for _, r := range runs { if r.Status != "ok" { failed = append(failed, r) } }
+		runs = failed
+	}
+	if len(runs) > limit { runs = runs[len(runs)-limit:] }
+	if format != formatText { return writeRunRecords(format, runs) }
+	fmt.Printf("%5s  %-16s %9s  %-11s %8s %6s %7s %7s %7s\n", "RUN", "STARTED", "DURATION", "STATUS", "RETURNED", "NEW", "REMOVED", "TOTAL", "SOURCES")
+	for i := len(runs) - 1; i >= 0; i-- {
+		r := runs[i]
+		status := r.Status
+		// an ok run that returned nothing is what a dead API key looks like
+		switch {
+		case status == "ok" && len(r.Anomalies) > 0:
+			status = "anomaly"
+		case status == "ok" && r.Returned == 0:
+			status = "ok (empty)"
+		}
+		fmt.Printf("%5d  %-16s %9s  %-11s %8d %6d %7d %7d %7d\n", r.Seq, r.Start.Local().Format("2006-01-02 15:04"),
+			(time.Duration(r.Duration * float64(time.Second))).Round(time.Second), status, r.Returned, r.Added, r.Removed, r.Total, len(r.Sources))
+		if r.Error != "" { fmt.Printf("%5s  %s\n", "", firstLine(r.Error)) }
+		for _, a := range r.Anomalies { fmt.Printf("%5s  %s\n", "", a) }
+	}
+	return 0
+}
+
+func printRun(r RunRecord) {
+	fmt.Printf("run      : #%d of %s (%s)\n", r.Seq, r.Domain, r.Trigger)
+	fmt.Printf("started  : %s\n", r.Start.Local().Format(time.RFC3339))
+	fmt.Printf("duration : %s\n", time.Duration(r.Duration*float64(time.Second)).Round(time.Millisecond))
+	fmt.Printf("status   : %s\n", r.Status)
+	if r.Error != "" { fmt.Printf("error    : %s\n", strings.ReplaceAll(r.Error, "\n", "\n           ")) }
+	if r.ExitCode != 0 { fmt.Printf("exit code: %d\n", r.ExitCode) }
+	for _, a := range r.Anomalies { fmt.Printf("anomaly  : %s\n", a) }
+	fmt.Printf("hosts    : returned %d, dropped %d, new %d (%d out of scope), not returned %d, inventory %d\n",
+		r.Returned, r.Dropped, r.Added, r.OutOfScope, r.Removed, r.Total)
+	for _, h := range r.Hooks {
+		fmt.Printf("hook     : %s %s (%s)\n", h.Name, h.Status, time.Duration(h.Duration*float64(time.Second)).Round(time.Millisecond))
+		if h.Error != "" { fmt.Printf("%11s%s\n", "", h.Error) }
+		if o := strings.TrimRight(h.Output, "\n"); o != "" { fmt.Printf("%11s%s\n", "", strings.ReplaceAll(o, "\n", "\n           ")) }
+	}
+	if len(r.Sources) == 0 { return }
+	names := make([]string, 0, len(r.Sources))
+	for s := range r.Sources { names = append(names, s) }
+	sort.Slice(names, func(i, j int) bool {
+		if r.Sources[names[i]] != r.Sources[names[j]] { return r.Sources[names[i]] > r.Sources[names[j]] }
+		return names[i] < names[j]
+	})
+	fmt.Println("sources  :")
+	for _, s := range names { fmt.Printf("  %-20s %d\n", s, r.Sources[s]) }
+}
+
+// writeRunRecords prints runs oldest first; in CSV sources are "name=count"
+// pairs and anomalies are separated by "; ".
+func writeRunRecords(f outputFormat, runs []RunRecord) int {
+	cols := []string{"seq", "domain", "trigger", "start", "end", "duration_s", "status", "exit_code", "returned", "dropped", "added", "removed", "out_of_scope", "total", "sources", "anomalies", "error"}
+	return exitWrite(writeRecords(os.Stdout, f, runs, cols, func(r RunRecord) []string {
+		var src []string
+		for s, n := range r.Sources { src = append(src, s+"="+strconv.Itoa(n)) }
+		sort.Strings(src)
+		return []string{strconv.Itoa(r.Seq), r.Domain, r.Trigger, csvTime(r.Start), csvTime(r.End), csvFloat(r.Duration), r.Status, strconv.Itoa(r.ExitCode),
+			strconv.Itoa(r.Returned), strconv.Itoa(r.Dropped), strconv.Itoa(r.Added), strconv.Itoa(r.Removed), strconv.Itoa(r.OutOfScope), strconv.Itoa(r.Total),
+			csvList(src), strings.Join(r.Anomalies, "; "), r.Error}
+	}))
+}
@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// storeMu serializes read-modify-write of inventory files across workers.
+var storeMu sync.Mutex
+
+const (
+	// ExitPartial is returned when some, but not all, domains of a scan failed.
+	ExitPartial = 3
+	// ExitInterrupted is returned after a clean shutdown on SIGINT/SIGTERM.
+	ExitInterrupted = 130
+
+	DefaultDomainTimeout = time.Hour
+)
+
+type scanOptions struct {
+	WithAI   bool
+	Workers  int
+	FailFast bool
+	Timeout  time.Duration // per domain enumeration, 0 = none
+	LockWait time.Duration // for a domain another run is scanning, see lockWait
+	Trigger  string        // recorded in the run history: scan, daemon
+	Resolve  bool          // store the addresses of returned hosts in the snapshot
+	Quiet    bool          // no per-domain text on stdout (structured output)
+	// OnDone, if set, is called once per finished domain (serialized).
+	OnDone func(domain string, r scanResult, err error)
+	// OnEvent, if set, gets the domain's events once they are logged.
+	OnEvent func(Event)
+}
+
+// scanSummary aggregates the results of a multi-domain scan.
+type scanSummary struct {
+	Domains, Failed, Total, New, OutOfScope int
+	Anomalous                               int // domains with collapsed results
+	Failures                                []scanFailure
+	Skipped                                 []string // never started (ctx done or FailFast)
+}
+
+// errFailFast is the error of the domains FailFast kept from starting.
+var errFailFast = errors.New("stopped after a failure (--fail-fast)")
+
+type scanFailure struct {
+	Domain string
+	Err    error
+}
+
+// scanMany runs scanOne over domains with a bounded worker pool. With one
+// worker output is streamed as before; with more, each domain's output is
+// buffered and printed as a single block with a [i/n] progress prefix.
+// Failures are collected and the remaining domains still run, unless
+// FailFast is set. Once ctx is done, or FailFast stopped the scan, no new
+// domains are started; those are recorded as skipped.
+func scanMany(ctx context.Context, domains []string, opts scanOptions) scanSummary {
+	var sum scanSummary
+	stdout := io.Writer(os.Stdout)
+	if opts.Quiet { stdout = io.Discard }
+	workers := opts.Workers
+	if workers > len(domains) { workers = len(domains) }
+	if workers <= 1 {
+		for i, d := range domains {
+			if ctx.Err() != nil { sum.skip(domains[i:], ctx.Err()); break }
+			r, err := scanOne(ctx, d, opts, stdout, os.Stderr)
+			sum.add(d, r, err)
+			if opts.OnDone != nil { opts.OnDone(d, r, err) }
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s: %v\n", d, err)
+				if opts.FailFast { sum.skip(domains[i+1:], errFailFast); break }
+			}
+		}
+		sum.print(stdout, len(domains))
+		return sum
+	}
+
+	var (
+		mu      sync.Mutex // guards sum, done, skipped and the terminal
+		done    int
+		skipped []string
+		wg      sync.WaitGroup
+	)
+	stop := make(chan struct{}) // closed on the first failure with FailFast
+	stopped := func() bool {
+		select {
+		case <-stop:
+			return true
+		case <-ctx.Done():
+			return true
+		default:
+			return false
+		}
+	}
+	jobs := make(chan string)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range jobs {
+				// a job handed over just as the pool stopped is not started
+				if stopped() { mu.Lock(); skipped = append(skipped, d); mu.Unlock(); continue }
+				var out, errw bytes.Buffer
+				r, err := scanOne(ctx, d, opts, &out, &errw)
+				mu.Lock()
+				done++
+				sum.add(d, r, err)
+				if opts.OnDone != nil { opts.OnDone(d, r, err) }
+				if err != nil {
+					if opts.FailFast {
+						select {
+						case <-stop:
+						default:
+							close(stop)
+						}
+					}
+					fmt.Fprintf(&errw, "error: %s: %v\n", d, err)
+				}
+				fmt.Fprintf(stdout, "[%d/%d] %s\n", done, len(domains), d)
+				io.Copy(stdout, &out)
+				io.Copy(os.Stderr, &errw)
+				mu.Unlock()
+			}
+		}()
+	}
+	sent := 0
+dispatch:
+	for _, d := range domains {
+		// a worker being ready must not win over the stop conditions
+		if stopped() { break }
+		select {
+		case jobs <- d:
+			sent++
+		case <-stop:
+			break dispatch
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	switch {
+	case ctx.Err() != nil:
+		sum.skip(append(skipped, domains[sent:]...), ctx.Err())
+	case stopped():
+		sum.skip(append(skipped, domains[sent:]...), errFailFast)
+	}
+	sum.print(stdout, len(domains))
+	return sum
+}
+
+func (s *scanSummary) add(domain string, r scanResult, err error) {
+	s.Domains++
+	if err != nil { s.Failed++; s.Failures = append(s.Failures, scanFailure{domain, err}); return }
+	s.Total += r.Total; s.New += r.New; s.OutOfScope += r.OutOfScope
+	if len(r.Anomalies) > 0 { s.Anomalous++ }
+}
+
+// skip records domains that were never started, because ctx ended or
+// FailFast stopped the scan.
+func (s *scanSummary) skip(domains []string, err error) {
+	for _, d := range domains { s.add(d, scanResult{}, fmt.Errorf("not started: %w", err)) }
+	s.Skipped = append(s.Skipped, domains...)
+}
+
+// print writes the totals line (multi-domain runs only) and the failure list.
+func (s *scanSummary) print(w io.Writer, requested int) {
+	if requested > 1 {
+		fmt.Fprintf(w, "Scanned %d/%d domain(s) -> total:%d new:%d out-of-scope:%d failed:%d\n", s.Domains-s.Failed, requested, s.Total, s.New, s.OutOfScope, s.Failed)
+	}
+	if len(s.Failures) == 0 || requested == 1 { return }
+	sort.Slice(s.Failures, func(i, j int) bool { return s.Failures[i].Domain < s.Failures[j].Domain })
+	fmt.Fprintf(os.Stderr, "Failed targets (%d):\n", len(s.Failures))
+	for _, f := range s.Failures { fmt.Fprintf(os.Stderr, "  %s: %s\n", f.Domain, firstLine(f.Err.Error())) }
+}
+
+// exitCode maps a summary to 0 (ok), 1 (everything failed) or ExitPartial.
+// Skipped domains did not fail, they did not run: a scan that skipped some
+// is partial.
+func (s *scanSummary) exitCode() int {
+	switch {
+	case s.Failed == 0:
+		return 0
+	case s.Failed >= s.Domains && len(s.Skipped) == 0:
+		return 1
+	}
+	return ExitPartial
+}
+
+// notifyFailures sends the failed-target summary to the configured channels.
+func notifyFailures(ctx context.Context, s scanSummary, requested int) {
+	if len(s.Failures) == 0 { return }
+	title := fmt.Sprintf("⚠️ DomWatch scan failures: %d of %d target(s) — %s", len(s.Failures), requested, time.Now().Format(time.RFC3339))
+	var lines []string
+	for _, f := range s.Failures { lines = append(lines, "- `"+f.Domain+"`: "+firstLine(f.Err.Error())) }
+	notify(ctx, os.Stderr, title, lines)
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 { s = s[:i] }
+	return s
+}
+
+// ---------- structured output ----------
+
+// ScanRecord is the machine-readable result of scanning one domain.
+type ScanRecord struct {
+	Domain        string     `json:"domain"`
+	Status        string     `json:"status"` // ok, failed, timeout, interrupted, locked, skipped
+	Run           int        `json:"run,omitempty"`
+	Started       *time.Time `json:"started,omitempty"`
+	Duration      float64    `json:"duration_s"`
+	Total         int        `json:"total"`
+	New           []string   `json:"new"`
+	NewOutOfScope []string   `json:"new_out_of_scope"`
+	Anomalies     []string   `json:"anomalies,omitempty"`
+	AISummary     string     `json:"ai_summary,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+func newScanRecord(domain string, r scanResult, err error) ScanRecord {
+	rec := ScanRecord{Domain: domain, Status: runStatus(err), Run: r.Run, Started: optTime(r.Started), Total: r.Total,
+		New: r.NewHosts, NewOutOfScope: r.OutOfScopeHosts, Anomalies: r.Anomalies, AISummary: r.AISummary}
+	if rec.New == nil { rec.New = []string{} }
+	if rec.NewOutOfScope == nil { rec.NewOutOfScope = []string{} }
+	if !r.Started.IsZero() { rec.Duration = time.Since(r.Started).Round(time.Millisecond).Seconds() }
+	if err != nil { rec.Error = firstLine(err.Error()) }
+	return rec
+}
+
+func skippedRecords(s scanSummary) []ScanRecord {
+	var out []ScanRecord
+	for _, d := range s.Skipped { out = append(out, ScanRecord{Domain: d, Status: "skipped", New: []string{}, NewOutOfScope: []string{}}) }
+	return out
+}
+
+func writeScanRecords(f outputFormat, recs []ScanRecord) {
+	cols := []string{"domain", "status", "run", "started", "duration_s", "total", "new", "new_out_of_scope", "error"}
+	err := writeRecords(os.Stdout, f, recs, cols, func(r ScanRecord) []string {
+		return []string{r.Domain, r.Status, strconv.Itoa(r.Run), csvOptTime(r.Started), csvFloat(r.Duration), strconv.Itoa(r.Total), csvList(r.New), csvList(r.NewOutOfScope), r.Error}
+	})
+	if err != nil { fmt.Fprintln(os.Stderr, "error:", err) }
+}
@@ -0,0 +1,104 @@
+//go:build unix
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// slowSubfinder installs a fake subfinder: domains starting with "fail"
+// fail at once, the others answer after a while.
+func slowSubfinder(t *testing.T) {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "subfinder")
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do [ "$1" = "-d" ] && d=$2; shift; done
+case $d in fail*) echo boom >&2; exit 1;; esac
+sleep 0.3
+echo "www.$d"
+`
+	if err := os.WriteFile(p, []byte(script), 0o755); err != nil { t.Fatal(err) }
+	t.Setenv("SUBFINDER_PATH", p)
+}
+
+// startedScans runs scanMany and returns the domains it started a scan of.
+func startedScans(t *testing.T, ctx context.Context, domains []string, opts scanOptions, onStart func(n int)) ([]string, scanSummary) {
+	t.Helper()
+	for _, d := range domains {
+		if _, err := addDomain(d); err != nil { t.Fatal(err) }
+	}
+	var mu sync.Mutex
+	var started []string
+	opts.Quiet = true
+	opts.OnEvent = func(ev Event) {
+		if ev.Type != EventScanStarted { return }
+		mu.Lock()
+		started = append(started, ev.Domain)
+		n := len(started)
+		mu.Unlock()
+		if onStart != nil { onStart(n) }
+	}
+	sum := scanMany(ctx, domains, opts)
+	sort.Strings(started)
+	return started, sum
+}
+
+func TestScanManyCancel(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	slowSubfinder(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	domains := []string{"a.com", "b.com", "c.com", "d.com", "e.com", "f.com"}
+	// cancel as soon as both workers are busy: the dispatcher is waiting to hand out c.com
+	started, sum := startedScans(t, ctx, domains, scanOptions{Workers: 2}, func(n int) { if n == 2 { cancel() } })
+	if len(started) != 2 { t.Errorf("started %v after the cancel, want only the first 2", started) }
+	if len(sum.Skipped) != 4 || sum.Domains != 6 { t.Errorf("skipped %v of %d domains, want 4 of 6", sum.Skipped, sum.Domains) }
+}
+
+func TestScanManyFailFast(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	slowSubfinder(t)
+	domains := []string{"fail.com", "slow.com", "c.com", "d.com"}
+	started, sum := startedScans(t, context.Background(), domains, scanOptions{Workers: 2, FailFast: true}, nil)
+	if len(started) != 2 || started[0] != "fail.com" || started[1] != "slow.com" { t.Errorf("started %v, want [fail.com slow.com]", started) }
+	sort.Strings(sum.Skipped)
+	if sum.Domains != 4 || sum.Failed != 3 || !reflect.DeepEqual(sum.Skipped, []string{"c.com", "d.com"}) { t.Errorf("summary %+v", sum) }
+	if c := sum.exitCode(); c != ExitPartial { t.Errorf("exit code %d, want %d", c, ExitPartial) }
+	var b bytes.Buffer
+	sum.print(&b, len(domains))
+	if want := "Scanned 1/4 domain(s) -> total:1 new:1 out-of-scope:0 failed:3\n"; b.String() != want { t.Errorf("printed %q, want %q", b.String(), want) }
+	recs := skippedRecords(sum)
+	if len(recs) != 2 || recs[0].Status != "skipped" { t.Errorf("skipped records %+v", recs) }
+}
+
+func TestScanManyFailFastOneWorker(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	slowSubfinder(t)
+	domains := []string{"a.com", "fail.com", "c.com", "d.com"}
+	started, sum := startedScans(t, context.Background(), domains, scanOptions{Workers: 1, FailFast: true}, nil)
+	if len(started) != 2 { t.Errorf("started %v, want [a.com fail.com]", started) }
+	if sum.Domains != 4 || sum.Failed != 3 || !reflect.DeepEqual(sum.Skipped, []string{"c.com", "d.com"}) { t.Errorf("summary %+v", sum) }
+	if c := sum.exitCode(); c != ExitPartial { t.Errorf("exit code %d, want %d", c, ExitPartial) }
+
+	// the first domain failing is still partial: the others never ran
+	_, sum = startedScans(t, context.Background(), []string{"fail.com", "e.com"}, scanOptions{Workers: 1, FailFast: true}, nil)
+	if c := sum.exitCode(); c != ExitPartial || !reflect.DeepEqual(sum.Skipped, []string{"e.com"}) { t.Errorf("exit code %d, skipped %v", c, sum.Skipped) }
+	_, sum = startedScans(t, context.Background(), []string{"fail.com", "fail2.com"}, scanOptions{Workers: 1}, nil)
+	if c := sum.exitCode(); c != 1 { t.Errorf("all failed: exit code %d, want 1", c) }
+}
+
+func TestScanManyWorkers(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	slowSubfinder(t)
+	domains := []string{"a.com", "fail.com", "b.com", "c.com", "d.com"}
+	started, sum := startedScans(t, context.Background(), domains, scanOptions{Workers: 3}, nil)
+	if len(started) != len(domains) { t.Errorf("started %v", started) }
+	if sum.Domains != 5 || sum.Failed != 1 || sum.Total != 4 || sum.exitCode() != ExitPartial { t.Errorf("summary %+v", sum) }
+}
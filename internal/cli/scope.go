@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Scope holds the include/exclude rules for one monitored root domain.
+// Rules may be exact hosts, wildcards (*.example.com), regexes (re:<expr> or
+// /<expr>/), IPs or CIDRs. An empty include list means "everything under the
+// root"; exclusions always win.
+type Scope struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+const ScopesRelPath = "scopes.json"
+
+func scopesPath() string { return filepath.Join(homeDir(), ScopesRelPath) }
+
+func loadScopes() (map[string]*Scope, error) {
+	m := map[string]*Scope{}
+	b, err := os.ReadFile(scopesPath())
+	if err != nil {
+		if os.IsNotExist(err) { return m, nil }
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &m); err != nil { return nil, fmt.Errorf("%s: %w", scopesPath(), err) }
+	return m, nil
+}
+func saveScopes(m map[string]*Scope) error {
+	for d, s := range m { if s == nil || (len(s.Include) == 0 && len(s.Exclude) == 0) { delete(m, d) } }
+	b, _ := json.MarshalIndent(m, "", "  ")
+	return writeFileAtomic(scopesPath(), b, 0o644)
+}
+// scopeFor returns the rules of domain, with the exclusions of its program.
+func scopeFor(domain string) *Scope {
+	m, err := loadScopes()
+	if err != nil { fmt.Fprintln(os.Stderr, "scope error:", err); return nil }
+	s := m[domain]
+	if ex := programExclude(domain); len(ex) > 0 {
+		c := &Scope{}
+		if s != nil { c.Include = s.Include; c.Exclude = append(c.Exclude, s.Exclude...) }
+		c.Exclude = append(c.Exclude, ex...)
+		s = c
+	}
+	return s
+}
+
+// programExclude is the program-wide exclusions of domain's program.
+func programExclude(domain string) []string {
+	reg, err := loadRegistry(); if err != nil { return nil }
+	if p := reg.Programs[reg.programOf(domain)]; p != nil { return p.Exclude }
+	return nil
+}
+
+// mergeScope adds the rules of add to old.
+func mergeScope(old, add *Scope) *Scope {
+	if old == nil { return add }
+	return &Scope{Include: uniqueSorted(append(append([]string{}, old.Include...), add.Include...)), Exclude: uniqueSorted(append(append([]string{}, old.Exclude...), add.Exclude...))}
+}
+
+// ---------- rules ----------
+type scopeRule struct {
+	raw  string
+	re   *regexp.Regexp
+	cidr *net.IPNet
+}
+
+func parseScopeRule(s string) (scopeRule, error) {
+	s = strings.TrimSpace(s)
+	r := scopeRule{raw: s}
+	switch {
+	case s == "":
+		return r, fmt.Errorf("empty rule")
+	case strings.HasPrefix(s, "re:") || (len(s) > 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/")):
+		expr := strings.TrimPrefix(s, "re:")
+		if !strings.HasPrefix(s, "re:") { expr = s[1 : len(s)-1] }
+		re, err := regexp.Compile("(?i)" + expr); if err != nil { return r, fmt.Errorf("bad regex %q: %w", s, err) }
+		r.re = re
+	case strings.Contains(s, "/"):
+		_, n, err := net.ParseCIDR(s); if err != nil { return r, fmt.Errorf("bad CIDR %q: %w", s, err) }
+		r.cidr = n
+	case net.ParseIP(s) != nil:
+		ip := net.ParseIP(s)
+		bits := 128; if ip.To4() != nil { ip = ip.To4(); bits = 32 }
+		r.cidr = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	default:
+		h := strings.ToLower(strings.TrimSuffix(s, "."))
+		r.re = regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(h), `\*`, `.*`) + "$")
+	}
+	return r, nil
+}
+
+// scopeMatcher is a compiled Scope. IP/CIDR rules match IP literals and
+// the addresses in addrs; the matcher itself never touches DNS.
+type scopeMatcher struct {
+	include, exclude []scopeRule
+	addrs            map[string][]string // known addresses of hosts
+}
+
+func compileScope(s *Scope) (*scopeMatcher, error) {
+	m := &scopeMatcher{addrs: map[string][]string{}}
+	if s == nil { return m, nil }
+	for _, raw := range s.Include {
+		r, err := parseScopeRule(raw); if err != nil { return nil, err }
+		m.include = append(m.include, r)
+	}
+	for _, raw := range s.Exclude {
+		r, err := parseScopeRule(raw); if err != nil { return nil, err }
+		m.exclude = append(m.exclude, r)
+	}
+	return m, nil
+}
+func (m *scopeMatcher) empty() bool { return len(m.include) == 0 && len(m.exclude) == 0 }
+
+// hasIPRules reports whether addresses matter to the matcher.
+func (m *scopeMatcher) hasIPRules() bool {
+	for _, r := range m.include { if r.cidr != nil { return true } }
+	for _, r := range m.exclude { if r.cidr != nil { return true } }
+	return false
+}
+
+// addAddrs adds the addresses of hosts, e.g. from --resolve records.
+func (m *scopeMatcher) addAddrs(addrs map[string][]string) {
+	for h, a := range addrs { if len(a) > 0 { m.addrs[h] = a } }
+}
+
+func (m *scopeMatcher) hostIPs(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil { return []net.IP{ip} }
+	var ips []net.IP
+	for _, a := range m.addrs[host] { if ip := net.ParseIP(a); ip != nil { ips = append(ips, ip) } }
+	return ips
+}
+func (m *scopeMatcher) match(r scopeRule, host string) bool {
+	if r.re != nil { return r.re.MatchString(host) }
+	for _, ip := range m.hostIPs(host) { if r.cidr.Contains(ip) { return true } }
+	return false
+}
+
+// check reports whether host is in scope, and the rule that decided it.
+func (m *scopeMatcher) check(host string) (bool, string) {
+	host = strings.ToLower(host)
+	for _, r := range m.exclude { if m.match(r, host) { return false, "excluded by " + r.raw } }
+	if len(m.include) == 0 { return true, "" }
+	for _, r := range m.include { if m.match(r, host) { return true, "included by " + r.raw } }
+	return false, "no include rule matched"
+}
+
+// domainMatcher compiles the scope of domain. With IP/CIDR rules it loads
+// the addresses stored by the latest --resolve scan.
+func domainMatcher(domain string) (*scopeMatcher, error) {
+	m, err := compileScope(scopeFor(domain)); if err != nil { return nil, err }
+	if m.hasIPRules() {
+		if s := latestSnapshot(domain); s != nil { m.addAddrs(s.Hosts) }
+	}
+	return m, nil
+}
+
+// splitScope partitions hosts into in-scope and out-of-scope for domain.
+// Hosts are matched against IP/CIDR rules by their stored addresses only.
+func splitScope(domain string, hosts []string) (in, out []string) { return splitScopeAddrs(domain, hosts, nil) }
+
+// splitScopeAddrs is splitScope with fresher addresses for some hosts.
+func splitScopeAddrs(domain string, hosts []string, addrs map[string][]string) (in, out []string) {
+	m, err := domainMatcher(domain)
+	if err != nil { fmt.Fprintln(os.Stderr, "scope error:", err); return hosts, nil }
+	if m.empty() { return hosts, nil }
+	m.addAddrs(addrs)
+	for _, h := range hosts {
+		if ok, _ := m.check(h); ok { in = append(in, h) } else { out = append(out, h) }
+	}
+	return
+}
+
+// resolveForScope looks up the hosts an IP/CIDR rule of domain would need
+// and that have no address in records yet.
+func resolveForScope(ctx context.Context, domain string, hosts []string, records map[string]resolution) map[string][]string {
+	addrs := map[string][]string{}
+	for h, r := range records { addrs[h] = r.Addrs }
+	m, err := compileScope(scopeFor(domain)); if err != nil || !m.hasIPRules() { return addrs }
+	var missing []string
+	for _, h := range hosts { if _, ok := records[h]; !ok && net.ParseIP(h) == nil { missing = append(missing, h) } }
+	for h, r := range resolveHosts(ctx, missing) { addrs[h] = r.Addrs }
+	return addrs
+}
+
+// ---------- command ----------
+func cmdScope(ctx context.Context, a cmdArgs) int {
+	sub, rest := a.sub, a.args[1:]
+	domain, err := domainArg(a, 0); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 2 }
+	scopes, err := loadScopes(); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	s := scopes[domain]; if s == nil { s = &Scope{} }
+	switch sub {
+	case "show":
+		pex := programExclude(domain)
+		if len(s.Include) == 0 && len(s.Exclude) == 0 && len(pex) == 0 { fmt.Println("no scope rules for", domain, "(everything in scope)"); return 0 }
+		for _, r := range s.Include { fmt.Println("include", r) }
+		for _, r := range s.Exclude { fmt.Println("exclude", r) }
+		for _, r := range pex { fmt.Println("exclude", r, "(program-wide)") }
+		return 0
+	case "check":
+		m, err := domainMatcher(domain); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+		m.addAddrs(resolveForScope(ctx, domain, rest, nil))
+		for _, h := range rest {
+			ok, why := m.check(h)
+			state := "in-scope"; if !ok { state = "out-of-scope" }
+			if why != "" { fmt.Printf("%s\t%s\t(%s)\n", h, state, why) } else { fmt.Printf("%s\t%s\n", h, state) }
+		}
+		return 0
+	case "include", "exclude":
+		for _, r := range rest {
+			if _, err := parseScopeRule(r); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 2 }
+		}
+		if sub == "include" { s.Include = uniqueSorted(append(s.Include, rest...)) } else { s.Exclude = uniqueSorted(append(s.Exclude, rest...)) }
+	case "rm":
+		s.Include, s.Exclude = without(s.Include, rest), without(s.Exclude, rest)
+	case "clear":
+		s = &Scope{}
+	}
+	scopes[domain] = s
+	if err := saveScopes(scopes); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	fmt.Printf("scope %s: %d include, %d exclude\n", domain, len(s.Include), len(s.Exclude))
+	return 0
+}
+
+func without(list, drop []string) []string {
+	d := map[string]struct{}{}; for _, s := range drop { d[strings.TrimSpace(s)] = struct{}{} }
+	var out []string
+	for _, s := range list { if _, ok := d[s]; !ok { out = append(out, s) } }
+	return out
+}
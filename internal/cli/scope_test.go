@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScopeMatcher(t *testing.T) {
+	m, err := compileScope(&Scope{
+		Include: []string{"*.example.com", "re:^api[0-9]+\\.example\\.org$", "192.0.2.0/24"},
+		Exclude: []string{"blog.example.com", "/^dev-/", "192.0.2.66", "2001:db8::/32"},
+	})
+	if err != nil { t.Fatal(err) }
+	m.addAddrs(map[string][]string{
+		"cdn.example.net":    {"192.0.2.10"},
+		"origin.example.com": {"2001:db8::1"},
+		"other.example.net":  {"198.51.100.1"},
+		"bad.example.net":    {"192.0.2.66"},
+	})
+	tests := []struct {
+		host string
+		in   bool
+		why  string
+	}{
+		{"www.example.com", true, "included by *.example.com"},
+		{"WWW.Example.com", true, "included by *.example.com"},
+		{"blog.example.com", false, "excluded by blog.example.com"},
+		{"dev-1.example.com", false, "excluded by /^dev-/"},
+		{"api7.example.org", true, "included by re:^api[0-9]+\\.example\\.org$"},
+		{"www.example.org", false, "no include rule matched"},
+		{"192.0.2.5", true, "included by 192.0.2.0/24"},
+		{"192.0.2.66", false, "excluded by 192.0.2.66"},
+		{"cdn.example.net", true, "included by 192.0.2.0/24"},
+		{"bad.example.net", false, "excluded by 192.0.2.66"},
+		{"origin.example.com", false, "excluded by 2001:db8::/32"},
+		{"other.example.net", false, "no include rule matched"},
+		// no known address and not a literal: IP rules do not match
+		{"unknown.example.net", false, "no include rule matched"},
+	}
+	for _, tt := range tests {
+		in, why := m.check(tt.host)
+		if in != tt.in || why != tt.why { t.Errorf("check(%q) = %v, %q; want %v, %q", tt.host, in, why, tt.in, tt.why) }
+	}
+	if !m.hasIPRules() { t.Error("hasIPRules = false") }
+	if m, _ := compileScope(&Scope{Include: []string{"*.example.com"}}); m.hasIPRules() { t.Error("hasIPRules = true for host rules") }
+	if m, _ := compileScope(nil); !m.empty() { t.Error("nil scope is not empty") }
+	for _, bad := range []string{"", "re:(", "10.0.0.0/33"} {
+		if _, err := compileScope(&Scope{Include: []string{bad}}); err == nil { t.Errorf("rule %q accepted", bad) }
+	}
+}
+
+func TestSplitScopeStoredAddrs(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	if err := ensureDirs(); err != nil { t.Fatal(err) }
+	if err := saveScopes(map[string]*Scope{"example.com": {Exclude: []string{"10.0.0.0/8"}}}); err != nil { t.Fatal(err) }
+	hosts := []string{"a.example.com", "b.example.com", "c.example.com"}
+	records := map[string]resolution{"a.example.com": {Addrs: []string{"10.1.2.3"}}, "b.example.com": {Addrs: []string{"203.0.113.7"}}}
+	if err := writeSnapshot("example.com", 1, time.Now(), hosts, records); err != nil { t.Fatal(err) }
+
+	in, out := splitScope("example.com", hosts)
+	if want := []string{"b.example.com", "c.example.com"}; !reflect.DeepEqual(in, want) { t.Errorf("in = %q, want %q", in, want) }
+	if want := []string{"a.example.com"}; !reflect.DeepEqual(out, want) { t.Errorf("out = %q, want %q", out, want) }
+
+	// fresher addresses win over the snapshot's
+	in, out = splitScopeAddrs("example.com", hosts, map[string][]string{"c.example.com": {"10.9.9.9"}})
+	if want := []string{"b.example.com"}; !reflect.DeepEqual(in, want) { t.Errorf("in = %q, want %q", in, want) }
+	if want := []string{"a.example.com", "c.example.com"}; !reflect.DeepEqual(out, want) { t.Errorf("out = %q, want %q", out, want) }
+
+	// hosts with records are not looked up again, and a cancelled lookup gives up
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	addrs := resolveForScope(ctx, "example.com", hosts, records)
+	if !reflect.DeepEqual(addrs["a.example.com"], []string{"10.1.2.3"}) { t.Errorf("addrs = %v", addrs) }
+}
@@ -0,0 +1,572 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// `domwatch serve` exposes the home directory over an HTTP JSON API for
+// other tools. It goes through the same helpers as the CLI commands
+// (addDomain, removeDomain, scanMany, loadRuns, loadDiff, ...) and takes the
+// same locks, so it can run next to the daemon and the timer.
+const (
+	DefaultListen   = "127.0.0.1:8080"
+	apiPrefix       = "/api/v1/"
+	maxScanJobs     = 100 // finished jobs kept in memory
+	defaultPageSize = 100
+	maxPageSize     = 1000
+	maxRequestBody  = 1 << 20
+)
+
+// newAPIToken returns a random token for `config set-api-token`.
+func newAPIToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// apiToken is DOMWATCH_API_TOKEN or the api_token of the config.
+func apiToken() string {
+	if v := strings.TrimSpace(os.Getenv("DOMWATCH_API_TOKEN")); v != "" { return v }
+	cfg, _ := loadConfig()
+	if cfg == nil { return "" }
+	return strings.TrimSpace(cfg.APIToken)
+}
+
+type apiServer struct {
+	ctx   context.Context
+	token string
+	opts  scanOptions // defaults for scans started over the API
+	// mon serializes mutations within this process; the home lock is
+	// reentrant per process and so does not order concurrent requests.
+	mon    *Monitor
+	jobsMu sync.Mutex
+	jobs   []*scanJob
+	lastID int
+	wg     sync.WaitGroup // running scan jobs
+}
+
+// scanJob is an asynchronous scan started with POST /api/v1/scans.
+type scanJob struct {
+	ID       int          `json:"id"`
+	Status   string       `json:"status"` // running, done
+	Domains  []string     `json:"domains"`
+	Created  time.Time    `json:"created"`
+	Finished *time.Time   `json:"finished,omitempty"`
+	Results  []ScanRecord `json:"results"` // one per finished domain, see ScanRecord
+}
+
+func cmdServe(ctx context.Context, a cmdArgs) int {
+	s := &apiServer{ctx: ctx, token: apiToken(), opts: scanOptions{Workers: a.int("concurrency"), Timeout: a.duration("timeout"), Trigger: "api", Quiet: true}}
+	// a domain that is being scanned is a conflict, not something to wait for
+	s.mon = cliMonitor("api"); s.mon.opts.LockWait = 0
+	if s.opts.Workers < 1 { return a.usage("--concurrency must be a positive integer") }
+	if s.opts.Timeout < 0 { return a.usage("--timeout must not be negative (0 = none)") }
+	cert, key := a.str("tls-cert"), a.str("tls-key")
+	if (cert == "") != (key == "") { return a.usage("--tls-cert and --tls-key go together") }
+	if len(s.token) < 16 {
+		fmt.Fprintln(os.Stderr, "error: no API token; create one with `domwatch config set-api-token` or set DOMWATCH_API_TOKEN (16+ characters)")
+		return 1
+	}
+	addr := a.str("listen")
+	if addr == "" { addr = DefaultListen }
+	if err := ensureDirs(); err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+
+	ln, err := net.Listen("tcp", addr); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	scheme := "http"; if cert != "" { scheme = "https" }
+	fmt.Printf("domwatch API listening on %s://%s%s (dashboard at /)\n", scheme, ln.Addr(), apiPrefix)
+	done := make(chan error, 1)
+	go func() {
+		if cert != "" { done <- srv.ServeTLS(ln, cert, key) } else { done <- srv.Serve(ln) }
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		srv.Shutdown(sctx)
+		cancel()
+		err = <-done
+	}
+	// interrupted scans still write their run history
+	s.wg.Wait()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+	fmt.Println("domwatch API stopped")
+	return 0
+}
+
+// ---------- routing ----------
+
+// apiError is the body of every non-2xx response.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) { w.code = code; w.ResponseWriter.WriteHeader(code) }
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter } // for http.ResponseController
+
+func (s *apiServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	w := &statusWriter{ResponseWriter: rw, code: http.StatusOK}
+	defer func() { fmt.Printf("%s %s %s %d %s\n", start.Format(time.RFC3339), r.Method, r.URL.Path, w.code, time.Since(start).Round(time.Millisecond)) }()
+	// the dashboard's static files need no token, the API and /metrics do
+	if !strings.HasPrefix(r.URL.Path, apiPrefix) && r.URL.Path != "/metrics" { s.serveStatic(w, r); return }
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="domwatch"`)
+		writeAPIError(w, http.StatusUnauthorized, "missing or invalid API token"); return
+	}
+	if r.URL.Path == "/metrics" { serveMetrics(w); return }
+	p := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+	switch {
+	case route(p, "status"):
+		s.only(w, r, "GET", s.getStatus)
+	case route(p, "domains"):
+		s.only(w, r, "GET POST", map[string]http.HandlerFunc{"GET": s.listDomains, "POST": s.addDomain}[r.Method])
+	case route(p, "domains", "*"):
+		s.only(w, r, "GET DELETE", map[string]http.HandlerFunc{"GET": s.getDomain, "DELETE": s.removeDomain}[r.Method])
+	case route(p, "domains", "*", "hosts"):
+		s.only(w, r, "GET", s.listHosts)
+	case route(p, "domains", "*", "hosts", "*"):
+		s.only(w, r, "GET", s.getHost)
+	case route(p, "domains", "*", "runs"):
+		s.only(w, r, "GET", s.listRuns)
+	case route(p, "domains", "*", "runs", "*"):
+		s.only(w, r, "GET", s.getRun)
+	case route(p, "domains", "*", "diff"):
+		s.only(w, r, "GET", s.getDiff)
+	case route(p, "discoveries"):
+		s.only(w, r, "GET", s.listDiscoveries)
+	case route(p, "search"):
+		s.only(w, r, "GET", s.search)
+	case route(p, "events"):
+		s.only(w, r, "GET", s.events)
+	case route(p, "scans"):
+		s.only(w, r, "GET POST", map[string]http.HandlerFunc{"GET": s.listScans, "POST": s.startScan}[r.Method])
+	case route(p, "scans", "*"):
+		s.only(w, r, "GET", s.getScan)
+	default:
+		writeAPIError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// route matches path segments against pattern; "*" matches any segment.
+func route(p []string, pattern ...string) bool {
+	if len(p) != len(pattern) { return false }
+	for i, s := range pattern { if s != "*" && s != p[i] { return false } }
+	return true
+}
+
+// only calls h if the request method is one of methods (space separated).
+func (s *apiServer) only(w http.ResponseWriter, r *http.Request, methods string, h http.HandlerFunc) {
+	for _, m := range strings.Fields(methods) {
+		if r.Method == m && h != nil { h(w, r); return }
+	}
+	w.Header().Set("Allow", strings.ReplaceAll(methods, " ", ", "))
+	writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func (s *apiServer) authorized(r *http.Request) bool {
+	tok, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	// EventSource cannot set headers, so the event stream also takes ?token=
+	if !ok && r.URL.Path == apiPrefix+"events" { tok, ok = r.URL.Query().Get("token"), true }
+	return ok && subtle.ConstantTimeCompare([]byte(strings.TrimSpace(tok)), []byte(s.token)) == 1
+}
+
+// segment returns path segment i of the request below apiPrefix.
+func segment(r *http.Request, i int) string {
+	p := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+	if i >= len(p) { return "" }
+	return strings.ToLower(p[i])
+}
+
+func writeAPI(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	writeJSON(w, v)
+}
+
+func writeAPIError(w http.ResponseWriter, code int, msg string) { writeAPI(w, code, apiError{msg}) }
+
+// apiFail maps err to a status: busy locks are 409, the rest 500.
+func apiFail(w http.ResponseWriter, err error) {
+	var busy *lockBusyError
+	if errors.As(err, &busy) { writeAPIError(w, http.StatusConflict, err.Error()); return }
+	var bad *domainError
+	if errors.As(err, &bad) { writeAPIError(w, http.StatusBadRequest, err.Error()); return }
+	writeAPIError(w, http.StatusInternalServerError, err.Error())
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error()); return false
+	}
+	return true
+}
+
+// intParam reads a non-negative integer query parameter.
+func intParam(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" { return def, nil }
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 { return 0, fmt.Errorf("%s must be a non-negative integer", name) }
+	return n, nil
+}
+
+func boolParam(r *http.Request, name string) bool {
+	b, _ := strconv.ParseBool(r.URL.Query().Get(name))
+	return b
+}
+
+// locked runs fn under the home lock, like mutating CLI commands.
+func (s *apiServer) locked(fn func() error) error {
+	return s.mon.locked(s.ctx, fn)
+}
+
+// monitored reports whether domain is listed in domains.txt.
+func monitored(domain string) bool {
+	all, _ := readLines(filepath.Join(homeDir(), "domains.txt"))
+	for _, d := range all { if strings.EqualFold(strings.TrimSpace(d), domain) { return true } }
+	return false
+}
+
+// domainParam returns the {domain} segment of the path, answering 404 when
+// it is not monitored.
+func domainParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	d, err := normalizeDomain(segment(r, 1))
+	if err != nil || !monitored(d) { writeAPIError(w, http.StatusNotFound, "unknown domain "+segment(r, 1)); return "", false }
+	return d, true
+}
+
+// ---------- domains ----------
+
+func (s *apiServer) getStatus(w http.ResponseWriter, r *http.Request) {
+	st, err := loadState(); if err != nil { apiFail(w, err); return }
+	reg, err := loadRegistry(); if err != nil { apiFail(w, err); return }
+	writeAPI(w, http.StatusOK, buildStatus(st, reg, time.Now()))
+}
+
+// GET /domains[?program=p][&tag=t]
+func (s *apiServer) listDomains(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ds, err := selectDomains(q.Get("program"), q.Get("tag")); if err != nil { apiFail(w, err); return }
+	recs, err := domainRecords(uniqueSorted(ds)); if err != nil { apiFail(w, err); return }
+	writeAPI(w, http.StatusOK, recs)
+}
+
+// domainDetail is GET /domains/{domain}: the list fields plus the status row.
+type domainDetail struct {
+	DomainRecord
+	Status domainStatus `json:"status"`
+}
+
+func (s *apiServer) getDomain(w http.ResponseWriter, r *http.Request) {
+	d, ok := domainParam(w, r); if !ok { return }
+	recs, err := domainRecords([]string{d}); if err != nil { apiFail(w, err); return }
+	st, err := loadState(); if err != nil { apiFail(w, err); return }
+	reg, err := loadRegistry(); if err != nil { apiFail(w, err); return }
+	out := domainDetail{DomainRecord: recs[0]}
+	for _, row := range buildStatus(st, reg, time.Now()).Domains { if row.Domain == d { out.Status = row } }
+	writeAPI(w, http.StatusOK, out)
+}
+
+// addRequest is the body of POST /domains; the fields mirror `domwatch add`.
+type addRequest struct {
+	Domain      string   `json:"domain"`
+	Program     string   `json:"program"`
+	Tags        []string `json:"tags"`
+	Registrable bool     `json:"registrable"`
+	Force       bool     `json:"force"`
+}
+
+func (s *apiServer) addDomain(w http.ResponseWriter, r *http.Request) {
+	var req addRequest
+	if !decodeBody(w, r, &req) { return }
+	domain, created, err := s.mon.AddDomain(s.ctx, req.Domain, AddOptions{Program: req.Program, Tags: req.Tags, Force: req.Force, Registrable: req.Registrable})
+	if err != nil { apiFail(w, err); return }
+	code := http.StatusOK
+	if created { code = http.StatusCreated }
+	writeAPI(w, code, map[string]any{"domain": domain, "created": created})
+}
+
+func (s *apiServer) removeDomain(w http.ResponseWriter, r *http.Request) {
+	d, ok := domainParam(w, r); if !ok { return }
+	if err := s.mon.RemoveDomain(s.ctx, d); err != nil { apiFail(w, err); return }
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hostPage is GET /domains/{domain}/hosts.
+type hostPage struct {
+	Domain string       `json:"domain"`
+	Total  int          `json:"total"` // after filtering
+	Offset int          `json:"offset"`
+	Limit  int          `json:"limit"`
+	Hosts  []HostRecord `json:"hosts"`
+}
+
+// GET /domains/{domain}/hosts[?scope=in|out][&q=substring][&offset=N][&limit=N]
+func (s *apiServer) listHosts(w http.ResponseWriter, r *http.Request) {
+	d, ok := domainParam(w, r); if !ok { return }
+	offset, err := intParam(r, "offset", 0); if err != nil { writeAPIError(w, http.StatusBadRequest, err.Error()); return }
+	limit, err := intParam(r, "limit", defaultPageSize); if err != nil { writeAPIError(w, http.StatusBadRequest, err.Error()); return }
+	if limit == 0 || limit > maxPageSize { limit = maxPageSize }
+	hosts, err := readLines(filepath.Join(dataDir(), d+".txt")); if err != nil { apiFail(w, err); return }
+	switch scope := r.URL.Query().Get("scope"); scope {
+	case "":
+	case "in", "out":
+		in, out := splitScope(d, hosts)
+		if hosts = in; scope == "out" { hosts = out }
+	default:
+		writeAPIError(w, http.StatusBadRequest, "scope must be in or out"); return
+	}
+	if q := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q"))); q != "" {
+		var match []string
+		for _, h := range hosts { if strings.Contains(h, q) || strings.Contains(displayHost(h), q) { match = append(match, h) } }
+		hosts = match
+	}
+	page := hostPage{Domain: d, Total: len(hosts), Offset: offset, Limit: limit}
+	if offset > len(hosts) { offset = len(hosts) }
+	hosts = hosts[offset:]
+	if len(hosts) > limit { hosts = hosts[:limit] }
+	page.Hosts = hostRecords(d, hosts)
+	writeAPI(w, http.StatusOK, page)
+}
+
+// GET /domains/{domain}/runs[?limit=N][&failed=true], oldest first
+func (s *apiServer) listRuns(w http.ResponseWriter, r *http.Request) {
+	d, ok := domainParam(w, r); if !ok { return }
+	limit, err := intParam(r, "limit", 20); if err != nil { writeAPIError(w, http.StatusBadRequest, err.Error()); return }
+	runs, err := loadRuns(d); if err != nil { apiFail(w, err); return }
+	if boolParam(r, "failed") {
+		var failed []RunRecord
+		for _, run := range runs { if run.Status != "ok" { failed = append(failed, run) } }
+		runs = failed
+	}
+	if limit > 0 && len(runs) > limit { runs = runs[len(runs)-limit:] }
+	if runs == nil { runs = []RunRecord{} }
+	writeAPI(w, http.StatusOK, runs)
+}
+
+func (s *apiServer) getRun(w http.ResponseWriter, r *http.Request) {
+	d, ok := domainParam(w, r); if !ok { return }
+	seq, err := strconv.Atoi(segment(r, 3)); if err != nil { writeAPIError(w, http.StatusBadRequest, "run must be a number"); return }
+	runs, err := loadRuns(d); if err != nil { apiFail(w, err); return }
+	for _, run := range runs { if run.Seq == seq { writeAPI(w, http.StatusOK, run); return } }
+	writeAPIError(w, http.StatusNotFound, fmt.Sprintf("no run #%d for %s", seq, d))
+}
+
+// GET /domains/{domain}/diff[?from=<run|date>][&to=<run|date>], as `diff --json`
+func (s *apiServer) getDiff(w http.ResponseWriter, r *http.Request) {
+	d, ok := domainParam(w, r); if !ok { return }
+	diff, err := loadDiff(d, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	switch {
+	case errors.Is(err, errNoSnapshots):
+		writeAPIError(w, http.StatusNotFound, d+": "+err.Error())
+	case err != nil:
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeAPI(w, http.StatusOK, diff)
+	}
+}
+
+// ---------- scans ----------
+
+// scanRequest is the body of POST /scans. Domains are scanned as given;
+// all/program/tag select like `scan --all`, i.e. only due domains unless force.
+type scanRequest struct {
+	Domains []string `json:"domains"`
+	All     bool     `json:"all"`
+	Program string   `json:"program"`
+	Tag     string   `json:"tag"`
+	Force   bool     `json:"force"`
+	Resolve bool     `json:"resolve"`
+	AI      bool     `json:"ai"`
+}
+
+func (s *apiServer) startScan(w http.ResponseWriter, r *http.Request) {
+	var req scanRequest
+	if !decodeBody(w, r, &req) { return }
+	var domains []string
+	for _, v := range req.Domains {
+		d, err := normalizeDomain(v); if err != nil { writeAPIError(w, http.StatusBadRequest, err.Error()); return }
+		if !monitored(d) { writeAPIError(w, http.StatusNotFound, "unknown domain "+d+" (add it first)"); return }
+		domains = append(domains, d)
+	}
+	if req.All || req.Program != "" || req.Tag != "" {
+		list, err := selectDomains(req.Program, req.Tag); if err != nil { apiFail(w, err); return }
+		due, _, _, err := dueDomains(uniqueSorted(list), DefaultScanInterval, req.Force); if err != nil { apiFail(w, err); return }
+		domains = append(domains, due...)
+	}
+	if len(req.Domains) == 0 && !req.All && req.Program == "" && req.Tag == "" {
+		writeAPIError(w, http.StatusBadRequest, "give domains, all, program or tag"); return
+	}
+	opts := s.opts
+	opts.Resolve, opts.WithAI = req.Resolve, req.AI
+	job := s.newJob(uniqueSorted(domains))
+	w.Header().Set("Location", fmt.Sprintf("%sscans/%d", apiPrefix, job.ID))
+	writeAPI(w, http.StatusAccepted, s.snapshotJob(job))
+	if len(job.Domains) > 0 { s.wg.Add(1); go s.runJob(job, opts) }
+}
+
+func (s *apiServer) newJob(domains []string) *scanJob {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	s.lastID++
+	job := &scanJob{ID: s.lastID, Status: "running", Domains: domains, Created: time.Now(), Results: []ScanRecord{}}
+	if domains == nil { job.Domains = []string{} }
+	if len(domains) == 0 { job.Status, job.Finished = "done", optTime(job.Created) }
+	s.jobs = append(s.jobs, job)
+	// forget the oldest finished jobs
+	for i := 0; len(s.jobs) > maxScanJobs && i < len(s.jobs); {
+		if s.jobs[i].Status == "done" { s.jobs = append(s.jobs[:i], s.jobs[i+1:]...) } else { i++ }
+	}
+	return job
+}
+
+// runJob scans the domains of job like `scan` does and records each result.
+func (s *apiServer) runJob(job *scanJob, opts scanOptions) {
+	defer s.wg.Done()
+	finish := func(recs []ScanRecord) {
+		s.jobsMu.Lock()
+		job.Results = append(job.Results, recs...)
+		job.Status, job.Finished = "done", optTime(time.Now())
+		s.jobsMu.Unlock()
+	}
+	if err := ensureSubfinder(); err != nil {
+		var recs []ScanRecord
+		for _, d := range job.Domains { recs = append(recs, newScanRecord(d, scanResult{}, err)) }
+		finish(recs)
+		return
+	}
+	flushPending(s.ctx, os.Stderr)
+	opts.OnDone = func(d string, r scanResult, err error) {
+		recordScan(d, r, err)
+		rec := newScanRecord(d, r, err)
+		s.jobsMu.Lock(); job.Results = append(job.Results, rec); s.jobsMu.Unlock()
+	}
+	sum := scanMany(s.ctx, job.Domains, opts)
+	finish(skippedRecords(sum))
+}
+
+// snapshotJob copies job so it can be encoded without holding jobsMu.
+func (s *apiServer) snapshotJob(job *scanJob) scanJob {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	c := *job
+	c.Results = append([]ScanRecord{}, job.Results...)
+	return c
+}
+
+// GET /scans: jobs of this server process, newest first
+func (s *apiServer) listScans(w http.ResponseWriter, r *http.Request) {
+	s.jobsMu.Lock()
+	jobs := make([]*scanJob, len(s.jobs))
+	copy(jobs, s.jobs)
+	s.jobsMu.Unlock()
+	out := make([]scanJob, 0, len(jobs))
+	for i := len(jobs) - 1; i >= 0; i-- { out = append(out, s.snapshotJob(jobs[i])) }
+	writeAPI(w, http.StatusOK, out)
+}
+
+func (s *apiServer) getScan(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(segment(r, 1)); if err != nil { writeAPIError(w, http.StatusBadRequest, "scan id must be a number"); return }
+	s.jobsMu.Lock()
+	var job *scanJob
+	for _, j := range s.jobs { if j.ID == id { job = j } }
+	s.jobsMu.Unlock()
+	if job == nil { writeAPIError(w, http.StatusNotFound, fmt.Sprintf("no scan #%d", id)); return }
+	writeAPI(w, http.StatusOK, s.snapshotJob(job))
+}
+
+// ---------- events ----------
+
+// GET /events[?since=ID][&types=a,b][&domain=d][&limit=N]. With Accept:
+// text/event-stream the events are streamed as they are logged (resuming
+// after Last-Event-ID, else after since, else from now); otherwise the
+// events after since (default 0) are returned as one JSON page, oldest first.
+func (s *apiServer) events(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	stream := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	var after int64
+	v := r.Header.Get("Last-Event-ID"); if v == "" { v = q.Get("since") }
+	if v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 { writeAPIError(w, http.StatusBadRequest, "since must be an event id"); return }
+		after = n
+	} else if stream {
+		last, _, err := lastEventID(); if err != nil { apiFail(w, err); return }
+		after = last
+	}
+	types := map[string]bool{}
+	for _, t := range strings.Split(q.Get("types"), ",") {
+		if t = strings.TrimSpace(t); t == "" { continue }
+		switch t {
+		case EventScanStarted, EventHostAdded, EventHostRemoved, EventRecordChanged, EventScanFinished:
+			types[t] = true
+		default:
+			writeAPIError(w, http.StatusBadRequest, "unknown event type "+t); return
+		}
+	}
+	domain := strings.ToLower(q.Get("domain"))
+	keep := func(ev Event) bool { return (len(types) == 0 || types[ev.Type]) && (domain == "" || ev.Domain == domain) }
+	c := &eventCursor{after: after}
+
+	if !stream {
+		limit, err := intParam(r, "limit", maxPageSize); if err != nil { writeAPIError(w, http.StatusBadRequest, err.Error()); return }
+		if limit == 0 || limit > maxPageSize { limit = maxPageSize }
+		evs, err := c.next(keep, limit); if err != nil { apiFail(w, err); return }
+		if evs == nil { evs = []Event{} }
+		writeAPI(w, http.StatusOK, evs)
+		return
+	}
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil { return }
+	tick := time.NewTicker(eventsPoll)
+	defer tick.Stop()
+	lastWrite := time.Now()
+	for {
+		evs, err := c.next(keep, 0)
+		if err != nil { fmt.Fprintln(os.Stderr, "error: event log:", err); return }
+		for _, ev := range evs {
+			b, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, b)
+		}
+		if len(evs) > 0 || time.Since(lastWrite) >= eventsKeepAlive {
+			if len(evs) == 0 { fmt.Fprint(w, ": keep-alive\n\n") }
+			if err := rc.Flush(); err != nil { return }
+			lastWrite = time.Now()
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
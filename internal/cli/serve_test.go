@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIDomains(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	if _, err := addDomain("example.com"); err != nil { t.Fatal(err) }
+	mon := cliMonitor("api")
+	mon.opts.Output = io.Discard
+	s := &apiServer{ctx: context.Background(), token: "0123456789abcdef", mon: mon}
+	do := func(method, path, body string) (int, string) {
+		r := httptest.NewRequest(method, apiPrefix+path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+s.token)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		var e apiError
+		json.Unmarshal(w.Body.Bytes(), &e)
+		return w.Code, e.Error
+	}
+	for _, tt := range []struct{ method, path, body string; code int }{
+		{"GET", "domains/example.com", "", http.StatusOK},
+		{"GET", "domains/example.com/runs", "", http.StatusOK},
+		{"GET", "domains/example.com/runs/1", "", http.StatusNotFound},
+		{"GET", "domains/example.com/diff", "", http.StatusNotFound},
+		{"GET", "domains/example.org", "", http.StatusNotFound},
+		{"GET", "domains/example.org/hosts", "", http.StatusNotFound},
+		{"GET", "domains/example.org/hosts/www.example.org", "", http.StatusNotFound},
+		{"GET", "domains/example.org/runs", "", http.StatusNotFound},
+		{"GET", "domains/example.org/runs/1", "", http.StatusNotFound},
+		{"GET", "domains/example.org/diff", "", http.StatusNotFound},
+		{"DELETE", "domains/example.org", "", http.StatusNotFound},
+		{"POST", "domains", `{"domain": "co.uk"}`, http.StatusBadRequest},
+		{"POST", "domains", `{"domain": "10.0.0.1"}`, http.StatusBadRequest},
+		{"POST", "domains", `{"domain": "example.com"}`, http.StatusOK},
+		{"POST", "domains", `{"domain": "Example.NET"}`, http.StatusCreated},
+		{"GET", "domains/example.net/runs", "", http.StatusOK},
+		{"GET", "discoveries?domain=Example.COM.", "", http.StatusOK},
+		{"GET", "discoveries?domain=example.org", "", http.StatusNotFound},
+	} {
+		code, msg := do(tt.method, tt.path, tt.body)
+		if code != tt.code { t.Errorf("%s %s = %d (%s), want %d", tt.method, tt.path, code, msg, tt.code) }
+	}
+}
+
+func TestHostProbes(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	if _, err := addDomain("example.com"); err != nil { t.Fatal(err) }
+	hosts := "a.example.com\nb.example.com\nwww.a.example.com\n"
+	if err := os.WriteFile(filepath.Join(dataDir(), "example.com.txt"), []byte(hosts), 0o644); err != nil { t.Fatal(err) }
+	start := time.Unix(1700000000, 0).UTC()
+	for i, hooks := range [][]HookResult{
+		{{Name: "httpx", Status: "ok", Output: "https://a.example.com [200] [Old]\nhttps://b.example.com [404]\n"}},
+		{
+			{Name: "httpx", Status: "ok", Output: "https://A.example.com:443 [301] [Moved]\nhttps://www.a.example.com [200]\n"},
+			{Name: "nuclei", Status: "failed", Output: "[tls-version] [ssl] [info] a.example.com:443 [\"tls12\"]\n[INF] done\n"},
+		},
+	} {
+		r := RunRecord{Domain: "example.com", Start: start.Add(time.Duration(i) * time.Hour), Status: "ok", Hooks: hooks}
+		if err := appendRun(&r); err != nil { t.Fatal(err) }
+	}
+	runs, err := loadRuns("example.com"); if err != nil { t.Fatal(err) }
+	probes := hostProbes(runs, []string{"a.example.com", "b.example.com", "c.example.com"})
+	want := map[string][]hostProbe{
+		"a.example.com": {
+			{Hook: "httpx", Run: 2, At: runs[1].Start, Status: "ok", Lines: []string{"https://A.example.com:443 [301] [Moved]"}},
+			{Hook: "nuclei", Run: 2, At: runs[1].Start, Status: "failed", Lines: []string{`[tls-version] [ssl] [info] a.example.com:443 ["tls12"]`}},
+		},
+		// the newer run did not name it: the older one is kept
+		"b.example.com": {{Hook: "httpx", Run: 1, At: runs[0].Start, Status: "ok", Lines: []string{"https://b.example.com [404]"}}},
+	}
+	if !reflect.DeepEqual(probes, want) { t.Errorf("hostProbes = %+v\nwant %+v", probes, want) }
+
+	s := &apiServer{ctx: context.Background(), token: "0123456789abcdef", mon: cliMonitor("api")}
+	for host, n := range map[string]int{"a.example.com": 2, "www.a.example.com": 1} {
+		r := httptest.NewRequest("GET", apiPrefix+"domains/example.com/hosts/"+host, nil)
+		r.Header.Set("Authorization", "Bearer "+s.token)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		var det hostDetail
+		if err := json.Unmarshal(w.Body.Bytes(), &det); err != nil || w.Code != http.StatusOK { t.Fatalf("%s: %d %s", host, w.Code, w.Body) }
+		if len(det.Probes) != n { t.Errorf("%s: %d probes, want %d: %+v", host, len(det.Probes), n, det.Probes) }
+	}
+}
+
+func TestAPIDiscoveriesDomain(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	if _, err := addDomain("example.com"); err != nil { t.Fatal(err) }
+	batch := filepath.Join(dataDir(), fmt.Sprintf("example.com_new_%d.txt", time.Now().Unix()))
+	if err := os.WriteFile(batch, []byte("www.example.com\n"), 0o644); err != nil { t.Fatal(err) }
+	s := &apiServer{ctx: context.Background(), token: "0123456789abcdef", mon: cliMonitor("api")}
+	for _, v := range []string{"example.com", "Example.COM.", "%20example.com"} {
+		r := httptest.NewRequest("GET", apiPrefix+"discoveries?domain="+v, nil)
+		r.Header.Set("Authorization", "Bearer "+s.token)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		var got []discovery
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusOK { t.Fatalf("domain=%s: %d %s", v, w.Code, w.Body) }
+		if len(got) != 1 || got[0].Host != "www.example.com" { t.Errorf("domain=%s: %+v", v, got) }
+	}
+}
@@ -0,0 +1,319 @@
+package cli
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A snapshot is the set of hosts one scan returned (plus their addresses
+// and CNAME when the scan ran with --resolve), kept per run as
+// snapshots/<domain>/<run>-<unix>.txt.gz so any two points in time can be
+// compared. The inventory in data/ only ever grows; snapshots also show
+// what disappeared.
+const (
+	SnapshotsRelDir = "snapshots"
+	resolveWorkers  = 20
+	resolveTimeout  = 5 * time.Second
+)
+
+func snapshotsDir(domain string) string { return filepath.Join(homeDir(), SnapshotsRelDir, domain) }
+
+// snapshot maps host -> sorted addresses; nil means "not resolved" and an
+// empty, non-nil slice means the host did not resolve.
+type snapshot struct {
+	Run    int
+	Time   time.Time
+	Hosts  map[string][]string
+	CNAMEs map[string]string // only hosts that are aliases
+}
+
+// resolution is what --resolve found for one host.
+type resolution struct {
+	Addrs []string
+	CNAME string
+}
+
+// newSnapshot is the snapshot writeSnapshot stores for hosts and records.
+func newSnapshot(run int, t time.Time, hosts []string, records map[string]resolution) *snapshot {
+	s := &snapshot{Run: run, Time: t, Hosts: make(map[string][]string, len(hosts)), CNAMEs: map[string]string{}}
+	for _, h := range hosts {
+		s.Hosts[h] = nil
+		r, ok := records[h]
+		if !ok { continue }
+		s.Hosts[h] = append([]string{}, r.Addrs...)
+		if r.CNAME != "" { s.CNAMEs[h] = r.CNAME }
+	}
+	return s
+}
+
+// latestSnapshot reads the newest snapshot of domain, or nil if none.
+func latestSnapshot(domain string) *snapshot {
+	refs, err := listSnapshots(domain)
+	if err != nil || len(refs) == 0 { return nil }
+	s, _ := readSnapshot(refs[len(refs)-1])
+	return s
+}
+
+type snapshotRef struct {
+	Run  int
+	Time time.Time
+	path string
+}
+
+// writeSnapshot stores one line per host: host[<TAB>addrs|-[<TAB>cname]].
+func writeSnapshot(domain string, run int, t time.Time, hosts []string, records map[string]resolution) error {
+	var b strings.Builder
+	zw := gzip.NewWriter(&b)
+	for _, h := range hosts {
+		line := h
+		if r, ok := records[h]; ok {
+			addrs := "-"
+			if len(r.Addrs) > 0 { addrs = strings.Join(r.Addrs, ",") }
+			line += "\t" + addrs
+			if r.CNAME != "" { line += "\t" + r.CNAME }
+		}
+		io.WriteString(zw, line+"\n")
+	}
+	if err := zw.Close(); err != nil { return err }
+	p := filepath.Join(snapshotsDir(domain), fmt.Sprintf("%d-%d.txt.gz", run, t.Unix()))
+	return writeFileAtomic(p, []byte(b.String()), 0o644)
+}
+
+// pruneSnapshots deletes the snapshots of domain beyond the newest keep or
+// older than maxDays (0 = no limit) and returns how many it removed. The
+// newest snapshot is always kept: scope checks and the next diff need it.
+func pruneSnapshots(domain string, keep, maxDays int, now time.Time) (int, error) {
+	if keep <= 0 && maxDays <= 0 { return 0, nil }
+	refs, err := listSnapshots(domain)
+	if err != nil || len(refs) < 2 { return 0, err }
+	cutoff := now.AddDate(0, 0, -maxDays)
+	n := 0
+	for i, ref := range refs[:len(refs)-1] {
+		tooMany := keep > 0 && len(refs)-i > keep
+		tooOld := maxDays > 0 && ref.Time.Before(cutoff)
+		if !tooMany && !tooOld { continue }
+		if err := os.Remove(ref.path); err != nil && !os.IsNotExist(err) { return n, err }
+		n++
+	}
+	return n, nil
+}
+
+// retentionText describes the snapshot retention of cfg.
+func retentionText(cfg *Config) string {
+	var parts []string
+	if cfg.SnapshotKeep > 0 { parts = append(parts, fmt.Sprintf("newest %d", cfg.SnapshotKeep)) }
+	if cfg.SnapshotMaxDays > 0 { parts = append(parts, fmt.Sprintf("%d days", cfg.SnapshotMaxDays)) }
+	if len(parts) == 0 { return "keep all" }
+	return strings.Join(parts, ", at most ")
+}
+
+// listSnapshots returns the snapshots of domain, oldest first.
+func listSnapshots(domain string) ([]snapshotRef, error) {
+	entries, err := os.ReadDir(snapshotsDir(domain))
+	if os.IsNotExist(err) { return nil, nil }
+	if err != nil { return nil, err }
+	var out []snapshotRef
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".txt.gz")
+		a, b, ok := strings.Cut(name, "-")
+		if !ok || name == e.Name() { continue }
+		run, err1 := strconv.Atoi(a)
+		ts, err2 := strconv.ParseInt(b, 10, 64)
+		if err1 != nil || err2 != nil { continue }
+		out = append(out, snapshotRef{Run: run, Time: time.Unix(ts, 0), path: filepath.Join(snapshotsDir(domain), e.Name())})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Run < out[j].Run })
+	return out, nil
+}
+
+func readSnapshot(ref snapshotRef) (*snapshot, error) {
+	f, err := os.Open(ref.path); if err != nil { return nil, err }
+	defer f.Close()
+	zr, err := gzip.NewReader(f); if err != nil { return nil, fmt.Errorf("%s: %w", ref.path, err) }
+	s := &snapshot{Run: ref.Run, Time: ref.Time, Hosts: map[string][]string{}, CNAMEs: map[string]string{}}
+	sc := bufio.NewScanner(zr)
+	for sc.Scan() {
+		f := strings.Split(sc.Text(), "\t")
+		host := f[0]
+		if host == "" { continue }
+		s.Hosts[host] = nil
+		if len(f) > 1 {
+			s.Hosts[host] = []string{}
+			if f[1] != "-" { s.Hosts[host] = strings.Split(f[1], ",") }
+		}
+		if len(f) > 2 && f[2] != "" { s.CNAMEs[host] = f[2] }
+	}
+	if err := sc.Err(); err != nil { return nil, fmt.Errorf("%s: %w", ref.path, err) }
+	return s, nil
+}
+
+// resolveHosts looks up the addresses and CNAME of hosts with a small
+// worker pool. Hosts that do not resolve get no addresses; a CNAME whose
+// target does not resolve is still recorded (see takeoverCandidate).
+func resolveHosts(ctx context.Context, hosts []string) map[string]resolution {
+	out := make(map[string]resolution, len(hosts))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	for w := 0; w < resolveWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for h := range jobs {
+				lctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+				addrs, _ := resolver.LookupHost(lctx, h)
+				cname, _ := resolver.LookupCNAME(lctx, h)
+				cancel()
+				sort.Strings(addrs)
+				r := resolution{Addrs: addrs, CNAME: strings.TrimSuffix(strings.ToLower(cname), ".")}
+				if r.CNAME == h { r.CNAME = "" }
+				mu.Lock(); out[h] = r; mu.Unlock()
+			}
+		}()
+	}
+	for _, h := range hosts {
+		if ctx.Err() != nil { break }
+		jobs <- h
+	}
+	close(jobs)
+	wg.Wait()
+	return out
+}
+
+// ---------- diff ----------
+
+// snapshotDiff is the result of comparing two snapshots (JSON output of
+// `domwatch diff --json`).
+type snapshotDiff struct {
+	Domain  string       `json:"domain"`
+	From    diffEnd      `json:"from"`
+	To      diffEnd      `json:"to"`
+	Added   []string     `json:"added"`
+	Removed []string     `json:"removed"`
+	Changed []hostChange `json:"changed"`
+}
+
+type diffEnd struct {
+	Run  int       `json:"run"`
+	Time time.Time `json:"time"`
+}
+
+// hostChange is a host present in both snapshots whose addresses or CNAME
+// changed. A CNAME that moves while the host stays unresolved is what a
+// takeover looks like, so it counts even when the addresses did not change.
+type hostChange struct {
+	Host      string   `json:"host"`
+	From      []string `json:"from"`
+	To        []string `json:"to"`
+	FromCNAME string   `json:"from_cname,omitempty"`
+	ToCNAME   string   `json:"to_cname,omitempty"`
+}
+
+func diffSnapshots(domain string, a, b *snapshot) snapshotDiff {
+	d := snapshotDiff{Domain: domain, From: diffEnd{a.Run, a.Time}, To: diffEnd{b.Run, b.Time}, Added: []string{}, Removed: []string{}, Changed: []hostChange{}}
+	for h, recs := range b.Hosts {
+		old, ok := a.Hosts[h]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, h)
+		case old != nil && recs != nil && (strings.Join(old, ",") != strings.Join(recs, ",") || a.CNAMEs[h] != b.CNAMEs[h]):
+			d.Changed = append(d.Changed, hostChange{h, old, recs, a.CNAMEs[h], b.CNAMEs[h]})
+		}
+	}
+	for h := range a.Hosts { if _, ok := b.Hosts[h]; !ok { d.Removed = append(d.Removed, h) } }
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Host < d.Changed[j].Host })
+	return d
+}
+
+// pickSnapshot resolves a --from/--to value: a run number ("42" or "#42"),
+// "first", "latest", or a date/time, meaning the last snapshot taken at or
+// before it.
+func pickSnapshot(refs []snapshotRef, v string) (snapshotRef, error) {
+	switch v = strings.TrimSpace(v); v {
+	case "first":
+		return refs[0], nil
+	case "latest", "last":
+		return refs[len(refs)-1], nil
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(v, "#")); err == nil {
+		for _, r := range refs { if r.Run == n { return r, nil } }
+		return snapshotRef{}, fmt.Errorf("no snapshot for run #%d", n)
+	}
+	t, dayOnly, err := parseDate(v)
+	if err != nil { return snapshotRef{}, fmt.Errorf("%q is not a run number or date (YYYY-MM-DD[ HH:MM])", v) }
+	if dayOnly { t = t.AddDate(0, 0, 1).Add(-time.Second) } // end of that day
+	if ref, ok := snapshotAt(refs, t); ok { return ref, nil }
+	return snapshotRef{}, fmt.Errorf("no snapshot at or before %s (first is %s)", v, refs[0].Time.Local().Format("2006-01-02 15:04"))
+}
+
+// snapshotAt returns the last snapshot taken at or before t.
+func snapshotAt(refs []snapshotRef, t time.Time) (snapshotRef, bool) {
+	for i := len(refs) - 1; i >= 0; i-- { if !refs[i].Time.After(t) { return refs[i], true } }
+	return snapshotRef{}, false
+}
+
+// parseDate accepts RFC 3339 or local "YYYY-MM-DD[ HH:MM]"; dayOnly
+// reports that no time of day was given.
+func parseDate(v string) (t time.Time, dayOnly bool, err error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err = time.ParseInLocation(layout, v, time.Local); err == nil { return t, layout == "2006-01-02", nil }
+	}
+	return t, false, err
+}
+
+var errNoSnapshots = errors.New("no snapshots yet; they are taken by every successful scan")
+
+// loadDiff compares the snapshots of domain picked by from and to (see
+// pickSnapshot). By default to is the latest and from the one before it.
+func loadDiff(domain, fromV, toV string) (snapshotDiff, error) {
+	refs, err := listSnapshots(domain); if err != nil { return snapshotDiff{}, err }
+	if len(refs) < 1 { return snapshotDiff{}, errNoSnapshots }
+	to := refs[len(refs)-1]
+	if toV != "" {
+		if to, err = pickSnapshot(refs, toV); err != nil { return snapshotDiff{}, err }
+	}
+	from := refs[0]
+	for _, r := range refs { if r.Run < to.Run { from = r } }
+	if fromV != "" {
+		if from, err = pickSnapshot(refs, fromV); err != nil { return snapshotDiff{}, err }
+	}
+	if from.Run > to.Run { return snapshotDiff{}, fmt.Errorf("--from (run #%d) is newer than --to (run #%d)", from.Run, to.Run) }
+	a, err := readSnapshot(from); if err != nil { return snapshotDiff{}, err }
+	b, err := readSnapshot(to); if err != nil { return snapshotDiff{}, err }
+	return diffSnapshots(domain, a, b), nil
+}
+
+func cmdDiff(ctx context.Context, a cmdArgs) int {
+	domain, err := domainArg(a, 0); if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 2 }
+	d, err := loadDiff(domain, a.str("from"), a.str("to"))
+	if errors.Is(err, errNoSnapshots) { fmt.Println("no snapshots for", domain, "yet; they are taken by every successful scan"); return 1 }
+	if err != nil { fmt.Fprintln(os.Stderr, "error:", err); return 1 }
+
+	if a.bool("json") { return exitWrite(writeJSON(os.Stdout, d)) }
+	fmt.Printf("%s: run #%d (%s) -> run #%d (%s)\n", domain, d.From.Run, d.From.Time.Local().Format("2006-01-02 15:04"), d.To.Run, d.To.Time.Local().Format("2006-01-02 15:04"))
+	for _, h := range d.Added { fmt.Println("+", hostLabel(h)) }
+	for _, h := range d.Removed { fmt.Println("-", hostLabel(h)) }
+	for _, c := range d.Changed { fmt.Printf("~ %s  %s -> %s\n", hostLabel(c.Host), recordsLabel(c.From, c.FromCNAME), recordsLabel(c.To, c.ToCNAME)) }
+	fmt.Printf("%d added, %d removed, %d changed\n", len(d.Added), len(d.Removed), len(d.Changed))
+	return 0
+}
+
+func recordsLabel(r []string, cname string) string {
+	s := strings.Join(r, ",")
+	if len(r) == 0 { s = "(no records)" }
+	if cname != "" { s = "CNAME " + cname + " " + s }
+	return s
+}
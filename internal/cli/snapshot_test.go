@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	// runs 1..6, 10 days apart, the last one 5 days ago
+	setup := func(t *testing.T) {
+		t.Setenv("DOMWATCH_HOME", t.TempDir())
+		for run := 1; run <= 6; run++ {
+			at := now.AddDate(0, 0, -5-10*(6-run))
+			if err := writeSnapshot("example.com", run, at, []string{"www.example.com"}, nil); err != nil { t.Fatal(err) }
+		}
+	}
+	runs := func(t *testing.T) []int {
+		refs, err := listSnapshots("example.com"); if err != nil { t.Fatal(err) }
+		var out []int
+		for _, r := range refs { out = append(out, r.Run) }
+		return out
+	}
+	for _, tt := range []struct {
+		name       string
+		keep, days int
+		want       []int
+	}{
+		{"no limits", 0, 0, []int{1, 2, 3, 4, 5, 6}},
+		{"keep 3", 3, 0, []int{4, 5, 6}},
+		{"keep more than there are", 10, 0, []int{1, 2, 3, 4, 5, 6}},
+		{"30 days", 0, 30, []int{4, 5, 6}},
+		{"both, count wins", 2, 30, []int{5, 6}},
+		{"both, age wins", 5, 20, []int{5, 6}},
+		{"newest is never removed", 0, 1, []int{6}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			setup(t)
+			n, err := pruneSnapshots("example.com", tt.keep, tt.days, now); if err != nil { t.Fatal(err) }
+			got := runs(t)
+			if len(got) != len(tt.want) || n != 6-len(tt.want) { t.Fatalf("kept %v (removed %d), want %v", got, n, tt.want) }
+			for i := range got { if got[i] != tt.want[i] { t.Fatalf("kept %v, want %v", got, tt.want) } }
+		})
+	}
+}
+
+func TestDiffSnapshotsCNAME(t *testing.T) {
+	a := &snapshot{Run: 1, Hosts: map[string][]string{"app.example.com": {}, "www.example.com": {"192.0.2.1"}, "new.example.com": nil},
+		CNAMEs: map[string]string{"app.example.com": "old.herokudns.com"}}
+	b := &snapshot{Run: 2, Hosts: map[string][]string{"app.example.com": {}, "www.example.com": {"192.0.2.1"}, "new.example.com": {}},
+		CNAMEs: map[string]string{"app.example.com": "new.herokudns.com", "new.example.com": "x.example.net"}}
+	d := diffSnapshots("example.com", a, b)
+	// new.example.com was not resolved in run 1: not a change
+	if len(d.Changed) != 1 { t.Fatalf("changed = %+v, want app.example.com only", d.Changed) }
+	if c := d.Changed[0]; c.Host != "app.example.com" || c.FromCNAME != "old.herokudns.com" || c.ToCNAME != "new.herokudns.com" { t.Errorf("change = %+v", c) }
+}
+
+func TestLoadDiffOrder(t *testing.T) {
+	t.Setenv("DOMWATCH_HOME", t.TempDir())
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for run := 1; run <= 3; run++ {
+		if err := writeSnapshot("example.com", run, at.AddDate(0, 0, run), []string{"www.example.com"}, nil); err != nil { t.Fatal(err) }
+	}
+	d, err := loadDiff("example.com", "", ""); if err != nil { t.Fatal(err) }
+	if d.From.Run != 2 || d.To.Run != 3 { t.Errorf("default diff = #%d -> #%d, want #2 -> #3", d.From.Run, d.To.Run) }
+	if d, err = loadDiff("example.com", "1", "2"); err != nil || d.From.Run != 1 || d.To.Run != 2 { t.Errorf("1 -> 2: %+v, %v", d, err) }
+	if _, err := loadDiff("example.com", "3", "1"); err == nil { t.Error("--from 3 --to 1 accepted") }
+	if _, err := loadDiff("example.com", "latest", "first"); err == nil { t.Error("--from latest --to first accepted") }
+}
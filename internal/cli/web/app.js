@@ -0,0 +1,250 @@
+// DomWatch dashboard: a small hash-routed page over the JSON API of
+// `domwatch serve`. Everything is built with DOM calls (textContent), never
+// innerHTML, so host names and AI text cannot inject markup.
+"use strict";
+
+const API = "/api/v1/";
+const view = document.getElementById("view");
+const PAGE = 100;
+
+function token() { return localStorage.getItem("domwatch-token") || ""; }
+
+class AuthError extends Error {}
+
+async function api(path, opts = {}) {
+  const res = await fetch(API + path, {
+    ...opts,
+    headers: { "Authorization": "Bearer " + token(), "Content-Type": "application/json" },
+  });
+  if (res.status === 401) throw new AuthError("invalid token");
+  if (res.status === 204) return null;
+  const body = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(body.error || res.statusText);
+  return body;
+}
+
+// el builds an element: attrs are properties (on* become listeners),
+// children are nodes, strings or arrays of them; null is skipped.
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (v == null || v === false) continue;
+    if (k.startsWith("on")) e.addEventListener(k.slice(2), v);
+    else if (k === "class") e.className = v;
+    else e.setAttribute(k, v === true ? "" : v);
+  }
+  const add = (c) => {
+    if (c == null || c === false) return;
+    if (Array.isArray(c)) c.forEach(add);
+    else e.append(c instanceof Node ? c : document.createTextNode(String(c)));
+  };
+  children.forEach(add);
+  return e;
+}
+
+function fmtTime(v) {
+  if (!v) return "–";
+  const d = new Date(v);
+  const p = (n) => String(n).padStart(2, "0");
+  return `${d.getFullYear()}-${p(d.getMonth() + 1)}-${p(d.getDate())} ${p(d.getHours())}:${p(d.getMinutes())}`;
+}
+
+function ago(v) {
+  if (!v) return "";
+  const s = (Date.now() - new Date(v).getTime()) / 1000;
+  if (s < 90) return "just now";
+  if (s < 5400) return Math.round(s / 60) + " min ago";
+  if (s < 129600) return Math.round(s / 3600) + " h ago";
+  return Math.round(s / 86400) + " days ago";
+}
+
+const enc = encodeURIComponent;
+const domainLink = (d) => el("a", { href: "#/d/" + enc(d) }, d);
+const hostLink = (d, h, u) => el("a", { href: `#/h/${enc(d)}/${enc(h)}`, class: "mono" }, u ? `${u} (${h})` : h);
+const statusCell = (s) => el("span", { class: s === "ok" ? "ok" : s === "locked" || s === "interrupted" ? "warn" : "bad" }, s);
+const table = (head, rows) => el("table", null, el("tr", null, head.map((h) => el("th", null, h))), rows);
+
+function show(...nodes) { view.replaceChildren(...nodes); }
+
+// ---------- views ----------
+
+async function overview() {
+  const [status, found] = await Promise.all([api("status"), api("discoveries?since=7d&limit=300")]);
+  const d = status.daemon;
+  const daemon = d.state === "stopped" ? el("span", { class: "muted" }, "daemon not running")
+    : el("span", { class: d.state === "running" ? "ok" : "warn" }, `daemon ${d.state} (pid ${d.pid}, heartbeat ${ago(d.heartbeat)})`,
+      d.running && d.running.length ? ` · scanning ${d.running.join(", ")}` : "");
+  const rows = status.domains.map((r) => el("tr", null,
+    el("td", null, domainLink(r.domain), r.paused ? el("span", { class: "tag" }, "paused") : null),
+    el("td", null, r.schedule),
+    el("td", null, r.last_run ? fmtTime(r.last_run) : el("span", { class: "muted" }, "never")),
+    el("td", null, !r.last_run ? "–" : r.last_ok ? statusCell("ok") : el("span", { class: "bad", title: r.last_error || "" }, "failed")),
+    el("td", null, r.last_new), el("td", null, r.total),
+    el("td", null, r.paused ? "–" : r.next_run ? fmtTime(r.next_run) : r.due ? "due" : "–")));
+  show(
+    el("h1", null, "Monitored domains"),
+    el("p", null, daemon),
+    rows.length ? table(["Domain", "Schedule", "Last run", "Status", "New", "Hosts", "Next run"], rows)
+      : el("p", { class: "muted" }, "No domains yet: domwatch add example.com"),
+    el("h2", null, "Discoveries in the last 7 days"),
+    timeline(found, 7));
+}
+
+// timeline groups discoveries by scan (domain and time).
+function timeline(found, days) {
+  if (!found.length) return el("p", { class: "muted" }, `Nothing new in the last ${days} days.`);
+  const groups = [];
+  for (const x of found) {
+    const g = groups[groups.length - 1];
+    if (g && g.domain === x.domain && g.time === x.first_seen) g.hosts.push(x);
+    else groups.push({ domain: x.domain, time: x.first_seen, hosts: [x] });
+  }
+  return el("ul", { class: "timeline" }, groups.map((g) => el("li", null,
+    el("div", null, el("strong", null, fmtTime(g.time)), " · ", domainLink(g.domain), ` · ${g.hosts.length} new`,
+      el("span", { class: "muted" }, " · " + ago(g.time))),
+    el("div", { class: "hosts" }, g.hosts.map((x) => el("span", null, hostLink(x.domain, x.host, x.unicode),
+      x.in_scope ? null : el("span", { class: "tag" }, "out of scope")))))));
+}
+
+async function domainView(domain, q = "", scope = "", offset = 0) {
+  const params = new URLSearchParams({ q, scope, offset, limit: PAGE });
+  const [info, page, runs, found] = await Promise.all([
+    api("domains/" + enc(domain)), api(`domains/${enc(domain)}/hosts?${params}`),
+    api(`domains/${enc(domain)}/runs?limit=30`), api(`discoveries?domain=${enc(domain)}&since=30d&limit=300`)]);
+  const st = info.status;
+  const scanBtn = el("button", { onclick: () => scanNow(domain, scanBtn) }, "Scan now");
+  const filter = el("form", { class: "toolbar", onsubmit: (e) => { e.preventDefault(); domainView(domain, e.target.q.value, e.target.scope.value, 0).catch(fail); } },
+    el("input", { name: "q", type: "search", placeholder: "Filter hosts", value: q }),
+    el("select", { name: "scope" }, [["", "all"], ["in", "in scope"], ["out", "out of scope"]].map(([v, t]) => el("option", { value: v, selected: v === scope }, t))),
+    el("button", null, "Filter"));
+  const hostRows = page.hosts.map((h) => el("tr", null, el("td", null, hostLink(domain, h.host, h.unicode)),
+    el("td", null, h.in_scope ? "in" : el("span", { class: "muted" }, "out"))));
+  const pager = el("div", { class: "pager" },
+    el("button", { disabled: offset === 0, onclick: () => domainView(domain, q, scope, Math.max(0, offset - PAGE)).catch(fail) }, "‹ Prev"),
+    el("span", { class: "muted" }, page.total ? `${offset + 1}–${offset + page.hosts.length} of ${page.total}` : "no hosts"),
+    el("button", { disabled: offset + PAGE >= page.total, onclick: () => domainView(domain, q, scope, offset + PAGE).catch(fail) }, "Next ›"));
+  const runRows = runs.slice().reverse().map((r) => el("tr", null,
+    el("td", null, "#" + r.seq), el("td", null, fmtTime(r.start)), el("td", null, r.trigger),
+    el("td", null, statusCell(r.status), r.anomalies && r.anomalies.length ? el("span", { class: "warn", title: r.anomalies.join("\n") }, " ⚠") : null),
+    el("td", null, r.returned || 0), el("td", null, r.added || 0), el("td", null, r.removed || 0), el("td", null, r.total),
+    el("td", { class: "muted" }, (r.error || "").split("\n")[0])));
+  const ai = runs.slice().reverse().find((r) => r.ai_summary);
+  show(
+    el("h1", null, domain),
+    el("p", null, info.program ? el("span", { class: "tag" }, "program: " + info.program) : null,
+      info.tags.map((t) => el("span", { class: "tag" }, t)), info.paused ? el("span", { class: "tag" }, "paused") : null,
+      ` ${st.total} hosts · ${st.schedule} · last run ${fmtTime(st.last_run)} `, scanBtn),
+    el("h2", null, "Recent discoveries (30 days)"), timeline(found, 30),
+    el("h2", null, "Inventory"), filter, table(["Host", "Scope"], hostRows), pager,
+    el("h2", null, "Scan history"),
+    runRows.length ? table(["Run", "Started", "Trigger", "Status", "Returned", "New", "Not returned", "Total", "Error"], runRows)
+      : el("p", { class: "muted" }, "No recorded runs."),
+    ai ? [el("h2", null, `AI summary (run #${ai.seq})`), el("pre", null, ai.ai_summary)] : null);
+}
+
+async function scanNow(domain, btn) {
+  btn.disabled = true;
+  btn.textContent = "Scanning…";
+  try {
+    let job = await api("scans", { method: "POST", body: JSON.stringify({ domains: [domain] }) });
+    while (job.status !== "done") {
+      await new Promise((r) => setTimeout(r, 2000));
+      job = await api("scans/" + job.id);
+    }
+    const r = job.results[0];
+    if (r && r.status !== "ok") alert(`Scan ${r.status}: ${r.error || ""}`);
+    if (location.hash === "#/d/" + enc(domain)) await domainView(domain);
+  } catch (e) { fail(e); } finally { btn.disabled = false; btn.textContent = "Scan now"; }
+}
+
+async function hostView(domain, host) {
+  const h = await api(`domains/${enc(domain)}/hosts/${enc(host)}`);
+  const fact = (k, v) => [el("dt", null, k), el("dd", null, v)];
+  const records = h.resolved_at
+    ? [fact("Addresses", h.addrs.length ? el("span", { class: "mono" }, h.addrs.join(", ")) : el("span", { class: "muted" }, "does not resolve")),
+      fact("CNAME", h.cname ? el("span", { class: "mono" }, h.cname) : "–"),
+      h.takeover ? fact("Takeover", el("span", { class: "bad" }, `dangling CNAME (${h.takeover})`)) : null,
+      fact("Resolved", fmtTime(h.resolved_at))]
+    : [fact("Records", el("span", { class: "muted" }, "not resolved yet; scan with --resolve"))];
+  show(
+    el("p", null, domainLink(domain), " ›"),
+    el("h1", { class: "mono" }, h.unicode ? `${h.unicode} (${h.host})` : h.host),
+    el("dl", { class: "facts" },
+      fact("Scope", h.in_scope ? el("span", { class: "ok" }, "in scope") : el("span", { class: "muted" }, "out of scope")),
+      h.scope_reason ? fact("Scope rule", h.scope_reason) : null,
+      fact("First seen", h.first_seen ? `${fmtTime(h.first_seen)} (${ago(h.first_seen)})` : "–"),
+      fact("Last seen", h.last_seen ? `${fmtTime(h.last_seen)} (run #${h.last_run}, ${ago(h.last_seen)})` : el("span", { class: "muted" }, "not returned by any stored scan")),
+      fact("Returned by", `${h.snapshots} stored scan(s)`),
+      records,
+      h.probes.length ? null : fact("Probes", el("span", { class: "muted" }, "no hook output names this host (see domwatch hook add)"))),
+    el("section", null, h.probes.length ? el("h2", null, "Probe results") : null,
+      h.probes.map((p) => [
+        el("p", null, el("strong", null, p.hook), ` · run #${p.run}, ${fmtTime(p.at)} · `, statusCell(p.status)),
+        el("pre", null, p.lines.join("\n"))])));
+}
+
+async function searchView(q) {
+  document.querySelector("#search input").value = q;
+  const hits = await api("search?limit=500&q=" + enc(q));
+  show(
+    el("h1", null, `Search: ${q}`),
+    el("p", { class: "muted" }, hits.length >= 500 ? "first 500 matches" : `${hits.length} match(es)`),
+    hits.length ? table(["Host", "Domain"], hits.map((x) => el("tr", null, el("td", null, hostLink(x.domain, x.host, x.unicode)), el("td", null, domainLink(x.domain)))))
+      : el("p", { class: "muted" }, "No hosts match."));
+}
+
+// ---------- plumbing ----------
+
+function login() {
+  document.getElementById("logout").hidden = true;
+  const form = document.getElementById("login").content.firstElementChild.cloneNode(true);
+  form.addEventListener("submit", (e) => {
+    e.preventDefault();
+    localStorage.setItem("domwatch-token", form.token.value.trim());
+    route();
+  });
+  show(form);
+  form.token.focus();
+}
+
+function fail(e) {
+  if (e instanceof AuthError) { localStorage.removeItem("domwatch-token"); follow(false); login(); return; }
+  view.prepend(el("div", { class: "error" }, String(e.message || e)));
+}
+
+// live re-renders the overview when a scan finishes anywhere (see
+// /api/v1/events); other views are left alone so filters are not lost.
+let live = null, liveTimer = 0;
+function follow(on) {
+  if (!on) { if (live) live.close(); live = null; return; }
+  if (live) return;
+  live = new EventSource(API + "events?types=scan_finished&token=" + enc(token()));
+  live.addEventListener("scan_finished", () => {
+    clearTimeout(liveTimer);
+    liveTimer = setTimeout(() => overview().catch(fail), 1000);
+  });
+}
+
+function route() {
+  if (!token()) { follow(false); login(); return; }
+  document.getElementById("logout").hidden = false;
+  const parts = location.hash.replace(/^#\/?/, "").split("/").map(decodeURIComponent);
+  let p;
+  switch (parts[0]) {
+    case "d": p = domainView(parts[1]); break;
+    case "h": p = hostView(parts[1], parts[2]); break;
+    case "search": p = searchView(parts.slice(1).join("/")); break;
+    default: p = overview();
+  }
+  follow(!parts[0]);
+  p.catch(fail);
+}
+
+document.getElementById("search").addEventListener("submit", (e) => {
+  e.preventDefault();
+  const q = e.target.q.value.trim();
+  if (q) location.hash = "#/search/" + enc(q);
+});
+document.getElementById("logout").addEventListener("click", () => { localStorage.removeItem("domwatch-token"); follow(false); login(); });
+window.addEventListener("hashchange", route);
+route();
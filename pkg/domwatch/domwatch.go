@@ -0,0 +1,71 @@
+// Package domwatch embeds domwatch's subdomain monitoring in other Go
+// programs. A Monitor works on the same home directory as the domwatch
+// command: domains added here are scanned by the timer or daemon, scans run
+// here show up in `domwatch history`, `serve` and the event log, and
+// notifiers, hooks and scopes configured with the CLI apply.
+//
+//	m, err := domwatch.New(domwatch.Options{Home: "/var/lib/domwatch"})
+//	if err != nil { ... }
+//	defer m.Close()
+//	stop := m.OnEvent(func(ev domwatch.Event) {
+//		if ev.Type == domwatch.EventHostAdded { log.Println("new host", ev.Host) }
+//	})
+//	defer stop()
+//	if _, _, err := m.AddDomain(ctx, "example.com", domwatch.AddOptions{}); err != nil { ... }
+//	res, err := m.Scan(ctx, "example.com")
+//	fmt.Println(res.Status, res.Total, res.New)
+//
+// Scans need subfinder on $PATH (or SUBFINDER_PATH). Options.Timeout bounds
+// each enumeration; unlike the CLI's --timeout it has no default, so set it
+// (DefaultTimeout is the CLI's) unless scans may run for as long as subfinder
+// does.
+//
+// A process works on one home directory at a time. All Monitors open in it
+// must use the same Home, New fails for another one until every Monitor is
+// closed, and a public_suffix_list.dat installed in the home is only read by
+// the first. Services that watch several homes run one process per home.
+package domwatch
+
+import "github.com/0xSADAT/domwatch/internal/cli"
+
+type (
+	// Monitor adds, removes, lists and scans monitored domains. Its
+	// methods are safe for concurrent use; scans of different domains run
+	// in parallel, a second scan of the same domain waits for
+	// Options.LockWait and then fails with status "locked".
+	Monitor = cli.Monitor
+	// Options configure a Monitor.
+	Options = cli.MonitorOptions
+	// AddOptions are the choices of Monitor.AddDomain.
+	AddOptions = cli.AddOptions
+	// ScanResult is the outcome of Monitor.Scan: status, run number, new
+	// in-scope and out-of-scope hosts, inventory size, anomalies.
+	ScanResult = cli.ScanRecord
+	// Domain is a monitored root domain with its program, tags and
+	// schedule state.
+	Domain = cli.DomainRecord
+	// Host is an inventory host.
+	Host = cli.HostRecord
+	// Run is one entry of a domain's run history.
+	Run = cli.RunRecord
+	// HookResult is what a post-discovery hook did during a run.
+	HookResult = cli.HookResult
+	// Event is a scan event, see Monitor.OnEvent.
+	Event = cli.Event
+)
+
+// Event types.
+const (
+	EventScanStarted   = cli.EventScanStarted
+	EventHostAdded     = cli.EventHostAdded
+	EventHostRemoved   = cli.EventHostRemoved
+	EventRecordChanged = cli.EventRecordChanged
+	EventScanFinished  = cli.EventScanFinished
+)
+
+// DefaultTimeout is the per-domain enumeration limit of `domwatch scan`.
+const DefaultTimeout = cli.DefaultDomainTimeout
+
+// New returns a Monitor for opts.Home, creating the directory if needed. It
+// fails while a Monitor for another home is open in the process.
+func New(opts Options) (*Monitor, error) { return cli.NewMonitor(opts) }
@@ -0,0 +1,97 @@
+//go:build unix
+
+package domwatch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xSADAT/domwatch/pkg/domwatch"
+)
+
+// fakeSubfinder installs a script that prints www and api of the domain.
+func fakeSubfinder(t *testing.T) {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "subfinder")
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do [ "$1" = "-d" ] && d=$2; shift; done
+echo "{\"host\":\"www.$d\",\"sources\":[\"crtsh\"]}"
+echo "{\"host\":\"api.$d\",\"sources\":[\"crtsh\"]}"
+`
+	if err := os.WriteFile(p, []byte(script), 0o755); err != nil { t.Fatal(err) }
+	t.Setenv("SUBFINDER_PATH", p)
+}
+
+func TestMonitor(t *testing.T) {
+	fakeSubfinder(t)
+	home := t.TempDir()
+	m, err := domwatch.New(domwatch.Options{Home: home}); if err != nil { t.Fatal(err) }
+	defer m.Close()
+	if m.Home() != home { t.Errorf("Home = %q, want %q", m.Home(), home) }
+	ctx := context.Background()
+
+	d, created, err := m.AddDomain(ctx, "Example.COM", domwatch.AddOptions{Program: "acme", Tags: []string{"web"}})
+	if err != nil || d != "example.com" || !created { t.Fatalf("AddDomain = %q, %v, %v", d, created, err) }
+	if _, _, err := m.AddDomain(ctx, "co.uk", domwatch.AddOptions{}); err == nil { t.Error("public suffix added") }
+
+	var events []domwatch.Event
+	stop := m.OnEvent(func(ev domwatch.Event) { events = append(events, ev) })
+	res, err := m.Scan(ctx, d)
+	if err != nil || res.Status != "ok" || res.Run != 1 || res.Total != 2 { t.Fatalf("Scan = %+v, %v", res, err) }
+	var types []string
+	for _, ev := range events { types = append(types, ev.Type) }
+	want := []string{domwatch.EventScanStarted, domwatch.EventHostAdded, domwatch.EventHostAdded, domwatch.EventScanFinished}
+	if len(types) != len(want) { t.Fatalf("events %q, want %q", types, want) }
+	for i := range want { if types[i] != want[i] { t.Errorf("event %d = %s, want %s", i, types[i], want[i]) } }
+
+	stop()
+	if _, err := m.Scan(ctx, d); err != nil { t.Fatal(err) }
+	if len(events) != len(want) { t.Errorf("%d events after unsubscribing", len(events)-len(want)) }
+
+	domains, err := m.List(); if err != nil { t.Fatal(err) }
+	if len(domains) != 1 || domains[0].Domain != d || domains[0].Program != "acme" { t.Errorf("List = %+v", domains) }
+	hosts, err := m.Hosts(d); if err != nil { t.Fatal(err) }
+	if len(hosts) != 2 || hosts[0].Host != "api.example.com" { t.Errorf("Hosts = %+v", hosts) }
+	runs, err := m.Runs(d); if err != nil { t.Fatal(err) }
+	if len(runs) != 2 || runs[1].Trigger != "library" { t.Errorf("Runs = %+v", runs) }
+
+	if err := m.RemoveDomain(ctx, d); err != nil { t.Fatal(err) }
+	if domains, _ := m.List(); len(domains) != 0 { t.Errorf("List after remove = %+v", domains) }
+}
+
+func TestMonitorListenersPerMonitor(t *testing.T) {
+	fakeSubfinder(t)
+	home := t.TempDir()
+	a, err := domwatch.New(domwatch.Options{Home: home}); if err != nil { t.Fatal(err) }
+	defer a.Close()
+	b, err := domwatch.New(domwatch.Options{Home: home}); if err != nil { t.Fatal(err) }
+	defer b.Close()
+	var na, nb int
+	a.OnEvent(func(domwatch.Event) { na++ })
+	b.OnEvent(func(domwatch.Event) { nb++ })
+	ctx := context.Background()
+	if _, _, err := a.AddDomain(ctx, "example.com", domwatch.AddOptions{}); err != nil { t.Fatal(err) }
+	if _, err := a.Scan(ctx, "example.com"); err != nil { t.Fatal(err) }
+	if na == 0 || nb != 0 { t.Errorf("events: scanning Monitor got %d, other got %d", na, nb) }
+}
+
+func TestMonitorHome(t *testing.T) {
+	one, two := t.TempDir(), t.TempDir()
+	a, err := domwatch.New(domwatch.Options{Home: one}); if err != nil { t.Fatal(err) }
+	if _, err := domwatch.New(domwatch.Options{Home: two}); err == nil { t.Fatal("second home accepted while the first is open") }
+	same, err := domwatch.New(domwatch.Options{Home: one}); if err != nil { t.Fatalf("same home: %v", err) }
+	a.Close()
+	if _, err := domwatch.New(domwatch.Options{Home: two}); err == nil { t.Fatal("second home accepted while a Monitor is open") }
+	same.Close()
+	same.Close() // closing twice releases once
+	b, err := domwatch.New(domwatch.Options{Home: two}); if err != nil { t.Fatalf("after Close: %v", err) }
+	defer b.Close()
+	if b.Home() != two { t.Errorf("Home = %q, want %q", b.Home(), two) }
+	if _, err := os.Stat(filepath.Join(two, "data")); err != nil { t.Errorf("home not created: %v", err) }
+
+	// an empty Home is $DOMWATCH_HOME, and so conflicts too
+	t.Setenv("DOMWATCH_HOME", one)
+	if _, err := domwatch.New(domwatch.Options{}); err == nil { t.Error("$DOMWATCH_HOME accepted while another home is open") }
+}